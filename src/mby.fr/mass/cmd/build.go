@@ -25,7 +25,10 @@ import (
 var buildCmd = &cobra.Command{
 	Use:   "build <resourceExpr>",
 	Short: "Build resources",
-	Long:  ``,
+	Long: `Build images whose signature changed.
+
+An image built from the same sources as a previous version is relabeled instead of rebuilt:
+only its version and git revision labels are updated on top of the previous image.`,
 	//Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		workspace.ForceBuild = true
@@ -45,6 +48,8 @@ func init() {
 	//rootCmd.PersistentFlags().StringVarP(&settings.SelectedEnvironment, "env", "e", "", "environment to use")
 	buildCmd.PersistentFlags().BoolVarP(&workspace.NoCacheBuild, "no-cache", "", false, "Disable build cache")
 	buildCmd.PersistentFlags().BoolVarP(&workspace.ForcePull, "pull", "p", false, "Attempt to pull newer image versions")
+	buildCmd.PersistentFlags().BoolVarP(&workspace.DryRun, "dry-run", "", false, "Display the plan without running anything")
+	buildCmd.PersistentFlags().BoolVarP(&workspace.AllowOverwrite, "allow-overwrite", "", false, "Allow to overwrite released versions built from other sources")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -41,7 +41,8 @@ func init() {
 	// bumpCmd.PersistentFlags().String("foo", "", "A help for foo")
 	bumpCmd.Flags().BoolVarP(&workspace.BumpMajor, "major", "", false, "Bump major version")
 	bumpCmd.Flags().BoolVarP(&workspace.BumpMinor, "minor", "", false, "Bump minor version")
-	bumpCmd.MarkFlagsMutuallyExclusive("major", "minor")
+	bumpCmd.Flags().BoolVarP(&workspace.BumpAuto, "auto", "", false, "Infer patch, minor or major bump from conventional commits since previous release")
+	bumpCmd.MarkFlagsMutuallyExclusive("major", "minor", "auto")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,48 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/workspace"
+)
+
+// changelogCmd represents the changelog command
+var changelogCmd = &cobra.Command{
+	Use:   "changelog <resourceExpr>",
+	Short: "write changelog of images from git history",
+	Long: `Collect commits touching each image source dir, build file and test dir since its previous release tag.
+Commits are grouped by conventional commit type (feat, fix, ...) in the CHANGELOG.md of the image.
+A RELEASE_NOTES.md aggregating the changes of all images is written in each project dir.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.ChangelogResources(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// changelogCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// changelogCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
@@ -41,6 +41,7 @@ func init() {
 	// and all subcommands, e.g.:
 	// downCmd.PersistentFlags().String("foo", "", "A help for foo")
 	downCmd.PersistentFlags().BoolVarP(&workspace.RmVolumes, "volumes", "", false, "Remove persistent volumes")
+	downCmd.PersistentFlags().BoolVarP(&workspace.DryRun, "dry-run", "", false, "Display the plan without running anything")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,49 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/graph"
+	"mby.fr/mass/internal/workspace"
+)
+
+// graphCmd represents the graph command
+var graphCmd = &cobra.Command{
+	Use:   "graph [resourceExpr]",
+	Short: "Display resources and dependencies graph",
+	Long: `Display the env/project/image tree with image and project dependencies.
+Images which changed since their last build are highlighted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.GraphResources(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// graphCmd.PersistentFlags().String("foo", "", "A help for foo")
+	graphCmd.Flags().StringVarP(&workspace.GraphFormat, "format", "f", graph.TextFormat, "Graph format: text, dot or mermaid")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// graphCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
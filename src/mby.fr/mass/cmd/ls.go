@@ -0,0 +1,52 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/workspace"
+)
+
+// lsCmd represents the ls command
+var lsCmd = &cobra.Command{
+	Use:     "ls [resourceExpr]",
+	Aliases: []string{"get"},
+	Short:   "List workspace resources",
+	Long: `List workspace resources with their kind, qualified name, directory,
+version, build file and test directory. Without expression all resources are listed.
+Output can be an aligned table, json, yaml or a go template applied to each resource, e.g.:
+mass ls p1 --format '{{.Name}} {{.Version}}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.ListResources(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// lsCmd.PersistentFlags().String("foo", "", "A help for foo")
+	lsCmd.Flags().StringVarP(&workspace.ListOutput, "output", "o", workspace.TableOutput, "Output mode: table, json or yaml")
+	lsCmd.Flags().StringVar(&workspace.ListFormat, "format", "", "Go template applied to each resource")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// lsCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/workspace"
+)
+
+var planPhase string
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan <resourceExpr>",
+	Short: "Preview what build, up or down would do",
+	Long: `Report for each resource if it would be built, pulled, created, recreated,
+removed or left alone, followed by the commands which would be run. Nothing is run.
+The same plan is displayed by build, up and down with the --dry-run flag.`,
+	//Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.PlanResources(planPhase, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// planCmd.PersistentFlags().String("foo", "", "A help for foo")
+	planCmd.Flags().StringVar(&planPhase, "phase", workspace.UpPhase, "Phase to plan: build, up or down")
+	planCmd.Flags().StringVarP(&workspace.PlanOutput, "output", "o", workspace.TableOutput, "Output mode: table, json or yaml")
+	planCmd.Flags().BoolVarP(&workspace.NoCacheBuild, "no-cache", "", false, "Disable build cache")
+	planCmd.Flags().BoolVarP(&workspace.ForceBuild, "build", "b", false, "Force build")
+	planCmd.Flags().BoolVarP(&workspace.ForcePull, "pull", "p", false, "Force pull")
+	planCmd.Flags().BoolVarP(&workspace.ForceDeploy, "force", "f", false, "Redeploy even if nothing changed")
+	planCmd.Flags().BoolVarP(&workspace.RmVolumes, "volumes", "", false, "Remove persistent volumes")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// planCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
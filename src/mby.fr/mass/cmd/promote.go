@@ -39,6 +39,7 @@ func init() {
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	// promoteCmd.PersistentFlags().String("foo", "", "A help for foo")
+	promoteCmd.PersistentFlags().BoolVarP(&workspace.ForcePromote, "force", "f", false, "Promote even without passing test record")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -0,0 +1,55 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/workspace"
+)
+
+// pushCmd represents the push command
+var pushCmd = &cobra.Command{
+	Use:   "push <resourceExpr>",
+	Short: "Push images to the working env registry",
+	Long: `Push built images to the registry configured for the working env in settings, e.g.:
+registries:
+  dev:
+    host: localhost:5000
+    namespace: myTeam
+    insecure: true
+    credentialsFile: /home/me/.docker/config.json
+Only released or promoted versions are pushed unless --force is used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.PushResources(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// pushCmd.PersistentFlags().String("foo", "", "A help for foo")
+	pushCmd.Flags().BoolVarP(&workspace.ForcePush, "force", "f", false, "Push dev versions too")
+	pushCmd.Flags().BoolVarP(&workspace.AllowOverwrite, "allow-overwrite", "", false, "Allow to overwrite released versions pushed from other sources")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// pushCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
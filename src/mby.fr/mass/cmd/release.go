@@ -25,20 +25,36 @@ import (
 var releaseCmd = &cobra.Command{
 	Use:   "release <resourceExpr>",
 	Short: "release resources",
-	Long: ``,
+	Long: `Release pre-release images.
+With --train every image of the given projects is released together: all transitions
+are validated before any resource file is written and written files are rolled back on
+error. The released versions are recorded in the project releases.yaml manifest.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		workspace.ReleaseResources(args)
 	},
 }
 
+// releaseTrainsCmd represents the release trains command
+var releaseTrainsCmd = &cobra.Command{
+	Use:   "trains <projectExpr>",
+	Short: "Display release trains",
+	Long:  `Display recorded release trains of projects with the released version of each image.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.ReleaseTrainsHistory(args)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseTrainsCmd)
 
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	// releaseCmd.PersistentFlags().String("foo", "", "A help for foo")
+	releaseCmd.PersistentFlags().BoolVarP(&workspace.ForceRelease, "force", "f", false, "Release even without passing test record")
+	releaseCmd.Flags().BoolVarP(&workspace.ReleaseTrain, "train", "", false, "Release all images of projects together or none of them")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -22,7 +22,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"mby.fr/mass/internal/interrupt"
 	"mby.fr/mass/internal/settings"
+	"mby.fr/mass/internal/workspace"
 )
 
 var cfgFile string
@@ -40,6 +42,7 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	interrupt.Handle(workspace.CleanupInterrupted)
 	cobra.CheckErr(rootCmd.Execute())
 }
 
@@ -53,6 +56,9 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mass.yaml)")
 	rootCmd.PersistentFlags().StringVarP(&settings.SelectedEnvironment, "env", "e", "", "environment to use")
 	rootCmd.PersistentFlags().CountVarP(&settings.LoggingLevel, "verbose", "v", "verbosity level")
+	rootCmd.PersistentFlags().StringVar(&settings.SelectedEngine, "engine", "", "engine to use: cli or api (default from settings)")
+	rootCmd.PersistentFlags().IntVar(&workspace.Parallel, "parallel", 0, "max count of actions run at once (default is the count of CPUs)")
+	rootCmd.PersistentFlags().BoolVar(&workspace.FailFast, "fail-fast", false, "stop all actions on first error instead of keeping going")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
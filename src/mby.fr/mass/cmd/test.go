@@ -25,20 +25,42 @@ import (
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Launch test",
-	Long:  ``,
+	Long: `Build resources, deploy them in an isolated namespace and run their tests.
+Containers, compose projects and network of the namespace are uniquely suffixed.
+The namespace is torn down after tests unless --keep is set. On failure containers
+logs and inspect outputs are collected before teardown.
+Tests join the network of the tested containers and receive the merged config
+environment and generated variables of deployed images: <image>_container,
+<image>_ip and <image>_port_<port>. Venom reads them as VENOM_VAR_<name>.
+Test results are merged in report.xml (JUnit) and report.json. Images results are
+recorded for their version and signature: promote and release require a passing record.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		workspace.TestResources(args)
 	},
 }
 
+// testHistoryCmd represents the test history command
+var testHistoryCmd = &cobra.Command{
+	Use:   "history <resourceExpr>",
+	Short: "Display test history",
+	Long:  `Display recorded test runs of images with their signature, outcome and duration.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.TestHistoryResources(args)
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(testCmd)
+	testCmd.AddCommand(testHistoryCmd)
 
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
-	// testCmd.PersistentFlags().String("foo", "", "A help for foo")
+	// testCmd.Flags().String("foo", "", "A help for foo")
+	testCmd.Flags().BoolVarP(&workspace.KeepTestEnv, "keep", "", false, "Keep the test namespace after tests")
+	testCmd.Flags().StringVarP(&workspace.TestReport, "report", "", "", "Directory of the JUnit and JSON test reports, default in the cache dir")
+	testCmd.Flags().BoolVarP(&workspace.ForcePull, "pull", "p", false, "Pull images instead of building them")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
@@ -43,6 +43,11 @@ func init() {
 	upCmd.PersistentFlags().BoolVarP(&workspace.NoCacheBuild, "no-cache", "", false, "Disable build cache")
 	upCmd.PersistentFlags().BoolVarP(&workspace.ForceBuild, "build", "b", false, "Force build")
 	upCmd.PersistentFlags().BoolVarP(&workspace.ForcePull, "pull", "p", false, "Force pull")
+	upCmd.PersistentFlags().BoolVarP(&workspace.ForceDeploy, "force", "f", false, "Redeploy even if nothing changed")
+	upCmd.PersistentFlags().BoolVarP(&workspace.DryRun, "dry-run", "", false, "Display the plan without running anything")
+	upCmd.PersistentFlags().BoolVarP(&workspace.Locked, "locked", "", false, "Deploy images by their id recorded in mass.lock without building nor pulling them")
+	upCmd.PersistentFlags().BoolVarP(&workspace.AllowOverwrite, "allow-overwrite", "", false, "Allow to overwrite released versions built from other sources")
+	upCmd.MarkFlagsMutuallyExclusive("locked", "build", "pull")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
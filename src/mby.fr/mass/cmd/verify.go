@@ -0,0 +1,48 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"mby.fr/mass/internal/workspace"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify <resourceExpr>",
+	Short: "verify resources against the lock file",
+	Long: `Report drifts between the mass.lock file of the workspace and the working tree.
+Each built or pulled image is locked with its full name, image id, build signature
+and build args hash. Exits on error if any image drifted or is not locked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		workspace.VerifyResources(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// verifyCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// verifyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+}
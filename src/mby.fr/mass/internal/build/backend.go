@@ -0,0 +1,339 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mby.fr/mass/internal/command"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/plan"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/engine"
+)
+
+const (
+	DockerBackend  = "docker"
+	PodmanBackend  = "podman"
+	BuildahBackend = "buildah"
+	BuildxBackend  = "buildx"
+)
+
+var UnknownBackend error = fmt.Errorf("Unknown build backend")
+
+// A backend builds a single image. Dependencies ordering and change detection are handled by the Builder.
+type Backend interface {
+	BuildImage(ctx context.Context, log logger.ActionLogger, image resources.Image, opts BuildOptions) error
+	// Command line BuildImage would run, used to plan builds
+	CommandLine(image resources.Image, opts BuildOptions) (string, error)
+	// Id of a local image, empty if not found
+	ImageId(ref string) string
+	// Add tags to a local image without building it
+	TagImage(ctx context.Context, log logger.ActionLogger, source string, tags []string) error
+	// Build a local image only replacing its labels, e.g. to reuse an image built for another version
+	RelabelImage(ctx context.Context, log logger.ActionLogger, source string, opts BuildOptions) error
+	// Pull an image built elsewhere, e.g. by a teammate sharing caches
+	PullImage(ctx context.Context, log logger.ActionLogger, ref string) error
+}
+
+type BackendFactory func() (Backend, error)
+
+var backends = map[string]BackendFactory{
+	DockerBackend:  newDockerBackend,
+	PodmanBackend:  cliBackendFactory("podman", "build"),
+	BuildahBackend: cliBackendFactory("buildah", "bud"),
+	BuildxBackend:  cliBackendFactory("docker", "buildx", "build", "--load"),
+}
+
+func RegisterBackend(name string, factory BackendFactory) {
+	backends[name] = factory
+}
+
+func NewBackend(name string) (Backend, error) {
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", UnknownBackend, name)
+	}
+	return factory()
+}
+
+// Name of the backend building an image: the image builder if set, else the settings builder.
+func BackendName(image resources.Image) (name string, err error) {
+	if image.Builder != "" {
+		return image.Builder, nil
+	}
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	name = ss.Settings().Builder
+	if name == "" {
+		name = DockerBackend
+	}
+	return
+}
+
+// Build context of an image built from source without any other instruction
+func relabelContext(source string) (dir string, err error) {
+	dir, err = os.MkdirTemp("", "mass-relabel-")
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM "+source+"\n"), 0644)
+	return
+}
+
+// Build with a binary, e.g. podman build or docker buildx build
+type cliBackend struct {
+	binary      string
+	buildParams []string
+}
+
+func cliBackendFactory(binary string, buildParams ...string) BackendFactory {
+	return func() (Backend, error) {
+		return cliBackend{binary, buildParams}, nil
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b cliBackend) params(image resources.Image, opts BuildOptions) (params []string, err error) {
+	buildFile, err := filepath.Rel(image.Dir(), image.AbsBuildFile())
+	if err != nil {
+		return
+	}
+	params = append(params, b.buildParams...)
+	for _, tag := range opts.Tags {
+		params = append(params, "-t", tag)
+	}
+	params = append(params, "-f", buildFile)
+
+	// Add --no-cache option
+	if opts.NoCache {
+		params = append(params, "--no-cache")
+	}
+
+	if opts.ForcePull {
+		params = append(params, "--pull")
+	}
+
+	for _, argKey := range sortedKeys(opts.BuildArgs) {
+		var buildArg string = "--build-arg=" + argKey + "=" + opts.BuildArgs[argKey]
+		params = append(params, buildArg)
+	}
+
+	for _, labelKey := range sortedKeys(opts.Labels) {
+		var label string = "--label=" + labelKey + "=" + opts.Labels[labelKey]
+		params = append(params, label)
+	}
+
+	// Add dot folder as last param
+	params = append(params, ".")
+	return
+}
+
+func (b cliBackend) CommandLine(image resources.Image, opts BuildOptions) (line string, err error) {
+	buildParams, err := b.params(image, opts)
+	if err != nil {
+		return
+	}
+	return plan.CommandLine(b.binary, buildParams...), nil
+}
+
+func (b cliBackend) BuildImage(ctx context.Context, log logger.ActionLogger, image resources.Image, opts BuildOptions) (err error) {
+	buildParams, err := b.params(image, opts)
+	if err != nil {
+		return
+	}
+
+	log.Debug("build params: %s", buildParams)
+	cmd := exec.Command(b.binary, buildParams...)
+	cmd.Dir = image.Dir()
+
+	return command.RunLoggingContext(ctx, cmd, log)
+}
+
+func (b cliBackend) ImageId(ref string) string {
+	params := []string{"image", "inspect", "--format", "{{.Id}}", ref}
+	if b.binary == "buildah" {
+		params = []string{"inspect", "--type", "image", "--format", "{{.FromImageID}}", ref}
+	}
+	out, err := exec.Command(b.binary, params...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (b cliBackend) TagImage(ctx context.Context, log logger.ActionLogger, source string, tags []string) (err error) {
+	for _, tag := range tags {
+		log.Debug("tag %s as %s", source, tag)
+		cmd := exec.Command(b.binary, "tag", source, tag)
+		err = command.RunLoggingContext(ctx, cmd, log)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b cliBackend) RelabelImage(ctx context.Context, log logger.ActionLogger, source string, opts BuildOptions) (err error) {
+	dir, err := relabelContext(source)
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var params []string
+	params = append(params, b.buildParams...)
+	for _, tag := range opts.Tags {
+		params = append(params, "-t", tag)
+	}
+	params = append(params, "-f", "Dockerfile")
+	for _, labelKey := range sortedKeys(opts.Labels) {
+		params = append(params, "--label="+labelKey+"="+opts.Labels[labelKey])
+	}
+	params = append(params, ".")
+
+	log.Debug("relabel params: %s", params)
+	cmd := exec.Command(b.binary, params...)
+	cmd.Dir = dir
+	return command.RunLoggingContext(ctx, cmd, log)
+}
+
+func (b cliBackend) PullImage(ctx context.Context, log logger.ActionLogger, ref string) (err error) {
+	log.Debug("pull %s", ref)
+	cmd := exec.Command(b.binary, "pull", ref)
+	return command.RunLoggingContext(ctx, cmd, log)
+}
+
+// Build with the docker binary or the engine API depending on engine settings
+func newDockerBackend() (Backend, error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return nil, err
+	}
+	client, err := ss.EngineClient()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		return engineBackend{client}, nil
+	}
+	return cliBackend{"docker", []string{"build"}}, nil
+}
+
+type engineBackend struct {
+	client *engine.Client
+}
+
+func (b engineBackend) options(image resources.Image, opts BuildOptions) (engineOpts engine.BuildOptions, err error) {
+	buildFile, err := filepath.Rel(image.Dir(), image.AbsBuildFile())
+	if err != nil {
+		return
+	}
+	engineOpts = engine.BuildOptions{
+		Tags:      opts.Tags,
+		BuildFile: filepath.ToSlash(buildFile),
+		BuildArgs: opts.BuildArgs,
+		Labels:    opts.Labels,
+		NoCache:   opts.NoCache,
+		ForcePull: opts.ForcePull,
+	}
+	return
+}
+
+// Engine API calls are rendered as an equivalent pseudo command
+func (b engineBackend) CommandLine(image resources.Image, opts BuildOptions) (line string, err error) {
+	engineOpts, err := b.options(image, opts)
+	if err != nil {
+		return
+	}
+	var params []string
+	params = append(params, "build")
+	for _, tag := range engineOpts.Tags {
+		params = append(params, "-t", tag)
+	}
+	params = append(params, "-f", engineOpts.BuildFile)
+	if engineOpts.NoCache {
+		params = append(params, "--no-cache")
+	}
+	if engineOpts.ForcePull {
+		params = append(params, "--pull")
+	}
+	for _, argKey := range sortedKeys(engineOpts.BuildArgs) {
+		params = append(params, "--build-arg="+argKey+"="+engineOpts.BuildArgs[argKey])
+	}
+	for _, labelKey := range sortedKeys(engineOpts.Labels) {
+		params = append(params, "--label="+labelKey+"="+engineOpts.Labels[labelKey])
+	}
+	params = append(params, image.Dir())
+	return plan.CommandLine("engine", params...), nil
+}
+
+func (b engineBackend) BuildImage(ctx context.Context, log logger.ActionLogger, image resources.Image, opts BuildOptions) (err error) {
+	engineOpts, err := b.options(image, opts)
+	if err != nil {
+		return
+	}
+	log.Debug("build options: %v", engineOpts)
+	id, err := b.client.Build(ctx, image.Dir(), engineOpts, log.Out())
+	if err != nil {
+		return
+	}
+	log.Info("Built image id: %s", id)
+	return
+}
+
+func (b engineBackend) ImageId(ref string) string {
+	info, err := b.client.ImageInspect(ref)
+	if err != nil {
+		return ""
+	}
+	return info.Id
+}
+
+func (b engineBackend) TagImage(ctx context.Context, log logger.ActionLogger, source string, tags []string) (err error) {
+	for _, tag := range tags {
+		log.Debug("tag %s as %s", source, tag)
+		err = b.client.ImageTag(source, tag)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b engineBackend) RelabelImage(ctx context.Context, log logger.ActionLogger, source string, opts BuildOptions) (err error) {
+	dir, err := relabelContext(source)
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	engineOpts := engine.BuildOptions{
+		Tags:      opts.Tags,
+		BuildFile: "Dockerfile",
+		Labels:    opts.Labels,
+	}
+	log.Debug("relabel options: %v", engineOpts)
+	_, err = b.client.Build(ctx, dir, engineOpts, log.Out())
+	return
+}
+
+func (b engineBackend) PullImage(ctx context.Context, log logger.ActionLogger, ref string) (err error) {
+	log.Debug("pull %s", ref)
+	return b.client.Pull(ctx, ref, log.Out())
+}
@@ -0,0 +1,89 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestCliBackendParams(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+	opts := BuildOptions{Tags: []string{image.FullName(), "p1/i1:latest"}, BuildArgs: map[string]string{"b": "2", "a": "1"}, Labels: map[string]string{"l": "v"}}
+
+	cases := []struct {
+		backend string
+		noCache bool
+		pull    bool
+		binary  string
+		params  []string
+	}{
+		{PodmanBackend, false, false, "podman", []string{"build", "-t", image.FullName(), "-t", "p1/i1:latest", "-f", "Dockerfile", "--build-arg=a=1", "--build-arg=b=2", "--label=l=v", "."}},
+		{BuildahBackend, true, false, "buildah", []string{"bud", "-t", image.FullName(), "-t", "p1/i1:latest", "-f", "Dockerfile", "--no-cache", "--build-arg=a=1", "--build-arg=b=2", "--label=l=v", "."}},
+		{BuildxBackend, true, true, "docker", []string{"buildx", "build", "--load", "-t", image.FullName(), "-t", "p1/i1:latest", "-f", "Dockerfile", "--no-cache", "--pull", "--build-arg=a=1", "--build-arg=b=2", "--label=l=v", "."}},
+		{DockerBackend, false, true, "docker", []string{"build", "-t", image.FullName(), "-t", "p1/i1:latest", "-f", "Dockerfile", "--pull", "--build-arg=a=1", "--build-arg=b=2", "--label=l=v", "."}},
+	}
+	for _, c := range cases {
+		backend, err := NewBackend(c.backend)
+		require.NoError(t, err, "should not error for backend %s", c.backend)
+		cli, ok := backend.(cliBackend)
+		require.True(t, ok, "backend %s should be a cli backend", c.backend)
+		assert.Equal(t, c.binary, cli.binary, "bad binary for backend %s", c.backend)
+		opts.NoCache = c.noCache
+		opts.ForcePull = c.pull
+		params, err := cli.params(image, opts)
+		require.NoError(t, err, "should not error for backend %s", c.backend)
+		assert.Equal(t, c.params, params, "bad params for backend %s", c.backend)
+	}
+
+	_, err := NewBackend("foo")
+	assert.ErrorIs(t, err, UnknownBackend)
+}
+
+func TestBackendName(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+
+	name, err := BackendName(image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, DockerBackend, name, "default backend should be docker")
+
+	settingsFile := filepath.Join(wksPath, ".mass", "settings.yaml")
+	content, err := os.ReadFile(settingsFile)
+	require.NoError(t, err, "should not error")
+	content = []byte(strings.Replace(string(content), "builder: docker", "builder: buildah", 1))
+	err = os.WriteFile(settingsFile, content, 0644)
+	require.NoError(t, err, "should not error")
+
+	name, err = BackendName(image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, BuildahBackend, name, "backend should come from settings")
+
+	image.Builder = PodmanBackend
+	name, err = BackendName(image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, PodmanBackend, name, "image builder should override settings")
+
+	image.Builder = "foo"
+	_, err = resolveBackends([][]resources.Image{{image}})
+	assert.ErrorIs(t, err, UnknownBackend)
+}
+
+func TestRelabelContext(t *testing.T) {
+	dir, err := relabelContext("sha256:0a1b2c3d")
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+
+	content, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "FROM sha256:0a1b2c3d\n", string(content), "relabel should only build from the source image")
+}
@@ -3,29 +3,52 @@ package build
 import (
 	//"bytes"
 
+	"context"
 	"fmt"
-	"os/exec"
-	"sync"
 
 	"mby.fr/mass/internal/change"
-	"mby.fr/mass/internal/command"
 	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/plan"
 	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/concurrent"
 )
 
 var NotBuildableResource error = fmt.Errorf("Not buildable resource")
 
 type Builder interface {
-	Build(onlyIfChange bool, noCache bool, forcePull bool) error
+	// Build at most pool.Parallelism images at once. Running builds are killed when ctx is done.
+	// Released versions are not rebuilt from changed sources unless allowOverwrite.
+	Build(ctx context.Context, pool concurrent.Options, onlyIfChange bool, noCache bool, forcePull bool, allowOverwrite bool) error
+	// Steps Build would do without building anything
+	Plan(onlyIfChange bool, noCache bool, forcePull bool) (plan.Plan, error)
 }
 
-func New(r resources.Resourcer) (Builder, error) {
+func New(rs ...resources.Resourcer) (Builder, error) {
 	var images []resources.Image
+	added := map[string]bool{}
+	for _, r := range rs {
+		imgs, err := buildableImages(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range imgs {
+			// Do not build an image twice
+			if !added[i.Name()] {
+				added[i.Name()] = true
+				images = append(images, i)
+			}
+		}
+	}
+	return ImagesBuilder{images}, nil
+}
+
+func buildableImages(r resources.Resourcer) (images []resources.Image, err error) {
 	switch res := r.(type) {
 	case *resources.Project:
-		return New(*res)
+		return buildableImages(*res)
 	case *resources.Image:
-		return New(*res)
+		return buildableImages(*res)
 	case resources.Project:
 		imgs, err := res.Images()
 		if err != nil {
@@ -39,100 +62,308 @@ func New(r resources.Resourcer) (Builder, error) {
 	default:
 		return nil, fmt.Errorf("%w: %s", NotBuildableResource, r.QualifiedName())
 	}
-	return DockerBuilder{"docker", images}, nil
+	return
 }
 
-type DockerBuilder struct {
-	binary string
+// Build images with the backend configured for each image
+type ImagesBuilder struct {
 	images []resources.Image
 }
 
-func (b DockerBuilder) Build(onlyIfChange bool, noCache bool, forcePull bool) (err error) {
-	buildCount := len(b.images)
-	errors := make(chan error, buildCount*2)
-	var wg sync.WaitGroup
+// Resolve the backend of each image before building anything
+func resolveBackends(levels [][]resources.Image) (backends map[string]Backend, err error) {
+	backends = map[string]Backend{}
+	byName := map[string]Backend{}
+	for _, level := range levels {
+		for _, image := range level {
+			name, err := BackendName(image)
+			if err != nil {
+				return nil, err
+			}
+			backend, ok := byName[name]
+			if !ok {
+				backend, err = NewBackend(name)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to build image %s: %w", image.Name(), err)
+				}
+				byName[name] = backend
+			}
+			backends[image.Name()] = backend
+		}
+	}
+	return
+}
 
-	for _, image := range b.images {
-		wg.Add(1)
-		go func(image resources.Image) {
-			defer wg.Done()
-			buildDockerImage(b.binary, image, onlyIfChange, noCache, forcePull, errors)
-		}(image)
+func (b ImagesBuilder) levels() (levels [][]resources.Image, backends map[string]Backend, err error) {
+	workspaceImages, err := resources.ListImages()
+	if err != nil {
+		return
 	}
+	levels, errors := Levels(b.images, workspaceImages)
+	if errors.GotError() {
+		return nil, nil, errors
+	}
+	backends, err = resolveBackends(levels)
+	return
+}
 
-	// Wait for all build to finish
-	wg.Wait()
+// Decide if an image must be built. If forcePull => force build.
+func mustBuild(image resources.Image, onlyIfChange bool, forcePull bool) (build bool, reason string, err error) {
+	if forcePull {
+		return true, "pull forced", nil
+	}
+	if !onlyIfChange {
+		return true, "build forced", nil
+	}
+	changed, _, err := change.DoesImageChanged(image)
+	if err != nil {
+		return
+	}
+	if !changed {
+		return false, "signature unchanged", nil
+	}
+	return true, "signature changed", nil
+}
 
-	// Use select to not block if no error in channel
-	select {
-	case err = <-errors:
-	default:
+// Id of a still present local image built from the same signature, e.g. before a bump or a promotion.
+// Images are only reused when building changed images with cache.
+// A reused image is relabeled so its version and git revision labels stay up to date.
+func reusableImage(backend Backend, image resources.Image, onlyIfChange bool, noCache bool, forcePull bool) (id string, err error) {
+	if !onlyIfChange || noCache || forcePull {
+		return
 	}
+	return builtImage(backend, image)
+}
 
-	return err
+// Id of a still present local image built from the same signature, possibly recorded by a teammate. Empty if none.
+func builtImage(backend Backend, image resources.Image) (id string, err error) {
+	id, err = change.BuiltImage(image)
+	if err != nil || id == "" {
+		return
+	}
+	if backend.ImageId(id) == "" {
+		return "", nil
+	}
+	return
 }
 
-func buildDockerImage(binary string, image resources.Image, onlyIfChange bool, noCache bool, forcePull bool, errors chan error) {
-	d := display.Service()
-	logger := d.BufferedActionLogger("build", image.Name())
+// Make an unchanged image present locally by relabeling the image built from the same signature or by pulling it.
+// Returns false if the image is missing and could not be pulled so it must be built.
+func presentImage(ctx context.Context, backend Backend, log logger.ActionLogger, image resources.Image) (present bool, err error) {
+	if backend.ImageId(image.FullName()) != "" {
+		return true, nil
+	}
+	config, err := resources.MergedConfig(image)
+	if err != nil {
+		return
+	}
+	source, err := builtImage(backend, image)
+	if err != nil {
+		return
+	}
+	if source != "" {
+		opts, err := buildOptions(image, *config, false, false)
+		if err != nil {
+			return false, err
+		}
+		err = backend.RelabelImage(ctx, log, source, opts)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		log.Info("Image: %s is missing. Pulling it ...", image.Name())
+		pullErr := backend.PullImage(ctx, log, image.FullName())
+		if pullErr != nil {
+			log.Info("Unable to pull image: %s : %s", image.Name(), pullErr)
+			return false, nil
+		}
+		err = backend.TagImage(ctx, log, image.FullName(), imageTags(image, *config))
+		if err != nil {
+			return
+		}
+	}
+	change.StoreBuiltImage(image, backend.ImageId(image.FullName()))
+	return true, nil
+}
+
+// Check released versions of all images to build before building anything
+func checkImmutable(levels [][]resources.Image, onlyIfChange bool, forcePull bool, allowOverwrite bool) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	for _, level := range levels {
+		for _, image := range level {
+			build, _, err := mustBuild(image, onlyIfChange, forcePull)
+			if err != nil {
+				return err
+			}
+			if !build {
+				continue
+			}
+			err = change.CheckBuildImmutable(image, allowOverwrite)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return
+}
 
-	err := change.Init()
+// Build images level by level following their dependencies. Images of a same level are built in parallel.
+func (b ImagesBuilder) Build(ctx context.Context, pool concurrent.Options, onlyIfChange bool, noCache bool, forcePull bool, allowOverwrite bool) (err error) {
+	levels, backends, err := b.levels()
+	if err != nil {
+		return
+	}
+	err = checkImmutable(levels, onlyIfChange, forcePull, allowOverwrite)
 	if err != nil {
-		errors <- err
 		return
 	}
 
-	// If forcePull => force build
-	if !forcePull && onlyIfChange {
-		changed, _, err := change.DoesImageChanged(image)
+	for _, level := range levels {
+		err = buildLevel(ctx, pool, backends, level, onlyIfChange, noCache, forcePull)
 		if err != nil {
-			errors <- err
-		} else if !changed {
-			logger.Info("Image: %s did not changed. Do not build it.", image.Name())
 			return
 		}
 	}
+	return
+}
 
-	logger.Info("Building image: %s ...", image.Name())
+func (b ImagesBuilder) Plan(onlyIfChange bool, noCache bool, forcePull bool) (p plan.Plan, err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	levels, backends, err := b.levels()
+	if err != nil {
+		return
+	}
 
-	var buildParams []string
-	buildParams = append(buildParams, "build", "-t", image.FullName())
+	p = plan.Plan{}
+	for _, level := range levels {
+		for _, image := range level {
+			step := plan.Step{Phase: "build", Resource: image.QualifiedName(), Action: plan.NoneAction}
+			build, reason, err := mustBuild(image, onlyIfChange, forcePull)
+			if err != nil {
+				return nil, err
+			}
+			step.Reason = reason
+			if !build && backends[image.Name()].ImageId(image.FullName()) == "" {
+				source, err := builtImage(backends[image.Name()], image)
+				if err != nil {
+					return nil, err
+				}
+				if source != "" {
+					step.Action = plan.TagAction
+					step.Reason += ", reusing image " + source + " with new labels"
+				} else {
+					step.Action = plan.PullAction
+					step.Reason += ", image missing"
+				}
+			}
+			if build {
+				config, err := resources.MergedConfig(image)
+				if err != nil {
+					return nil, err
+				}
+				opts, err := buildOptions(image, *config, noCache, forcePull)
+				if err != nil {
+					return nil, err
+				}
+				line, err := backends[image.Name()].CommandLine(image, opts)
+				if err != nil {
+					return nil, err
+				}
+				step.Action = plan.BuildAction
+				step.Commands = append(step.Commands, line)
+				id, err := reusableImage(backends[image.Name()], image, onlyIfChange, noCache, forcePull)
+				if err != nil {
+					return nil, err
+				}
+				if id != "" {
+					step.Action = plan.TagAction
+					step.Reason += ", reusing image " + id + " with new labels"
+					step.Commands = nil
+				}
+			}
+			p = append(p, step)
+		}
+	}
+	return
+}
 
-	// Add --no-cache option
-	if noCache {
-		buildParams = append(buildParams, "--no-cache")
+func buildLevel(ctx context.Context, pool concurrent.Options, backends map[string]Backend, images []resources.Image, onlyIfChange bool, noCache bool, forcePull bool) (err error) {
+	builder := func(ctx context.Context, image resources.Image) (void interface{}, err error) {
+		err = buildImage(ctx, backends[image.Name()], image, onlyIfChange, noCache, forcePull)
+		return
 	}
+	_, err = concurrent.RunWaitingContext(ctx, pool, builder, images...)
+	return
+}
 
-	if forcePull {
-		buildParams = append(buildParams, "--pull")
+func buildImage(ctx context.Context, backend Backend, image resources.Image, onlyIfChange bool, noCache bool, forcePull bool) (err error) {
+	d := display.Service()
+	logger := d.BufferedActionLogger("build", image.Name())
+
+	err = change.Init()
+	if err != nil {
+		return
 	}
 
-	// Forge build-args
-	config, err := resources.MergedConfig(image)
+	build, _, err := mustBuild(image, onlyIfChange, forcePull)
 	if err != nil {
-		errors <- err
+		return
+	}
+	if !build {
+		present, err := presentImage(ctx, backend, logger, image)
+		if err != nil {
+			logger.Flush()
+			return fmt.Errorf("Error getting image %s : %w", image.Name(), err)
+		}
+		if present {
+			logger.Info("Image: %s did not changed. Do not build it.", image.Name())
+			return nil
+		}
 	}
 
-	for argKey, argValue := range config.BuildArgs {
-		var buildArg string = "--build-arg=" + argKey + "=" + argValue
-		buildParams = append(buildParams, buildArg)
+	// Forge build-args, labels and tags
+	config, err := resources.MergedConfig(image)
+	if err != nil {
+		return
+	}
+	opts, err := buildOptions(image, *config, noCache, forcePull)
+	if err != nil {
+		return
 	}
 
-	// Add dot folder as last param
-	buildParams = append(buildParams, ".")
+	id, err := reusableImage(backend, image, onlyIfChange, noCache, forcePull)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		logger.Info("Image: %s was already built from same sources. Relabeling image %s ...", image.Name(), id)
+		err = backend.RelabelImage(ctx, logger, id, opts)
+		if err != nil {
+			logger.Flush()
+			return fmt.Errorf("Error relabeling image %s : %w", image.Name(), err)
+		}
+		change.StoreImageSignature(image)
+		logger.Info("Relabel finished for image: %s .", image.Name())
+		return
+	}
 
-	logger.Debug("build params: %s", buildParams)
-	cmd := exec.Command(binary, buildParams...)
-	cmd.Dir = image.Dir()
+	logger.Info("Building image: %s ...", image.Name())
 
-	err = command.RunLogging(cmd, logger)
+	err = backend.BuildImage(ctx, logger, image, opts)
 	if err != nil {
 		logger.Flush()
-		err := fmt.Errorf("Error building image %s : %w", image.Name(), err)
-		errors <- err
+		return fmt.Errorf("Error building image %s : %w", image.Name(), err)
 	}
 
 	change.StoreImageSignature(image)
+	change.StoreBuiltImage(image, backend.ImageId(image.FullName()))
 
 	logger.Info("Build finished for image: %s .", image.Name())
+	return
 }
@@ -0,0 +1,188 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/test"
+)
+
+// Backend recording builds, tags, relabels and pulls of local images by reference
+type fakeBackend struct {
+	images    map[string]string
+	registry  map[string]string
+	builds    int
+	tagged    []string
+	relabeled []string
+	labels    map[string]map[string]string // Labels of relabeled images by id
+	pulled    []string
+}
+
+func (b *fakeBackend) BuildImage(ctx context.Context, log logger.ActionLogger, image resources.Image, opts BuildOptions) error {
+	b.builds++
+	for _, tag := range opts.Tags {
+		b.images[tag] = fmt.Sprintf("sha256:%d", b.builds)
+	}
+	return nil
+}
+
+func (b *fakeBackend) CommandLine(image resources.Image, opts BuildOptions) (string, error) {
+	return "fake build", nil
+}
+
+func (b *fakeBackend) ImageId(ref string) string {
+	for _, id := range b.images {
+		if id == ref {
+			return id
+		}
+	}
+	return b.images[ref]
+}
+
+func (b *fakeBackend) TagImage(ctx context.Context, log logger.ActionLogger, source string, tags []string) error {
+	id := b.ImageId(source)
+	for _, tag := range tags {
+		b.images[tag] = id
+		b.tagged = append(b.tagged, tag)
+	}
+	return nil
+}
+
+func (b *fakeBackend) RelabelImage(ctx context.Context, log logger.ActionLogger, source string, opts BuildOptions) error {
+	if b.ImageId(source) == "" {
+		return fmt.Errorf("image not found: %s", source)
+	}
+	if b.labels == nil {
+		b.labels = map[string]map[string]string{}
+	}
+	id := fmt.Sprintf("sha256:relabeled%d", len(b.labels)+1)
+	b.labels[id] = opts.Labels
+	for _, tag := range opts.Tags {
+		b.images[tag] = id
+		b.relabeled = append(b.relabeled, tag)
+	}
+	return nil
+}
+
+func (b *fakeBackend) PullImage(ctx context.Context, log logger.ActionLogger, ref string) error {
+	id, ok := b.registry[ref]
+	if !ok {
+		return fmt.Errorf("image not found: %s", ref)
+	}
+	b.images[ref] = id
+	b.pulled = append(b.pulled, ref)
+	return nil
+}
+
+// Share caches of a workspace in sharedPath.
+// Settings are rewritten because workspaces initialized by a same process inherit previous settings.
+func shareCaches(t *testing.T, wksPath, sharedPath string) {
+	settingsFile := filepath.Join(wksPath, ".mass", "settings.yaml")
+	content, err := os.ReadFile(settingsFile)
+	require.NoError(t, err, "should not error")
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &values)
+	require.NoError(t, err, "should not error")
+	values["sharedcaches"] = []map[string]string{{"backend": "dir", "path": sharedPath}}
+	content, err = yaml.Marshal(values)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(settingsFile, content, 0644)
+	require.NoError(t, err, "should not error")
+}
+
+func TestBuildReusesImageOfSameSignature(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+	backend := &fakeBackend{images: map[string]string{}}
+	ctx := context.Background()
+
+	err := buildImage(ctx, backend, image, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 1, backend.builds)
+	builtId := backend.images[image.FullName()]
+
+	_, _, err = image.Promote()
+	require.NoError(t, err, "should not error")
+	err = buildImage(ctx, backend, image, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 1, backend.builds, "promoted image should not be rebuilt")
+	assert.Contains(t, backend.relabeled, image.FullName())
+	relabeledId := backend.images[image.FullName()]
+	assert.NotEqual(t, builtId, relabeledId, "promoted image should be relabeled")
+	assert.Equal(t, image.Version(), backend.labels[relabeledId][VersionLabel], "relabeled image should have the promoted version label")
+
+	err = buildImage(ctx, backend, image, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 1, backend.builds, "unchanged image should not be rebuilt")
+
+	_, _, err = image.Release()
+	require.NoError(t, err, "should not error")
+	err = buildImage(ctx, backend, image, true, true, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 2, backend.builds, "image should be rebuilt without cache")
+
+	// Reused image must still exist locally
+	_, _, err = image.Bump(false, false)
+	require.NoError(t, err, "should not error")
+	backend.images = map[string]string{}
+	err = buildImage(ctx, backend, image, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 3, backend.builds, "removed image should be rebuilt")
+}
+
+func TestBuildSkipsImageBuiltByTeammate(t *testing.T) {
+	sharedPath, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(sharedPath)
+	registry := map[string]string{}
+	ctx := context.Background()
+
+	wksPath1 := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath1)
+	shareCaches(t, wksPath1, sharedPath)
+	image1 := initImage(t, wksPath1, "p1/i1", "FROM alpine\n")
+	backend1 := &fakeBackend{images: map[string]string{}, registry: registry}
+	err = buildImage(ctx, backend1, image1, true, false, false)
+	require.NoError(t, err, "should not error")
+	require.Equal(t, 1, backend1.builds)
+	builtId := backend1.images[image1.FullName()]
+	registry[image1.FullName()] = builtId
+
+	wksPath2 := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath2)
+	shareCaches(t, wksPath2, sharedPath)
+	image2 := initImage(t, wksPath2, "p1/i1", "FROM alpine\n")
+	require.Equal(t, image1.FullName(), image2.FullName())
+	backend2 := &fakeBackend{images: map[string]string{}, registry: registry}
+	err = buildImage(ctx, backend2, image2, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 0, backend2.builds, "image built by a teammate should not be rebuilt")
+	assert.Equal(t, []string{image2.FullName()}, backend2.pulled, "image built by a teammate should be pulled")
+	assert.Equal(t, builtId, backend2.images[image2.FullName()])
+
+	// Image built by a teammate on the same engine is tagged
+	backend3 := &fakeBackend{images: map[string]string{"foo": builtId}}
+	err = buildImage(ctx, backend3, image2, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 0, backend3.builds, "image built by a teammate should not be rebuilt")
+	assert.Empty(t, backend3.pulled, "image present on the engine should not be pulled")
+	assert.Contains(t, backend3.relabeled, image2.FullName())
+	assert.Equal(t, image2.AbsSourceDir(), backend3.labels[backend3.images[image2.FullName()]][SourceLabel], "image built by a teammate should be relabeled")
+
+	// Missing image which cannot be pulled is built
+	backend4 := &fakeBackend{images: map[string]string{}, registry: map[string]string{}}
+	err = buildImage(ctx, backend4, image2, true, false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, 1, backend4.builds, "missing image should be built")
+}
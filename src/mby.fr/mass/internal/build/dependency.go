@@ -0,0 +1,214 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/errorz"
+)
+
+type UnknownDependency struct {
+	Image      string
+	Dependency string
+}
+
+func (e UnknownDependency) Error() string {
+	return fmt.Sprintf("Image %s depends on unknown workspace image: %s", e.Image, e.Dependency)
+}
+
+type DependencyCycle struct {
+	Images []string
+}
+
+func (e DependencyCycle) Error() string {
+	return fmt.Sprintf("Dependency cycle detected between images: %s", strings.Join(e.Images, ", "))
+}
+
+// Return the repository part of an image reference (without tag nor digest).
+func imageRepository(ref string) string {
+	ref = strings.ToLower(ref)
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// Parse FROM instructions of a BuildFile and return referenced images.
+// References to previous build stages and to not resolved ARG are ignored.
+func ParseBuildFileFroms(path string) (refs []string, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer f.Close()
+
+	stages := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.ToUpper(fields[0]) != "FROM" {
+			continue
+		}
+		// Skip flags like --platform
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		ref := args[0]
+		previousStage := stages[strings.ToLower(ref)]
+		if len(args) >= 3 && strings.ToUpper(args[1]) == "AS" {
+			stages[strings.ToLower(args[2])] = true
+		}
+		if previousStage || strings.Contains(ref, "$") {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	err = scanner.Err()
+	return
+}
+
+// Return the workspace images an image depends on.
+// Dependencies are FROM references in the BuildFile matching a workspace image plus explicit dependsOn entries.
+// A reference prefixed by a workspace project name which does not match a workspace image is an error.
+func Dependencies(image resources.Image, workspaceImages []resources.Image) (deps []resources.Image, errors errorz.Aggregated) {
+	known := map[string]resources.Image{}
+	projects := map[string]bool{}
+	// Images may be referenced by their registry qualified repository
+	qualified := map[string]string{}
+	for _, i := range workspaceImages {
+		known[strings.ToLower(i.Name())] = i
+		projects[strings.ToLower(i.Project.Name())] = true
+		qualified[i.Repository()] = strings.ToLower(i.Name())
+	}
+
+	added := map[string]bool{}
+	addDep := func(name string) {
+		if added[name] {
+			return
+		}
+		added[name] = true
+		deps = append(deps, known[name])
+	}
+
+	refs, err := ParseBuildFileFroms(image.AbsBuildFile())
+	if err != nil {
+		errors.Add(err)
+		return
+	}
+	for _, ref := range refs {
+		repo := imageRepository(ref)
+		if _, ok := known[repo]; ok {
+			addDep(repo)
+		} else if name, ok := qualified[repo]; ok {
+			addDep(name)
+		} else if splitted := strings.Split(repo, "/"); len(splitted) == 2 && projects[splitted[0]] {
+			errors.Add(UnknownDependency{image.Name(), ref})
+		}
+	}
+
+	for _, dep := range image.DependsOn {
+		name := strings.ToLower(dep)
+		if !strings.Contains(name, "/") {
+			// Image of same project
+			name = strings.ToLower(image.Project.Name()) + "/" + name
+		}
+		if _, ok := known[name]; ok {
+			addDep(name)
+		} else {
+			errors.Add(UnknownDependency{image.Name(), dep})
+		}
+	}
+	return
+}
+
+// Order images in levels. Images of a level only depend on images of previous levels.
+// Dependencies outside of supplied images are checked but not returned.
+func Levels(images []resources.Image, workspaceImages []resources.Image) (levels [][]resources.Image, errors errorz.Aggregated) {
+	// Collect dependencies of images and of their transitive dependencies
+	graph := map[string][]string{}
+	byName := map[string]resources.Image{}
+	toVisit := append([]resources.Image{}, images...)
+	for len(toVisit) > 0 {
+		image := toVisit[0]
+		toVisit = toVisit[1:]
+		name := strings.ToLower(image.Name())
+		if _, ok := graph[name]; ok {
+			continue
+		}
+		byName[name] = image
+		deps, errs := Dependencies(image, workspaceImages)
+		errors.Concat(errs)
+		graph[name] = []string{}
+		for _, dep := range deps {
+			graph[name] = append(graph[name], strings.ToLower(dep.Name()))
+			toVisit = append(toVisit, dep)
+		}
+	}
+	if errors.GotError() {
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, image := range images {
+		wanted[strings.ToLower(image.Name())] = true
+	}
+
+	// Kahn algorithm level by level
+	done := map[string]bool{}
+	for len(done) < len(graph) {
+		var ready []string
+		for name, deps := range graph {
+			if done[name] {
+				continue
+			}
+			satisfied := true
+			for _, dep := range deps {
+				if !done[dep] {
+					satisfied = false
+					break
+				}
+			}
+			if satisfied {
+				ready = append(ready, name)
+			}
+		}
+
+		if len(ready) == 0 {
+			var cycle []string
+			for name := range graph {
+				if !done[name] {
+					cycle = append(cycle, byName[name].Name())
+				}
+			}
+			sort.Strings(cycle)
+			errors.Add(DependencyCycle{cycle})
+			return nil, errors
+		}
+
+		sort.Strings(ready)
+		var level []resources.Image
+		for _, name := range ready {
+			done[name] = true
+			if wanted[name] {
+				level = append(level, byName[name])
+			}
+		}
+		if len(level) > 0 {
+			levels = append(levels, level)
+		}
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/test"
+)
+
+func initImage(t *testing.T, wksPath, name, buildfileContent string, dependsOn ...string) resources.Image {
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, name))
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(image.AbsBuildFile(), []byte(buildfileContent), 0644)
+	require.NoError(t, err, "should not error")
+	image.DependsOn = dependsOn
+	return image
+}
+
+func imageNames(images []resources.Image) (names []string) {
+	for _, i := range images {
+		names = append(names, i.Name())
+	}
+	return
+}
+
+func TestParseBuildFileFroms(t *testing.T) {
+	tempDir, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "Dockerfile")
+	content := `
+FROM --platform=linux/amd64 golang:1.18 AS builder
+RUN echo "from inside"
+FROM builder AS tester
+from p1/base:1.2.0
+FROM $BASE_IMAGE
+FROM registry/ns/foo@sha256:abcd
+`
+	err = os.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err, "should not error")
+
+	refs, err := ParseBuildFileFroms(path)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"golang:1.18", "p1/base:1.2.0", "registry/ns/foo@sha256:abcd"}, refs)
+
+	refs, err = ParseBuildFileFroms(filepath.Join(tempDir, "notExisting"))
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, refs)
+}
+
+func TestImageRepository(t *testing.T) {
+	assert.Equal(t, "p1/base", imageRepository("p1/base:1.2.0"))
+	assert.Equal(t, "p1/base", imageRepository("P1/Base"))
+	assert.Equal(t, "localhost:5000/p1/base", imageRepository("localhost:5000/p1/base:1.0"))
+	assert.Equal(t, "p1/base", imageRepository("p1/base@sha256:abcd"))
+}
+
+func TestLevels(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	_, err = resources.Init[resources.Project](filepath.Join(wksPath, "p2"))
+	require.NoError(t, err, "should not error")
+
+	base := initImage(t, wksPath, "p1/base", "FROM alpine:3.16\n")
+	api := initImage(t, wksPath, "p1/api", "FROM p1/base:0.0.1-dev\n")
+	web := initImage(t, wksPath, "p2/web", "FROM alpine\n", "p1/api")
+	lib := initImage(t, wksPath, "p2/lib", "FROM alpine\n")
+	all := []resources.Image{web, lib, api, base}
+
+	levels, errors := Levels(all, all)
+	require.False(t, errors.GotError(), "should not error")
+	require.Len(t, levels, 3)
+	assert.Equal(t, []string{"p1/base", "p2/lib"}, imageNames(levels[0]))
+	assert.Equal(t, []string{"p1/api"}, imageNames(levels[1]))
+	assert.Equal(t, []string{"p2/web"}, imageNames(levels[2]))
+
+	// Dependencies not to build are not returned
+	levels, errors = Levels([]resources.Image{web, base}, all)
+	require.False(t, errors.GotError(), "should not error")
+	require.Len(t, levels, 2)
+	assert.Equal(t, []string{"p1/base"}, imageNames(levels[0]))
+	assert.Equal(t, []string{"p2/web"}, imageNames(levels[1]))
+}
+
+func TestLevelsErrors(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+
+	a := initImage(t, wksPath, "p1/a", "FROM p1/b\n")
+	b := initImage(t, wksPath, "p1/b", "FROM alpine\n", "c")
+	c := initImage(t, wksPath, "p1/c", "FROM p1/a:1.0\n")
+	all := []resources.Image{a, b, c}
+
+	levels, errors := Levels(all, all)
+	assert.Nil(t, levels)
+	require.True(t, errors.GotError(), "should error")
+	assert.Equal(t, DependencyCycle{[]string{"p1/a", "p1/b", "p1/c"}}, errors.Errors()[0])
+
+	unknown := initImage(t, wksPath, "p1/d", "FROM p1/notExisting:1.0\n", "missing")
+	all = append(all, unknown)
+	_, errors = Levels([]resources.Image{unknown}, all)
+	require.True(t, errors.GotError(), "should error")
+	assert.Len(t, errors.Errors(), 2)
+	assert.Contains(t, errors.Errors(), UnknownDependency{"p1/d", "p1/notExisting:1.0"})
+	assert.Contains(t, errors.Errors(), UnknownDependency{"p1/d", "missing"})
+}
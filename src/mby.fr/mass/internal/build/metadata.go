@@ -0,0 +1,129 @@
+package build
+
+import (
+	"strings"
+
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/git"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+)
+
+const (
+	VersionLabel   = "org.opencontainers.image.version"
+	SourceLabel    = "org.opencontainers.image.source"
+	RevisionLabel  = "org.opencontainers.image.revision"
+	TitleLabel     = "org.opencontainers.image.title"
+	WorkspaceLabel = "mass.workspace"
+	GitLabel       = "mass.git.revision"
+
+	// Build arg holding the short git revision
+	GitRevisionArg = "GIT_REVISION"
+)
+
+// Options passed to a backend to build an image
+type BuildOptions struct {
+	Tags      []string // First tag is the image FullName
+	BuildArgs map[string]string
+	Labels    map[string]string
+	NoCache   bool
+	ForcePull bool
+}
+
+// Git revision of an image dir or empty if not in a git repository
+func gitRevision(image resources.Image) (string, error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return "", err
+	}
+	return git.Revision(ss.Settings().Git.Backend, image.Dir()), nil
+}
+
+// Automatic OCI labels overridden by merged config labels
+func imageLabels(image resources.Image, conf config.Config) (labels map[string]string, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	labels = map[string]string{
+		TitleLabel:     image.Name(),
+		VersionLabel:   image.Version(),
+		SourceLabel:    image.AbsSourceDir(),
+		WorkspaceLabel: ss.Settings().Name,
+	}
+	revision, err := gitRevision(image)
+	if err != nil {
+		return
+	}
+	if revision != "" {
+		labels[RevisionLabel] = revision
+		labels[GitLabel] = git.ShortRevision(revision)
+	}
+	for k, v := range conf.Labels {
+		labels[k] = v
+	}
+	return
+}
+
+// Image FullName and LocalName followed by a tag for each config tag.
+// A tag containing a ':' or a '/' is a full image reference.
+func imageTags(image resources.Image, conf config.Config) (tags []string) {
+	tags = append(tags, image.FullName())
+	repository := image.Repository()
+	added := map[string]bool{image.FullName(): true}
+	// Keep a local tag to resolve other images FROM lines
+	if !added[image.LocalName()] {
+		added[image.LocalName()] = true
+		tags = append(tags, image.LocalName())
+	}
+
+	for _, k := range sortedKeys(conf.Tags) {
+		tag := conf.Tags[k]
+		if tag == "" {
+			continue
+		}
+		if !strings.ContainsAny(tag, ":/") {
+			tag = repository + ":" + tag
+		}
+		if !added[tag] {
+			added[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return
+}
+
+// Automatic build args overridden by merged config build args
+func imageBuildArgs(image resources.Image, conf config.Config) (args map[string]string, err error) {
+	args = map[string]string{}
+	revision, err := gitRevision(image)
+	if err != nil {
+		return
+	}
+	if revision != "" {
+		args[GitRevisionArg] = git.ShortRevision(revision)
+	}
+	for k, v := range conf.BuildArgs {
+		args[k] = v
+	}
+	return
+}
+
+func buildOptions(image resources.Image, conf config.Config, noCache bool, forcePull bool) (opts BuildOptions, err error) {
+	labels, err := imageLabels(image, conf)
+	if err != nil {
+		return
+	}
+	buildArgs, err := imageBuildArgs(image, conf)
+	if err != nil {
+		return
+	}
+	opts = BuildOptions{
+		Tags:      imageTags(image, conf),
+		BuildArgs: buildArgs,
+		Labels:    labels,
+		NoCache:   noCache,
+		ForcePull: forcePull,
+	}
+	return
+}
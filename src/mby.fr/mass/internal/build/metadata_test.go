@@ -0,0 +1,105 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+)
+
+func TestImageTags(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/I1", "FROM alpine\n")
+
+	conf := config.Config{Tags: config.TagsConfig{
+		"b":     "latest",
+		"a":     "stable",
+		"empty": "",
+		"full":  "registry:5000/p1/i1:1.0",
+		"dup":   "latest",
+	}}
+	tags := imageTags(image, conf)
+	assert.Equal(t, []string{image.FullName(), "p1/i1:stable", "p1/i1:latest", "registry:5000/p1/i1:1.0"}, tags)
+}
+
+func TestImageLabels(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+
+	conf := config.Config{Labels: config.LabelsConfig{"foo": "bar", VersionLabel: "overridden"}}
+	labels, err := imageLabels(image, conf)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "bar", labels["foo"])
+	assert.Equal(t, "overridden", labels[VersionLabel], "config labels should override automatic labels")
+	assert.Equal(t, image.Name(), labels[TitleLabel])
+	assert.Equal(t, image.AbsSourceDir(), labels[SourceLabel])
+	ss, err := settings.GetSettingsService()
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, ss.Settings().Name, labels[WorkspaceLabel])
+	assert.NotContains(t, labels, RevisionLabel, "no revision label outside a git repository")
+}
+
+func TestGitRevisionMetadata(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+
+	conf := config.Config{BuildArgs: config.BuildArgsConfig{"foo": "bar"}}
+	args, err := imageBuildArgs(image, conf)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, map[string]string{"foo": "bar"}, args, "no revision arg outside a git repository")
+
+	for _, gitArgs := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=tester", "-c", "user.email=tester@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", wksPath}, gitArgs...)...).CombinedOutput()
+		require.NoError(t, err, "should not error: %s", out)
+	}
+	out, err := exec.Command("git", "-C", wksPath, "rev-parse", "HEAD").Output()
+	require.NoError(t, err, "should not error")
+	revision := string(out[:40])
+
+	labels, err := imageLabels(image, conf)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, revision, labels[RevisionLabel])
+	assert.Equal(t, revision[:7], labels[GitLabel])
+
+	args, err = imageBuildArgs(image, conf)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, map[string]string{"foo": "bar", GitRevisionArg: revision[:7]}, args)
+
+	conf.BuildArgs[GitRevisionArg] = "overridden"
+	args, err = imageBuildArgs(image, conf)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "overridden", args[GitRevisionArg], "config build args should override automatic args")
+}
+
+func TestEnvTagsOverrideImageTags(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image := initImage(t, wksPath, "p1/i1", "FROM alpine\n")
+	writeConfig := func(dir, content string) {
+		err := os.WriteFile(filepath.Join(dir, config.DefaultConfigFile), []byte(content), 0644)
+		require.NoError(t, err, "should not error")
+	}
+	writeConfig(filepath.Join(wksPath, "envs", "dev"), "tags:\n  channel: dev\n")
+	writeConfig(filepath.Join(wksPath, "p1"), "tags:\n  channel: project\n  p: project\n")
+	writeConfig(image.Dir(), "tags:\n  channel: image\n  p: image\n  i: image\n")
+
+	image, err := resources.Read[resources.Image](image.Dir())
+	require.NoError(t, err, "should not error")
+	conf, err := resources.MergedConfig(image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, config.TagsConfig{"channel": "dev", "p": "image", "i": "image"}, conf.Tags)
+}
@@ -0,0 +1,31 @@
+package change
+
+import (
+	"mby.fr/mass/internal/resources"
+)
+
+const defaultBuiltCacheDir = "builtImages"
+
+// Record the id of the image built from the current signature of res.
+// Signatures do not depend on the version so other versions built from the same sources can reuse it.
+// Generated labels like the version are not part of the signature: reusing versions relabel the recorded image.
+func StoreBuiltImage(res resources.Image, imageId string) (err error) {
+	if imageId == "" {
+		return
+	}
+	signature, err := calcImageSignature(res)
+	if err != nil {
+		return
+	}
+	return builtCacheDir.StoreString(signature, imageId)
+}
+
+// Id of an image already built from the current signature of res. Empty if none was recorded.
+func BuiltImage(res resources.Image) (imageId string, err error) {
+	signature, err := calcImageSignature(res)
+	if err != nil {
+		return
+	}
+	imageId, _, err = builtCacheDir.LoadString(signature)
+	return
+}
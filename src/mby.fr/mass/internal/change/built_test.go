@@ -0,0 +1,34 @@
+package change
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltImage(t *testing.T) {
+	r := initImmutableImage(t)
+
+	id, err := BuiltImage(r)
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, id, "image should not be built yet")
+
+	err = StoreBuiltImage(r, "sha256:1234")
+	require.NoError(t, err, "should not error")
+
+	// Promoted version with same sources reuses the built image
+	_, _, err = r.Promote()
+	require.NoError(t, err, "should not error")
+	id, err = BuiltImage(r)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "sha256:1234", id)
+
+	err = os.WriteFile(filepath.Join(r.AbsSourceDir(), "srcFile"), []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+	id, err = BuiltImage(r)
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, id, "changed sources should not reuse the built image")
+}
@@ -1,10 +1,12 @@
 package change
 
 import (
+	"encoding/hex"
 	"io/fs"
 	"path/filepath"
 	"strings"
 
+	"mby.fr/mass/internal/display"
 	"mby.fr/mass/internal/resources"
 	"mby.fr/mass/internal/settings"
 	"mby.fr/utils/cache"
@@ -16,29 +18,66 @@ const defaultDeployCacheDir = "deploySignatures"
 
 var imageCacheDir cache.Cache
 var deployCacheDir cache.Cache
+var testCacheDir cache.Cache
+var pushCacheDir cache.Cache
+var builtCacheDir cache.Cache
 
+// Cache dir of the workspace the caches were initialized for
+var initializedCacheDir string
+
+// Local cache of a cache dir, read through and written back to the shared caches of the settings if shared
+func newCache(ss *settings.SettingsService, dir string, shared bool) (c cache.Cache, err error) {
+	c, err = cache.NewPersistentCache(filepath.Join(ss.CacheDir(), dir))
+	if err != nil || !shared {
+		return
+	}
+	sharedCaches, err := ss.SharedCaches(dir)
+	if err != nil {
+		return
+	}
+	onError := func(err error) {
+		display.Service().Warn("Shared cache unavailable: %s", err)
+	}
+	return cache.NewTieredCache(c, onError, sharedCaches...), nil
+}
+
+// Deploy signatures describe the local engine state so they are never shared.
+// Image signatures, test results, push signatures and built images ids are shared with the team.
+// A shared image signature does not mean the image is present locally, the builder must check it.
 func Init() (err error) {
-	if imageCacheDir != nil && deployCacheDir != nil {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
 		return
 	}
+	if initializedCacheDir == ss.CacheDir() {
+		return
+	}
+	initializedCacheDir = ""
 
 	// Initializes caches
-	ss, e := settings.GetSettingsService()
+
+	imageCacheDir, err = newCache(ss, defaultImageCacheDir, true)
 	if err != nil {
-		return e
+		return
 	}
-	imageSignaturesCacheDir := filepath.Join(ss.CacheDir(), defaultImageCacheDir)
-	deploySignaturesCacheDir := filepath.Join(ss.CacheDir(), defaultDeployCacheDir)
-
-	imageCacheDir, err = cache.NewPersistentCache(imageSignaturesCacheDir)
+	deployCacheDir, err = newCache(ss, defaultDeployCacheDir, false)
 	if err != nil {
 		return
 	}
-	deployCacheDir, err = cache.NewPersistentCache(deploySignaturesCacheDir)
+	testCacheDir, err = newCache(ss, defaultTestCacheDir, true)
+	if err != nil {
+		return
+	}
+	pushCacheDir, err = newCache(ss, defaultPushCacheDir, true)
+	if err != nil {
+		return
+	}
+	builtCacheDir, err = newCache(ss, defaultBuiltCacheDir, true)
 	if err != nil {
 		return
 	}
 
+	initializedCacheDir = ss.CacheDir()
 	return
 }
 
@@ -54,7 +93,11 @@ func fileTree(rootPath string) (tree string, err error) {
 		} else {
 			entry += "-"
 		}
-		entry += path
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		entry += relPath
 		files = append(files, entry)
 		return nil
 	}
@@ -66,9 +109,13 @@ func fileTree(rootPath string) (tree string, err error) {
 	return
 }
 
+// Sources are signed relative to the image so the signature does not depend on the workspace location.
 func calcImageSignature(res resources.Image) (signature string, err error) {
-	filesToSign := []string{res.BuildFile, res.AbsSourceDir()}
-	filesSignature, err := trust.SignFsContents(filesToSign...)
+	buildFileSignature, err := trust.SignNamedFileContent(res.BuildFile, res.AbsBuildFile())
+	if err != nil {
+		return "", err
+	}
+	sourcesSignature, err := trust.SignDirContent(res.AbsSourceDir())
 	if err != nil {
 		return "", err
 	}
@@ -82,11 +129,20 @@ func calcImageSignature(res resources.Image) (signature string, err error) {
 		return "", err
 	}
 
-	signature, err = trust.SignObjects(configs.BuildArgs, filesSignature, fileTree)
+	signature, err = trust.SignObjects(configs.BuildArgs, configs.Labels, configs.Tags, buildFileSignature, sourcesSignature, fileTree)
 
 	return
 }
 
+// Hex encoded image signature, raw signatures are not valid JSON nor YAML strings
+func ImageSignature(res resources.Image) (signature string, err error) {
+	raw, err := calcImageSignature(res)
+	if err != nil {
+		return
+	}
+	return hex.EncodeToString([]byte(raw)), nil
+}
+
 func imageCacheKey(res resources.Image) (signature string) {
 	return res.FullName()
 }
@@ -129,25 +185,54 @@ func DoesImageChanged(res resources.Image) (changed bool, sign string, err error
 	return
 }
 
-func calcDeploySignature(res resources.Image) (signature string, err error) {
-	// TODO add volumes in signature
+// Deploy signature of an image or a project over working env, environment, run args, volumes,
+// DeployFile content and the digest of deployed image(s).
+func calcDeploySignature(res resources.Resourcer, digest string) (signature string, err error) {
 	filesToSign := []string{}
+	if p, ok := res.(resources.Project); ok {
+		filesToSign = append(filesToSign, p.AbsDeployFile())
+	}
 	filesSignature, err := trust.SignFsContents(filesToSign...)
+	if err != nil {
+		return "", err
+	}
 
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return "", err
+	}
+	env, err := ss.WorkingEnv()
+	if err != nil {
+		return "", err
+	}
 	configs, err := resources.MergedConfig(res)
 	if err != nil {
 		return "", err
 	}
-	signature, err = trust.SignObjects(filesSignature, configs.Environment, configs.RunArgs)
+	signature, err = trust.SignObjects(env, filesSignature, configs.Environment, configs.RunArgs, configs.Volumes, digest)
 
 	return
 }
 
-func deployCacheKey(res resources.Image) (signature string) {
-	return res.FullName()
+func deployCacheKey(res resources.Resourcer) (key string) {
+	if i, ok := res.(resources.Image); ok {
+		return i.FullName()
+	}
+	return res.QualifiedName()
 }
 
-func loadDeploySignature(res resources.Image) (signature string, err error) {
+// Accept resources pointers
+func derefResource(res resources.Resourcer) resources.Resourcer {
+	switch r := res.(type) {
+	case *resources.Project:
+		return *r
+	case *resources.Image:
+		return *r
+	}
+	return res
+}
+
+func loadDeploySignature(res resources.Resourcer) (signature string, err error) {
 	key := deployCacheKey(res)
 	value, ok, e := deployCacheDir.LoadString(key)
 	if e != nil {
@@ -159,8 +244,9 @@ func loadDeploySignature(res resources.Image) (signature string, err error) {
 	return
 }
 
-func StoreDeploySignature(res resources.Image) (err error) {
-	signature, e := calcDeploySignature(res)
+func StoreDeploySignature(res resources.Resourcer, digest string) (err error) {
+	res = derefResource(res)
+	signature, e := calcDeploySignature(res, digest)
 	if e != nil {
 		return e
 	}
@@ -169,14 +255,23 @@ func StoreDeploySignature(res resources.Image) (err error) {
 	return
 }
 
-func DoesDeployChanged(res resources.Image) (test bool, err error) {
+// Forget deploy signature of an undeployed resource so next deploy is not skipped
+func ForgetDeploySignature(res resources.Resourcer) (err error) {
+	res = derefResource(res)
+	key := deployCacheKey(res)
+	err = deployCacheDir.Delete(key)
+	return
+}
+
+func DoesDeployChanged(res resources.Resourcer, digest string) (test bool, err error) {
+	res = derefResource(res)
 	// Return true if found deploy changed
 	previousSignature, e1 := loadDeploySignature(res)
 	if e1 != nil {
 		return false, e1
 	}
 
-	actualSignature, e2 := calcDeploySignature(res)
+	actualSignature, e2 := calcDeploySignature(res, digest)
 	if e2 != nil {
 		return false, e2
 	}
@@ -130,6 +130,60 @@ func TestCalcImageSignature(t *testing.T) {
 	require.NoError(t, err, "should not error")
 	assert.NotEmpty(t, signature3, "empty image signature")
 	assert.Equal(t, signature3, signature5, "two signatures should be identical adding test file")
+
+	// Change labels shoud change signature
+	configFile := filepath.Join(r.Dir(), "config.yaml")
+	err = os.WriteFile(configFile, []byte("labels:\n  foo: bar\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	signature6, err := calcImageSignature(r)
+	require.NoError(t, err, "should not error")
+	assert.NotEqual(t, signature5, signature6, "two signatures should differ changing labels")
+
+	// Change tags shoud change signature
+	err = os.WriteFile(configFile, []byte("labels:\n  foo: bar\ntags:\n  latest: latest\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	signature7, err := calcImageSignature(r)
+	require.NoError(t, err, "should not error")
+	assert.NotEqual(t, signature6, signature7, "two signatures should differ changing tags")
+}
+
+func TestCalcImageSignatureFromOtherDir(t *testing.T) {
+	path, err := test.BuildRandTempPath()
+	defer os.RemoveAll(path)
+	require.NoError(t, err, "should not error")
+
+	// Init Settings for templates to work
+	err = settings.Init(path)
+	require.NoError(t, err, "should not error")
+	os.Chdir(path)
+
+	r, err := resources.Init[resources.Image](path)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(r.AbsBuildFile(), []byte("FROM alpine\n"), 0644)
+	require.NoError(t, err, "should not error")
+	signature1, err := calcImageSignature(r)
+	require.NoError(t, err, "should not error")
+
+	// Signature should not depend on the working directory
+	os.Chdir(r.AbsSourceDir())
+	signature2, err := calcImageSignature(r)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, signature1, signature2, "signature should not change from another directory")
+
+	// Nor on where the image is checked out
+	otherPath := filepath.Join(t.TempDir(), "checkout")
+	err = settings.Init(otherPath)
+	require.NoError(t, err, "should not error")
+	os.Chdir(otherPath)
+	other, err := resources.Init[resources.Image](otherPath)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(other.AbsBuildFile(), []byte("FROM alpine\n"), 0644)
+	require.NoError(t, err, "should not error")
+	signature3, err := calcImageSignature(other)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, signature1, signature3, "signature should not change with the image checkout path")
 }
 
 func TestDoesImageChanged(t *testing.T) {
@@ -170,3 +224,123 @@ func TestDoesImageChanged(t *testing.T) {
 	assert.NotEqual(t, sign1, sign3, "signature should be changed")
 	assert.True(t, test, "image should be changed")
 }
+
+func TestDoesDeployChanged(t *testing.T) {
+	path, err := test.BuildRandTempPath()
+	defer os.RemoveAll(path)
+	require.NoError(t, err, "should not error")
+
+	// Init Settings for templates to work
+	err = settings.Init(path)
+	require.NoError(t, err, "should not error")
+	os.Chdir(path)
+
+	err = Init()
+	require.NoError(t, err, "should not error")
+
+	r, err := resources.Init[resources.Image](path)
+	require.NoError(t, err, "should not error")
+
+	test, err := DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.True(t, test, "never deployed image should be changed")
+
+	err = StoreDeploySignature(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+
+	test, err = DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.False(t, test, "deploy should not be changed")
+
+	// Change image digest shoud change deploy
+	test, err = DoesDeployChanged(r, "sha256:2")
+	require.NoError(t, err, "should not error")
+	assert.True(t, test, "deploy should be changed with another digest")
+
+	// Change source file shoud not change deploy
+	srcFile := filepath.Join(r.AbsSourceDir(), "srcFile")
+	err = os.WriteFile(srcFile, []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+
+	test, err = DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.False(t, test, "deploy should not be changed by source file")
+
+	// Change environment shoud change deploy
+	configFile := filepath.Join(r.Dir(), "config.yaml")
+	err = os.WriteFile(configFile, []byte("environment:\n  FOO: bar\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	test, err = DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.True(t, test, "deploy should be changed by environment")
+
+	err = StoreDeploySignature(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+
+	// Change volumes shoud change deploy
+	err = os.WriteFile(configFile, []byte("environment:\n  FOO: bar\nvolumes:\n  - /tmp:/data\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	test, err = DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.True(t, test, "deploy should be changed by volumes")
+
+	err = StoreDeploySignature(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+
+	// Forget signature shoud change deploy
+	err = ForgetDeploySignature(r)
+	require.NoError(t, err, "should not error")
+
+	test, err = DoesDeployChanged(r, "sha256:1")
+	require.NoError(t, err, "should not error")
+	assert.True(t, test, "forgotten deploy should be changed")
+}
+
+func TestRecordTest(t *testing.T) {
+	path, err := test.BuildRandTempPath()
+	defer os.RemoveAll(path)
+	require.NoError(t, err, "should not error")
+
+	// Init Settings for templates to work
+	err = settings.Init(path)
+	require.NoError(t, err, "should not error")
+	os.Chdir(path)
+
+	err = Init()
+	require.NoError(t, err, "should not error")
+
+	r, err := resources.Init[resources.Image](path)
+	require.NoError(t, err, "should not error")
+
+	records, err := TestHistory(r)
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, records, "history should be empty")
+	err = CheckTested(r)
+	assert.ErrorIs(t, err, NoPassingTest, "never tested image should not be tested")
+
+	err = RecordTest(r, TestRecord{Status: "failed", Tests: 2, Failures: 1})
+	require.NoError(t, err, "should not error")
+	err = CheckTested(r)
+	assert.ErrorIs(t, err, NoPassingTest, "failed test should not pass")
+
+	err = RecordTest(r, TestRecord{Status: "passed", Passed: true, Tests: 2})
+	require.NoError(t, err, "should not error")
+	err = CheckTested(r)
+	assert.NoError(t, err, "passed test should pass")
+
+	records, err = TestHistory(r)
+	require.NoError(t, err, "should not error")
+	require.Len(t, records, 2)
+	assert.Equal(t, "failed", records[0].Status, "history should be ordered")
+	assert.Equal(t, r.FullName(), records[1].FullName)
+	assert.NotEmpty(t, records[1].Signature)
+
+	// Change source file shoud require new tests
+	srcFile := filepath.Join(r.AbsSourceDir(), "srcFile")
+	err = os.WriteFile(srcFile, []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+	err = CheckTested(r)
+	assert.ErrorIs(t, err, NoPassingTest, "changed image should not be tested")
+}
@@ -0,0 +1,83 @@
+package change
+
+import (
+	"fmt"
+
+	"mby.fr/mass/internal/resources"
+)
+
+const defaultPushCacheDir = "pushSignatures"
+
+var ImmutableVersion error = fmt.Errorf("Released version is immutable")
+
+func immutableError(res resources.Image, action string) error {
+	return fmt.Errorf("%w: %s was already %s from other sources, bump its version with mass bump or use --allow-overwrite", ImmutableVersion, res.FullName(), action)
+}
+
+// Immutable versions cannot be rebuilt with a signature differing from the recorded one unless allowOverwrite
+func CheckBuildImmutable(res resources.Image, allowOverwrite bool) (err error) {
+	if allowOverwrite {
+		return
+	}
+	immutable, err := res.IsImmutable()
+	if err != nil || !immutable {
+		return
+	}
+	recorded, err := loadImageSignature(res)
+	if err != nil || recorded == "" {
+		return
+	}
+	actual, err := calcImageSignature(res)
+	if err != nil {
+		return
+	}
+	if actual != recorded {
+		err = immutableError(res, "built")
+	}
+	return
+}
+
+// Signature of the local image: the one recorded at build or the working tree one if never built
+func builtSignature(res resources.Image) (signature string, err error) {
+	signature, err = loadImageSignature(res)
+	if err != nil || signature != "" {
+		return
+	}
+	return calcImageSignature(res)
+}
+
+// Immutable versions cannot be pushed again from a build differing from the pushed one unless allowOverwrite
+func CheckPushImmutable(res resources.Image, allowOverwrite bool) (err error) {
+	if allowOverwrite {
+		return
+	}
+	immutable, err := res.IsImmutable()
+	if err != nil || !immutable {
+		return
+	}
+	pushed, _, err := pushCacheDir.LoadString(imageCacheKey(res))
+	if err != nil || pushed == "" {
+		return
+	}
+	built, err := builtSignature(res)
+	if err != nil {
+		return
+	}
+	if built != pushed {
+		err = immutableError(res, "pushed")
+	}
+	return
+}
+
+// Record the signature of a pushed immutable version
+func StorePushSignature(res resources.Image) (err error) {
+	immutable, err := res.IsImmutable()
+	if err != nil || !immutable {
+		return
+	}
+	signature, err := builtSignature(res)
+	if err != nil {
+		return
+	}
+	return pushCacheDir.StoreString(imageCacheKey(res), signature)
+}
@@ -0,0 +1,114 @@
+package change
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/test"
+)
+
+func initImmutableImage(t *testing.T) resources.Image {
+	path, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	t.Cleanup(func() { os.RemoveAll(path) })
+
+	err = settings.Init(path)
+	require.NoError(t, err, "should not error")
+	os.Chdir(path)
+	err = Init()
+	require.NoError(t, err, "should not error")
+
+	r, err := resources.Init[resources.Image](path)
+	require.NoError(t, err, "should not error")
+	return r
+}
+
+func TestCheckBuildImmutable(t *testing.T) {
+	r := initImmutableImage(t)
+	srcFile := filepath.Join(r.AbsSourceDir(), "srcFile")
+
+	// Dev versions are mutable
+	err := StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(srcFile, []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+	err = CheckBuildImmutable(r, false)
+	assert.NoError(t, err, "dev version should be rebuilt")
+
+	r.Ver = "1.0.0"
+	err = CheckBuildImmutable(r, false)
+	assert.NoError(t, err, "never built released version should be built")
+	err = StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = CheckBuildImmutable(r, false)
+	assert.NoError(t, err, "unchanged released version should be rebuilt")
+
+	err = os.WriteFile(srcFile, []byte("bar"), 0644)
+	require.NoError(t, err, "should not error")
+	err = CheckBuildImmutable(r, false)
+	assert.True(t, errors.Is(err, ImmutableVersion), "changed released version should not be rebuilt")
+	assert.Contains(t, err.Error(), "mass bump")
+	err = CheckBuildImmutable(r, true)
+	assert.NoError(t, err, "overwrite should be allowed")
+}
+
+func TestCheckBuildImmutableChannelVersion(t *testing.T) {
+	r := initImmutableImage(t)
+	settingsFile, err := os.OpenFile(filepath.Join(r.Dir(), ".mass", "settings.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err, "should not error")
+	_, err = settingsFile.WriteString("versioning:\n  images:\n    " + r.Name() + ":\n      scheme: semver-channels\n")
+	settingsFile.Close()
+	require.NoError(t, err, "should not error")
+	srcFile := filepath.Join(r.AbsSourceDir(), "srcFile")
+
+	r.Ver = "1.0.0-alpha.1"
+	err = StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(srcFile, []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+	err = CheckBuildImmutable(r, false)
+	assert.True(t, errors.Is(err, ImmutableVersion), "changed channel version should not be rebuilt")
+
+	r.Ver = "1.0.0-rc.1"
+	err = StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(srcFile, []byte("bar"), 0644)
+	require.NoError(t, err, "should not error")
+	err = CheckBuildImmutable(r, false)
+	assert.NoError(t, err, "release candidate should be rebuilt")
+}
+
+func TestCheckPushImmutable(t *testing.T) {
+	r := initImmutableImage(t)
+	r.Ver = "2.0.0"
+
+	err := CheckPushImmutable(r, false)
+	assert.NoError(t, err, "never pushed released version should be pushed")
+	err = StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = StorePushSignature(r)
+	require.NoError(t, err, "should not error")
+	err = CheckPushImmutable(r, false)
+	assert.NoError(t, err, "same build should be pushed again")
+
+	// Rebuild from other sources
+	err = os.WriteFile(filepath.Join(r.AbsSourceDir(), "srcFile"), []byte("foo"), 0644)
+	require.NoError(t, err, "should not error")
+	err = StoreImageSignature(r)
+	require.NoError(t, err, "should not error")
+	err = CheckPushImmutable(r, false)
+	assert.True(t, errors.Is(err, ImmutableVersion), "other build should not be pushed")
+	err = CheckPushImmutable(r, true)
+	assert.NoError(t, err, "overwrite should be allowed")
+
+	r.Ver = "2.0.1-rc1"
+	err = CheckPushImmutable(r, false)
+	assert.NoError(t, err, "pre-release should be pushed")
+}
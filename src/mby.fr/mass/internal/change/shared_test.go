@@ -0,0 +1,71 @@
+package change
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/cache"
+	"mby.fr/utils/test"
+)
+
+func TestSharedCaches(t *testing.T) {
+	path, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(path)
+	err = settings.Init(path)
+	require.NoError(t, err, "should not error")
+	os.Chdir(path)
+
+	serverDir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(serverDir)
+	served, err := cache.NewPersistentCache(serverDir)
+	require.NoError(t, err, "should not error")
+	server := httptest.NewServer(cache.NewHttpHandler(served))
+	defer server.Close()
+
+	settingsFile := filepath.Join(path, ".mass", "settings.yaml")
+	f, err := os.OpenFile(settingsFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err, "should not error")
+	_, err = f.WriteString("sharedcaches:\n  - backend: dir\n    path: shared\n  - backend: http\n    url: " + server.URL + "\n    timeout: 2s\n")
+	require.NoError(t, err, "should not error")
+	f.Close()
+
+	ss, err := settings.GetSettingsService()
+	require.NoError(t, err, "should not error")
+	sharedCaches, err := ss.SharedCaches(defaultPushCacheDir)
+	require.NoError(t, err, "should not error")
+	require.Len(t, sharedCaches, 2)
+
+	c, err := newCache(ss, defaultPushCacheDir, true)
+	require.NoError(t, err, "should not error")
+	err = c.StoreString("p1/i1:1.0.0", "signature")
+	require.NoError(t, err, "should not error")
+	for _, shared := range sharedCaches {
+		value, ok, err := shared.LoadString("p1/i1:1.0.0")
+		require.NoError(t, err, "should not error")
+		assert.True(t, ok, "value should be written back")
+		assert.Equal(t, "signature", value)
+	}
+	assert.DirExists(t, filepath.Join(path, "shared", defaultPushCacheDir))
+
+	local, err := newCache(ss, defaultDeployCacheDir, false)
+	require.NoError(t, err, "should not error")
+	err = local.StoreString("p1/i1:1.0.0", "signature")
+	require.NoError(t, err, "should not error")
+	assert.NoDirExists(t, filepath.Join(path, "shared", defaultDeployCacheDir), "deploy signatures should not be shared")
+
+	err = os.WriteFile(settingsFile, []byte("sharedcaches:\n  - backend: foo\n"), 0644)
+	require.NoError(t, err, "should not error")
+	ss, err = settings.GetSettingsService()
+	require.NoError(t, err, "should not error")
+	_, err = newCache(ss, defaultPushCacheDir, true)
+	assert.True(t, errors.Is(err, settings.UnknownCache), "should error")
+}
@@ -0,0 +1,90 @@
+package change
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"mby.fr/mass/internal/resources"
+)
+
+const defaultTestCacheDir = "testResults"
+
+// Oldest records are dropped beyond this count
+const maxTestHistory = 100
+
+var NoPassingTest error = fmt.Errorf("No passing test record")
+
+// Outcome of a mass test run for an image version and signature
+type TestRecord struct {
+	FullName  string    `json:"fullName"`
+	Signature string    `json:"signature"`
+	Status    string    `json:"status"`
+	Passed    bool      `json:"passed"`
+	Tests     int       `json:"tests"`
+	Failures  int       `json:"failures"`
+	Duration  float64   `json:"duration"` // In seconds
+	Date      time.Time `json:"date"`
+}
+
+// History is kept for all versions of an image
+func testCacheKey(res resources.Image) string {
+	return res.QualifiedName()
+}
+
+// Test records of an image from the oldest to the most recent
+func TestHistory(res resources.Image) (records []TestRecord, err error) {
+	value, ok, err := testCacheDir.LoadString(testCacheKey(res))
+	if err != nil || !ok {
+		return
+	}
+	err = json.Unmarshal([]byte(value), &records)
+	return
+}
+
+// Record a test outcome for the current image version and signature
+func RecordTest(res resources.Image, record TestRecord) (err error) {
+	signature, err := ImageSignature(res)
+	if err != nil {
+		return
+	}
+	record.FullName = res.FullName()
+	record.Signature = signature
+
+	records, err := TestHistory(res)
+	if err != nil {
+		return
+	}
+	records = append(records, record)
+	if len(records) > maxTestHistory {
+		records = records[len(records)-maxTestHistory:]
+	}
+	content, err := json.Marshal(records)
+	if err != nil {
+		return
+	}
+	err = testCacheDir.StoreString(testCacheKey(res), string(content))
+	return
+}
+
+// Return an error unless the most recent test of the current image version and signature passed
+func CheckTested(res resources.Image) (err error) {
+	signature, err := ImageSignature(res)
+	if err != nil {
+		return
+	}
+	records, err := TestHistory(res)
+	if err != nil {
+		return
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.FullName == res.FullName() && r.Signature == signature {
+			if r.Passed {
+				return nil
+			}
+			break
+		}
+	}
+	return fmt.Errorf("%w for %s", NoPassingTest, res.FullName())
+}
@@ -0,0 +1,296 @@
+package changelog
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"mby.fr/mass/internal/git"
+	"mby.fr/mass/internal/resources"
+)
+
+const (
+	DefaultChangelogFile    = "CHANGELOG.md"
+	DefaultReleaseNotesFile = "RELEASE_NOTES.md"
+
+	changelogHeader = "# Changelog\n"
+	breakingType    = "breaking"
+	otherType       = "other"
+	dateLayout      = "2006-01-02"
+)
+
+// Version bump level inferred from commits
+type Level int
+
+const (
+	NoBump Level = iota
+	PatchBump
+	MinorBump
+	MajorBump
+)
+
+func (l Level) String() string {
+	switch l {
+	case PatchBump:
+		return "patch"
+	case MinorBump:
+		return "minor"
+	case MajorBump:
+		return "major"
+	}
+	return "none"
+}
+
+// Conventional commit subject: type(scope)!: description
+var conventionalRegExp = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+var breakingFooterRegExp = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+
+// Sections of a release in display order. Unlisted types are grouped in other changes.
+var sections = []struct {
+	Type  string
+	Title string
+}{
+	{breakingType, "Breaking Changes"},
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{otherType, "Other Changes"},
+}
+
+// A commit parsed as a conventional commit. Non conventional commits are of type other.
+type Entry struct {
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+	Hash        string
+}
+
+func Parse(c git.Commit) (e Entry) {
+	e.Hash = c.Hash
+	e.Type = otherType
+	e.Description = c.Subject
+	if submatches := conventionalRegExp.FindStringSubmatch(c.Subject); submatches != nil {
+		e.Type = strings.ToLower(submatches[1])
+		e.Scope = submatches[2]
+		e.Breaking = submatches[3] == "!"
+		e.Description = submatches[4]
+	}
+	e.Breaking = e.Breaking || breakingFooterRegExp.MatchString(c.Body)
+	return
+}
+
+func (e Entry) section() string {
+	if e.Breaking {
+		return breakingType
+	}
+	for _, s := range sections {
+		if s.Type == e.Type {
+			return e.Type
+		}
+	}
+	return otherType
+}
+
+func (e Entry) markdown() string {
+	line := "- "
+	if e.Scope != "" {
+		line += "**" + e.Scope + ":** "
+	}
+	line += e.Description
+	if e.Hash != "" {
+		line += " (" + git.ShortRevision(e.Hash) + ")"
+	}
+	return line + "\n"
+}
+
+// Changes of a resource since its previous release
+type Release struct {
+	Name     string // Resource name
+	Version  string // Version being released
+	Previous string // Previous release version or empty if never released
+	Date     time.Time
+	Entries  []Entry // Newest first
+}
+
+// Breaking changes bump major, features bump minor and any other change bumps patch
+func (r Release) Level() (l Level) {
+	for _, e := range r.Entries {
+		switch {
+		case e.Breaking:
+			return MajorBump
+		case e.Type == "feat":
+			l = MinorBump
+		case l == NoBump:
+			l = PatchBump
+		}
+	}
+	return
+}
+
+// Markdown section titled title with a sub section by change type
+func (r Release) Markdown(title string) string {
+	builder := strings.Builder{}
+	builder.WriteString("## " + title + "\n\n")
+	if len(r.Entries) == 0 {
+		builder.WriteString("No changes.\n")
+		return builder.String()
+	}
+	first := true
+	for _, s := range sections {
+		var lines []string
+		for _, e := range r.Entries {
+			if e.section() == s.Type {
+				lines = append(lines, e.markdown())
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if !first {
+			builder.WriteString("\n")
+		}
+		first = false
+		builder.WriteString("### " + s.Title + "\n\n")
+		builder.WriteString(strings.Join(lines, ""))
+	}
+	return builder.String()
+}
+
+// Title of the release section in a changelog
+func (r Release) changelogTitle() string {
+	return r.Version + " - " + r.Date.Format(dateLayout)
+}
+
+// Released versions of tags starting with prefix, highest first
+func releasedVersions(tags []string, prefix string) (versions []*semver.Version) {
+	for _, tag := range tags {
+		v, err := semver.NewVersion(strings.TrimPrefix(tag, prefix))
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(versions)))
+	return
+}
+
+// Previous release tag and version of an image, empty if never released
+func PreviousRelease(repo git.Repository, image resources.Image) (tag, version string, err error) {
+	prefix := git.ReleaseTagPrefix(image.Name())
+	tags, err := repo.Tags(prefix)
+	if err != nil {
+		return
+	}
+	versions := releasedVersions(tags, prefix)
+	if len(versions) == 0 {
+		return
+	}
+	return prefix + versions[0].Original(), versions[0].Original(), nil
+}
+
+// Version an image is about to release: its version without pre-release nor metadata
+func releasing(image resources.Image) (string, error) {
+	v, err := semver.NewVersion(image.Version())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()), nil
+}
+
+// Commits touching the image source dir, build file and test dir since its previous release
+func Collect(repo git.Repository, image resources.Image) (r Release, err error) {
+	r.Name = image.Name()
+	r.Date = time.Now()
+	r.Version, err = releasing(image)
+	if err != nil {
+		return
+	}
+	tag, previous, err := PreviousRelease(repo, image)
+	if err != nil {
+		return
+	}
+	r.Previous = previous
+	commits, err := repo.Log(tag, image.AbsSourceDir(), image.AbsBuildFile(), image.AbsTestDir())
+	if err != nil {
+		return
+	}
+	for _, c := range commits {
+		r.Entries = append(r.Entries, Parse(c))
+	}
+	return
+}
+
+// Version is already bumped for level if current is a development version above previous accordingly
+func AlreadyBumped(previous, current string, level Level) (bool, error) {
+	cur, err := semver.NewVersion(current)
+	if err != nil {
+		return false, err
+	}
+	if cur.Prerelease() == "" {
+		return false, nil
+	}
+	if previous == "" {
+		return true, nil
+	}
+	prev, err := semver.NewVersion(previous)
+	if err != nil {
+		return false, err
+	}
+	switch level {
+	case MajorBump:
+		return cur.Major() > prev.Major(), nil
+	case MinorBump:
+		return cur.Major() > prev.Major() || cur.Minor() > prev.Minor(), nil
+	}
+	released, err := cur.SetPrerelease("")
+	if err != nil {
+		return false, err
+	}
+	return released.GreaterThan(prev), nil
+}
+
+// Insert the release section on top of a changelog file. The latest section is replaced if of the same version.
+func WriteChangelog(path string, r Release) (err error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	existing := strings.TrimPrefix(string(content), changelogHeader)
+	var released string
+	if index := strings.Index(existing, "## "); index >= 0 {
+		released = existing[index:]
+	}
+	if strings.HasPrefix(released, "## "+r.Version+" ") {
+		next := strings.Index(released, "\n## ")
+		if next < 0 {
+			released = ""
+		} else {
+			released = released[next+1:]
+		}
+	}
+
+	content = []byte(changelogHeader + "\n" + r.Markdown(r.changelogTitle()))
+	if released != "" {
+		content = append(content, []byte("\n"+released)...)
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// Release notes of a project aggregating the release of each of its images
+func WriteReleaseNotes(path, project string, releases []Release) (err error) {
+	builder := strings.Builder{}
+	builder.WriteString("# " + project + " release notes\n")
+	for _, r := range releases {
+		builder.WriteString("\n")
+		builder.WriteString(r.Markdown(r.Name + " " + r.Version))
+	}
+	return os.WriteFile(path, []byte(builder.String()), 0644)
+}
@@ -0,0 +1,165 @@
+package changelog
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/git"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestParse(t *testing.T) {
+	e := Parse(git.Commit{Hash: "0123456789", Subject: "feat(api): add endpoint"})
+	assert.Equal(t, Entry{Type: "feat", Scope: "api", Description: "add endpoint", Hash: "0123456789"}, e)
+
+	e = Parse(git.Commit{Subject: "fix!: drop legacy flag"})
+	assert.Equal(t, "fix", e.Type)
+	assert.True(t, e.Breaking, "should be breaking")
+
+	e = Parse(git.Commit{Subject: "refactor: split parser", Body: "Some details.\n\nBREAKING CHANGE: parser API changed"})
+	assert.True(t, e.Breaking, "should be breaking")
+
+	e = Parse(git.Commit{Subject: "Update README"})
+	assert.Equal(t, Entry{Type: otherType, Description: "Update README"}, e)
+}
+
+func TestLevel(t *testing.T) {
+	r := Release{}
+	assert.Equal(t, NoBump, r.Level())
+	r.Entries = []Entry{{Type: "docs"}, {Type: otherType}}
+	assert.Equal(t, PatchBump, r.Level())
+	r.Entries = append(r.Entries, Entry{Type: "feat"}, Entry{Type: "fix"})
+	assert.Equal(t, MinorBump, r.Level())
+	r.Entries = append(r.Entries, Entry{Type: "fix", Breaking: true})
+	assert.Equal(t, MajorBump, r.Level())
+}
+
+func TestAlreadyBumped(t *testing.T) {
+	cases := []struct {
+		previous, current string
+		level             Level
+		expected          bool
+	}{
+		{"1.2.0", "1.2.0", PatchBump, false},
+		{"1.2.0", "1.2.1-dev", PatchBump, true},
+		{"1.2.0", "1.2.1-dev", MinorBump, false},
+		{"1.2.0", "1.3.0-dev", MinorBump, true},
+		{"1.2.0", "1.3.0-dev", MajorBump, false},
+		{"1.2.0", "2.0.0-dev", MajorBump, true},
+		{"", "0.0.1-dev", MajorBump, true},
+	}
+	for _, c := range cases {
+		bumped, err := AlreadyBumped(c.previous, c.current, c.level)
+		require.NoError(t, err, "should not error")
+		assert.Equal(t, c.expected, bumped, "%s => %s for %s", c.previous, c.current, c.level)
+	}
+}
+
+func TestWriteChangelog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultChangelogFile)
+	date := time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)
+
+	r1 := Release{Version: "1.0.0", Date: date, Entries: []Entry{{Type: "feat", Description: "first feature", Hash: "aaaaaaaaaa"}}}
+	err := WriteChangelog(path, r1)
+	require.NoError(t, err, "should not error")
+
+	r2 := Release{Version: "1.1.0", Date: date, Entries: []Entry{{Type: "fix", Scope: "api", Description: "a fix", Hash: "bbbbbbbbbb"}}}
+	err = WriteChangelog(path, r2)
+	require.NoError(t, err, "should not error")
+	r2.Entries = append(r2.Entries, Entry{Type: "feat", Description: "second feature", Hash: "cccccccccc"}, Entry{Type: "chore", Description: "tidy"})
+	err = WriteChangelog(path, r2)
+	require.NoError(t, err, "should not error")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err, "should not error")
+	expected := `# Changelog
+
+## 1.1.0 - 2022-10-02
+
+### Features
+
+- second feature (ccccccc)
+
+### Bug Fixes
+
+- **api:** a fix (bbbbbbb)
+
+### Other Changes
+
+- tidy
+
+## 1.0.0 - 2022-10-02
+
+### Features
+
+- first feature (aaaaaaa)
+`
+	assert.Equal(t, expected, string(content), "same version section should be replaced")
+}
+
+func TestWriteReleaseNotes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultReleaseNotesFile)
+	releases := []Release{
+		{Name: "p1/i1", Version: "1.0.0", Entries: []Entry{{Type: "feat", Description: "a feature"}}},
+		{Name: "p1/i2", Version: "0.1.0"},
+	}
+	err := WriteReleaseNotes(path, "p1", releases)
+	require.NoError(t, err, "should not error")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err, "should not error")
+	expected := "# p1 release notes\n\n## p1/i1 1.0.0\n\n### Features\n\n- a feature\n\n## p1/i2 0.1.0\n\nNo changes.\n"
+	assert.Equal(t, expected, string(content))
+}
+
+func TestCollect(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	gitCommand := func(args ...string) {
+		args = append([]string{"-C", wksPath, "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, "should not error: %s", out)
+	}
+	commitFile := func(path, message string) {
+		err := os.WriteFile(path, []byte(message), 0644)
+		require.NoError(t, err, "should not error")
+		gitCommand("add", path)
+		gitCommand("commit", "-q", "-m", message)
+	}
+	gitCommand("init", "-q")
+	gitCommand("add", ".")
+	gitCommand("commit", "-q", "-m", "init")
+	gitCommand("tag", "-a", git.ReleaseTag(image.Name(), "0.0.1"), "-m", "release")
+	gitCommand("tag", "-a", git.ReleaseTag(image.Name(), "0.0.2-rc1"), "-m", "pre-release")
+
+	commitFile(filepath.Join(image.AbsSourceDir(), "main.go"), "feat: add main")
+	commitFile(image.AbsBuildFile(), "fix(build): pin base image")
+	commitFile(filepath.Join(image.Dir(), "notes.txt"), "docs: outside of sources")
+	commitFile(filepath.Join(wksPath, "other.txt"), "chore: outside of image")
+
+	repo, err := git.Open(git.BinaryBackend, image.Dir())
+	require.NoError(t, err, "should not error")
+	tag, previous, err := PreviousRelease(repo, image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "p1/i1/v0.0.1", tag)
+	assert.Equal(t, "0.0.1", previous)
+
+	r, err := Collect(repo, image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "p1/i1", r.Name)
+	assert.Equal(t, "0.0.1", r.Previous)
+	require.Len(t, r.Entries, 2)
+	assert.Equal(t, "pin base image", r.Entries[0].Description)
+	assert.Equal(t, "add main", r.Entries[1].Description)
+	assert.Equal(t, MinorBump, r.Level())
+}
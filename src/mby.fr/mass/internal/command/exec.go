@@ -1,13 +1,20 @@
 package command
 
 import (
+	"context"
 	"os/exec"
+	"sync"
 
 	"mby.fr/mass/internal/logger"
 	"mby.fr/utils/inout"
 )
 
 func RunLogging(cmd *exec.Cmd, logger logger.ActionLogger) (err error) {
+	return RunLoggingContext(context.Background(), cmd, logger)
+}
+
+// Run a command logging its outputs. The process is killed when ctx is done.
+func RunLoggingContext(ctx context.Context, cmd *exec.Cmd, logger logger.ActionLogger) (err error) {
 	errors := make(chan error, 10)
 
 	stdout, err := cmd.StdoutPipe()
@@ -19,13 +26,33 @@ func RunLogging(cmd *exec.Cmd, logger logger.ActionLogger) (err error) {
 		errors <- err
 	}
 
-	go inout.CopyChannelingErrors(stdout, logger.Out(), errors)
-	go inout.CopyChannelingErrors(stderr, logger.Err(), errors)
+	// Outputs must be read before calling Wait which closes the pipes
+	var copying sync.WaitGroup
+	copying.Add(2)
+	go func() {
+		defer copying.Done()
+		inout.CopyChannelingErrors(stdout, logger.Out(), errors)
+	}()
+	go func() {
+		defer copying.Done()
+		inout.CopyChannelingErrors(stderr, logger.Err(), errors)
+	}()
 
 	err = cmd.Start()
 	if err != nil {
 		//logger.Flush()
 		errors <- err
+	} else {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cmd.Process.Kill()
+			case <-done:
+			}
+		}()
+		copying.Wait()
 	}
 	err = cmd.Wait()
 	if err == nil {
@@ -35,5 +62,8 @@ func RunLogging(cmd *exec.Cmd, logger logger.ActionLogger) (err error) {
 		default:
 		}
 	}
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	return
 }
@@ -17,6 +17,15 @@ type TagsConfig map[string]string
 type EnvConfig map[string]string
 type BuildArgsConfig map[string]string
 type RunArgsConfig []string
+type VolumesConfig []string
+
+// Test runner options
+type TestConfig struct {
+	Image       string        // Image of the container runner, overrides the venom image
+	Command     []string      // Command of the command and container runners
+	Environment EnvConfig     // Environment passed to the tests
+	Volumes     VolumesConfig // Volumes mounted in the tests container: hostPath:containerPath[:mode]
+}
 
 type Config struct {
 	Labels LabelsConfig
@@ -24,6 +33,8 @@ type Config struct {
 	Environment EnvConfig
 	BuildArgs BuildArgsConfig
 	RunArgs RunArgsConfig
+	Volumes VolumesConfig // Volumes mounted on deploy: hostPath:containerPath[:mode]
+	Test TestConfig
 }
 
 // Init config in a directory path
@@ -98,6 +109,33 @@ func mergeStringArrays(base, replace []string) []string {
 	return merged
 }
 
+// Merge arrays keeping each value once
+func mergeDistinctStringArrays(base, add []string) []string {
+	var merged []string
+	present := map[string]bool{}
+	for _, v := range append(append([]string{}, base...), add...) {
+		if !present[v] {
+			present[v] = true
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+// Image and command are replaced, environment and volumes are merged
+func mergeTestConfigs(base, replace TestConfig) TestConfig {
+	merged := base
+	if replace.Image != "" {
+		merged.Image = replace.Image
+	}
+	if len(replace.Command) > 0 {
+		merged.Command = replace.Command
+	}
+	merged.Environment = mergeStringMaps(base.Environment, replace.Environment)
+	merged.Volumes = mergeDistinctStringArrays(base.Volumes, replace.Volumes)
+	return merged
+}
+
 // Merge several config from lowest priority to highest priority
 func Merge(configs ...Config) (Config) {
 	mergedConfig := configs[0]
@@ -108,6 +146,8 @@ func Merge(configs ...Config) (Config) {
 		mergedConfig.Environment = mergeStringMaps(mergedConfig.Environment, c.Environment)
 		mergedConfig.BuildArgs = mergeStringMaps(mergedConfig.BuildArgs, c.BuildArgs)
 		mergedConfig.RunArgs = mergeStringArrays(mergedConfig.RunArgs, c.RunArgs)
+		mergedConfig.Volumes = mergeDistinctStringArrays(mergedConfig.Volumes, c.Volumes)
+		mergedConfig.Test = mergeTestConfigs(mergedConfig.Test, c.Test)
 	}
 
 	return mergedConfig
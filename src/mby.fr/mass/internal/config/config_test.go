@@ -110,3 +110,24 @@ func TestMergeConfig(t *testing.T) {
 	assert.Equal(t, "val5", mergedConfig.Environment["key5"], "key modified")
 }
 
+
+func TestMergeVolumes(t *testing.T) {
+	c1 := Config{Volumes: VolumesConfig{"/data:/data", "/logs:/logs"}}
+	c2 := Config{}
+	c3 := Config{Volumes: VolumesConfig{"/logs:/logs", "/cache:/cache:ro"}}
+
+	mergedConfig := Merge(c1, c2, c3)
+	assert.Equal(t, VolumesConfig{"/data:/data", "/logs:/logs", "/cache:/cache:ro"}, mergedConfig.Volumes, "volumes should be merged once")
+}
+
+func TestMergeTestConfig(t *testing.T) {
+	c1 := Config{Test: TestConfig{Image: "golang:1.18", Command: []string{"go", "test"}, Environment: EnvConfig{"a": "1"}, Volumes: VolumesConfig{"/data:/data"}}}
+	c2 := Config{Test: TestConfig{Environment: EnvConfig{"b": "2"}}}
+	c3 := Config{Test: TestConfig{Command: []string{"go", "test", "./..."}, Environment: EnvConfig{"a": "3"}, Volumes: VolumesConfig{"/cache:/cache"}}}
+	mergedConfig := Merge(c1, c2, c3)
+
+	assert.Equal(t, "golang:1.18", mergedConfig.Test.Image, "image should not be replaced by empty image")
+	assert.Equal(t, []string{"go", "test", "./..."}, mergedConfig.Test.Command, "command should be replaced")
+	assert.Equal(t, EnvConfig{"a": "3", "b": "2"}, mergedConfig.Test.Environment, "environment should be merged")
+	assert.Equal(t, VolumesConfig{"/data:/data", "/cache:/cache"}, mergedConfig.Test.Volumes, "volumes should be merged once")
+}
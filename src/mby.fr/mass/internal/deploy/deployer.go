@@ -2,15 +2,23 @@ package deploy
 
 import (
 	//"bytes"
+	"context"
 	"fmt"
 	"os/exec"
 	"regexp"
+	"strings"
 
+	"mby.fr/mass/internal/change"
 	"mby.fr/mass/internal/command"
+	"mby.fr/mass/internal/config"
 	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/interrupt"
 	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/plan"
 	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
 
+	"mby.fr/utils/engine"
 	"mby.fr/utils/errorz"
 )
 
@@ -18,37 +26,90 @@ const composeImage = "docker/compose:alpine-1.29.2"
 const defaultComposeDownTimeoutInSec = "60"
 
 var NotDeployableResource error = fmt.Errorf("Not deployable resource")
+var NotLocked error = fmt.Errorf("Image not locked")
 
 type Deployer interface {
-	Pull() error
-	Deploy() error
-	Undeploy(rmVolumes bool) error
+	// Running processes are killed when ctx is done
+	Pull(ctx context.Context) error
+	// If onlyIfChange, skip resources whose deploy signature did not change since last deploy
+	Deploy(ctx context.Context, onlyIfChange bool) error
+	Undeploy(ctx context.Context, rmVolumes bool) error
+	// Steps Pull would do without running anything
+	PlanPull() (plan.Plan, error)
+	// Steps Deploy would do without running anything. Images qualified names in rebuilt will be built before deploying.
+	PlanDeploy(onlyIfChange bool, rebuilt map[string]bool) (plan.Plan, error)
+	// Steps Undeploy would do without running anything
+	PlanUndeploy(rmVolumes bool) (plan.Plan, error)
 }
 
 func New(r resources.Resourcer) (Deployer, error) {
+	return NewNamespaced(DefaultNamespace, r)
+}
+
+// Deployer of a resource in a namespace. Deploy signatures are only used in the default namespace.
+func NewNamespaced(ns Namespace, r resources.Resourcer) (Deployer, error) {
 	switch res := r.(type) {
 	case *resources.Project:
-		return New(*res)
+		return NewNamespaced(ns, *res)
 	case *resources.Image:
-		return New(*res)
+		return NewNamespaced(ns, *res)
 	case resources.Project:
-		return DockerComposeProjectsDeployer{"docker", []string{}, []resources.Project{res}}, nil
+		return DockerComposeProjectsDeployer{"docker", ns, []string{}, []resources.Project{res}, nil}, nil
 	case resources.Image:
-		return DockerImagesDeployer{"docker", []string{}, []resources.Image{res}}, nil
+		ss, err := settings.GetSettingsService()
+		if err != nil {
+			return nil, err
+		}
+		client, err := ss.EngineClient()
+		if err != nil {
+			return nil, err
+		}
+		return DockerImagesDeployer{"docker", client, ns, []string{}, []resources.Image{res}, nil}, nil
 	default:
 		return nil, fmt.Errorf("%w: %s", NotDeployableResource, r.QualifiedName())
 	}
 }
 
+// Deployer running images by their locked id instead of their mutable tag. lockedIds are image ids by image name.
+// Images of compose projects are tagged back to their locked id before upping the project.
+func NewLocked(r resources.Resourcer, lockedIds map[string]string) (Deployer, error) {
+	deployer, err := New(r)
+	if err != nil {
+		return nil, err
+	}
+	switch d := deployer.(type) {
+	case DockerImagesDeployer:
+		d.locked = lockedIds
+		return d, nil
+	case DockerComposeProjectsDeployer:
+		d.locked = lockedIds
+		return d, nil
+	}
+	return deployer, nil
+}
+
+// Locked id of an image
+func lockedId(lockedIds map[string]string, image resources.Image) (id string, err error) {
+	id, ok := lockedIds[image.Name()]
+	if !ok || id == "" {
+		err = fmt.Errorf("%w: %s", NotLocked, image.Name())
+	}
+	return
+}
+
+// Compose projects are still deployed with the docker binary.
 type DockerImagesDeployer struct {
-	binary string
-	args   []string
-	images []resources.Image
+	binary    string
+	client    *engine.Client // If not nil use the engine API instead of the binary
+	namespace Namespace
+	args      []string
+	images    []resources.Image
+	locked    map[string]string // If not nil run images by their locked id
 }
 
-func (d DockerImagesDeployer) Pull() (err error) {
+func (d DockerImagesDeployer) Pull(ctx context.Context) (err error) {
 	for _, image := range d.images {
-		err = pullImage(d.binary, image)
+		err = pullImage(ctx, d.binary, d.client, image)
 		if err != nil {
 			return
 		}
@@ -56,9 +117,20 @@ func (d DockerImagesDeployer) Pull() (err error) {
 	return
 }
 
-func (d DockerImagesDeployer) Deploy() (err error) {
+func (d DockerImagesDeployer) Deploy(ctx context.Context, onlyIfChange bool) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
 	for _, image := range d.images {
-		err = runImage(d.binary, image)
+		ref := image.FullName()
+		if d.locked != nil {
+			ref, err = lockedId(d.locked, image)
+			if err != nil {
+				return
+			}
+		}
+		err = deployImage(ctx, d.binary, d.client, d.namespace, image, ref, onlyIfChange)
 		if err != nil {
 			return
 		}
@@ -66,9 +138,194 @@ func (d DockerImagesDeployer) Deploy() (err error) {
 	return
 }
 
-func (d DockerImagesDeployer) Undeploy(rmVolumes bool) (err error) {
+func (d DockerImagesDeployer) Undeploy(ctx context.Context, rmVolumes bool) (err error) {
 	// FIXME: remove persistent volumes
-	return undeployContainers(d.binary, d.images)
+	err = undeployContainers(d.binary, d.client, d.namespace, d.images)
+	if err != nil || !d.namespace.IsDefault() {
+		return
+	}
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	for _, image := range d.images {
+		err = change.ForgetDeploySignature(image)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Engine API calls are rendered as an equivalent pseudo command
+func commandLine(binary string, client *engine.Client, params ...string) string {
+	if client != nil {
+		binary = "engine"
+	}
+	return plan.CommandLine(binary, params...)
+}
+
+func (d DockerImagesDeployer) PlanPull() (p plan.Plan, err error) {
+	for _, image := range d.images {
+		p = append(p, plan.Step{
+			Phase:    "pull",
+			Resource: image.QualifiedName(),
+			Action:   plan.PullAction,
+			Reason:   "pull forced",
+			Commands: []string{commandLine(d.binary, d.client, "pull", image.FullName())},
+		})
+	}
+	return
+}
+
+func (d DockerImagesDeployer) PlanDeploy(onlyIfChange bool, rebuilt map[string]bool) (p plan.Plan, err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	for _, image := range d.images {
+		step := plan.Step{Phase: "up", Resource: image.QualifiedName(), Action: plan.NoneAction}
+		digest := imageDigest(d.binary, d.client, image.FullName())
+		deploy, reason, err := mustDeploy(d.namespace, image, digest, onlyIfChange)
+		if err != nil {
+			return nil, err
+		}
+		ctName, err := containerName(d.namespace, image)
+		if err != nil {
+			return nil, err
+		}
+		exists := containerExists(d.binary, d.client, ctName)
+		if rebuilt[image.QualifiedName()] {
+			deploy, reason = true, "image rebuilt"
+		} else if !deploy && !exists {
+			deploy, reason = true, "container missing"
+		} else if digest == "" {
+			reason += ", image will be pulled"
+		}
+		step.Reason = reason
+		if deploy {
+			runArgs, cmdArgs, _, err := imageRunArgs(d.namespace, image)
+			if err != nil {
+				return nil, err
+			}
+			step.Action = plan.CreateAction
+			if exists {
+				step.Action = plan.RecreateAction
+			}
+			step.Commands = append(step.Commands,
+				commandLine(d.binary, d.client, "rm", "-f", ctName),
+				commandLine(d.binary, d.client, dockerRunParams(runArgs, ctName, image.FullName(), cmdArgs...)...))
+		}
+		p = append(p, step)
+	}
+	return
+}
+
+func (d DockerImagesDeployer) PlanUndeploy(rmVolumes bool) (p plan.Plan, err error) {
+	for _, image := range d.images {
+		ctName, err := containerName(d.namespace, image)
+		if err != nil {
+			return nil, err
+		}
+		step := plan.Step{Phase: "down", Resource: image.QualifiedName(), Action: plan.NoneAction, Reason: "no container"}
+		if containerExists(d.binary, d.client, ctName) {
+			step.Action = plan.RemoveAction
+			step.Reason = "container found"
+		}
+		step.Commands = []string{commandLine(d.binary, d.client, "rm", "-f", ctName)}
+		p = append(p, step)
+	}
+	return
+}
+
+// Identifier of a local image content. Empty if the image is not found.
+func imageDigest(binary string, client *engine.Client, ref string) string {
+	if client != nil {
+		info, err := client.ImageInspect(ref)
+		if err != nil {
+			return ""
+		}
+		return info.Id
+	}
+	out, err := exec.Command(binary, "image", "inspect", "--format", "{{.Id}}", ref).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Identifier of the local image of a resource. Empty if the image is not found.
+func ImageId(image resources.Image) (id string, err error) {
+	client, err := engineClient()
+	if err != nil {
+		return
+	}
+	return imageDigest("docker", client, image.FullName()), nil
+}
+
+// Decide if a resource must be deployed comparing its deploy signature with the last deploy
+func mustDeploy(ns Namespace, res resources.Resourcer, digest string, onlyIfChange bool) (deploy bool, reason string, err error) {
+	if !ns.IsDefault() {
+		return true, "namespace " + ns.Suffix, nil
+	}
+	if !onlyIfChange {
+		return true, "deploy forced", nil
+	}
+	changed, err := change.DoesDeployChanged(res, digest)
+	if err != nil {
+		return
+	}
+	if !changed {
+		return false, "deploy signature unchanged", nil
+	}
+	return true, "deploy signature changed", nil
+}
+
+// Does a container exists whatever its state
+func containerExists(binary string, client *engine.Client, name string) bool {
+	if client != nil {
+		_, err := client.ContainerInspect(name)
+		return err == nil
+	}
+	err := exec.Command(binary, "container", "inspect", name).Run()
+	return err == nil
+}
+
+// Deploy an image running ref: its full name or its locked id
+func deployImage(ctx context.Context, binary string, client *engine.Client, ns Namespace, image resources.Image, ref string, onlyIfChange bool) (err error) {
+	d := display.Service()
+	log := d.BufferedActionLogger("up", image.FullName())
+
+	ctName, err := containerName(ns, image)
+	if err != nil {
+		return
+	}
+	digest := imageDigest(binary, client, ref)
+	deploy, _, err := mustDeploy(ns, image, digest, onlyIfChange)
+	if err != nil {
+		return
+	}
+	// A container removed out-of-band is redeployed even if the deploy did not change
+	if !deploy && containerExists(binary, client, ctName) {
+		log.Info("Deploy of image: %s did not changed. Do not redeploy it.", image.Name())
+		return nil
+	}
+
+	// Recreate container
+	if client != nil {
+		err = rmEngineContainers(log, client, ctName)
+	} else {
+		err = rmDockerContainers(log, binary, ctName)
+	}
+	if err != nil {
+		log.Debug("Unable to remove previous container: %s", err)
+	}
+
+	err = runImage(ctx, binary, client, ns, image, ref)
+	if err != nil || !ns.IsDefault() {
+		return
+	}
+	return change.StoreDeploySignature(image, digest)
 }
 
 func absContainerName(image resources.Image) (name string, err error) {
@@ -81,18 +338,28 @@ func absContainerName(image resources.Image) (name string, err error) {
 	return
 }
 
-func pullImage(binary string, image resources.Image) (err error) {
+func containerName(ns Namespace, image resources.Image) (name string, err error) {
+	name, err = absContainerName(image)
+	name = ns.name(name)
+	return
+}
+
+func pullImage(ctx context.Context, binary string, client *engine.Client, image resources.Image) (err error) {
 	d := display.Service()
 	log := d.BufferedActionLogger("pull", image.FullName())
 
-	var pullParams []string
-	pullParams = append(pullParams, "pull", image.FullName())
+	if client != nil {
+		err = client.Pull(ctx, image.FullName(), log.Out())
+	} else {
+		var pullParams []string
+		pullParams = append(pullParams, "pull", image.FullName())
 
-	log.Debug("pull params: %s", pullParams)
-	cmd := exec.Command(binary, pullParams...)
-	//cmd.Dir = image.Dir()
+		log.Debug("pull params: %s", pullParams)
+		cmd := exec.Command(binary, pullParams...)
+		//cmd.Dir = image.Dir()
 
-	err = command.RunLogging(cmd, log)
+		err = command.RunLoggingContext(ctx, cmd, log)
+	}
 	if err != nil {
 		flushErr := d.Flush()
 		err = fmt.Errorf("Error pulling image %s : %w", image.FullName(), err)
@@ -104,10 +371,7 @@ func pullImage(binary string, image resources.Image) (err error) {
 	return
 }
 
-func runDockerImage(log logger.ActionLogger, binary string, runArgs []string, name string, image string, cmdArgs ...string) (err error) {
-	log.Info("Running image: %s as: %s ...", image, name)
-
-	var runParams []string
+func dockerRunParams(runArgs []string, name string, image string, cmdArgs ...string) (runParams []string) {
 	runParams = append(runParams, "run")
 
 	runParams = append(runParams, runArgs...)
@@ -119,12 +383,19 @@ func runDockerImage(log logger.ActionLogger, binary string, runArgs []string, na
 	runParams = append(runParams, image)
 
 	runParams = append(runParams, cmdArgs...)
+	return
+}
+
+func runDockerImage(ctx context.Context, log logger.ActionLogger, binary string, runArgs []string, name string, image string, cmdArgs ...string) (err error) {
+	log.Info("Running image: %s as: %s ...", image, name)
+
+	runParams := dockerRunParams(runArgs, name, image, cmdArgs...)
 
 	log.Debug("run params: %s", runParams)
 	cmd := exec.Command(binary, runParams...)
 	//cmd.Dir = image.Dir()
 
-	err = command.RunLogging(cmd, log)
+	err = command.RunLoggingContext(ctx, cmd, log)
 	if err != nil {
 		// flushErr := log.Flush()
 		// agg := errorz.NewAggregated(err, flushErr)
@@ -134,35 +405,94 @@ func runDockerImage(log logger.ActionLogger, binary string, runArgs []string, na
 	return
 }
 
-func runImage(binary string, image resources.Image) (err error) {
-	d := display.Service()
-	log := d.BufferedActionLogger("run", image.FullName())
+// Engine container config equivalent to imageRunArgs
+func engineContainerConfig(ns Namespace, image resources.Image, ref string, conf *config.Config, cmdArgs []string) (config engine.ContainerConfig) {
+	config = engine.ContainerConfig{Image: ref, Cmd: cmdArgs}
+	config.HostConfig.Binds = conf.Volumes
+	for argKey, argValue := range conf.Environment {
+		config.Env = append(config.Env, argKey+"="+argValue)
+	}
+	if !ns.IsDefault() {
+		config.HostConfig.NetworkMode = ns.Network()
+		config.NetworkingConfig = &engine.NetworkingConfig{
+			EndpointsConfig: map[string]engine.EndpointSettings{ns.Network(): {Aliases: []string{image.Name()}}},
+		}
+		config.Labels = interrupt.TemporaryLabels()
+	}
+	return
+}
 
-	var runArgs []string
-	config, errors := resources.MergedConfig(image)
-	if errors != nil {
-		return errors
+func runEngineImage(ctx context.Context, log logger.ActionLogger, client *engine.Client, name string, config engine.ContainerConfig) (err error) {
+	image := config.Image
+	log.Info("Running image: %s as: %s ...", image, name)
+
+	log.Debug("container config: %v", config)
+
+	// Removing the container stops the run
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.ContainerRemove(name, true)
+		case <-done:
+		}
+	}()
+	err = client.Run(name, config, false, log.Out(), log.Err())
+	if err != nil {
+		return fmt.Errorf("Error running image %s : %w", image, err)
 	}
+	log.Info("Run finished for image: %s .", image)
+	return
+}
+
+// Docker run args and container command args of an image
+func imageRunArgs(ns Namespace, image resources.Image) (runArgs, cmdArgs []string, conf *config.Config, err error) {
+	conf, err = resources.MergedConfig(image)
+	if err != nil {
+		return
+	}
+	runArgs = append(runArgs, ns.runArgs(image.Name())...)
+
 	// Add envVars
-	for argKey, argValue := range config.Environment {
+	for argKey, argValue := range conf.Environment {
 		var envArg string = "-e=" + argKey + "=" + argValue
 		runArgs = append(runArgs, envArg)
 	}
 
+	// Add volumes
+	for _, volume := range conf.Volumes {
+		runArgs = append(runArgs, "-v", volume)
+	}
+
 	//runArgs = append(runArgs, "badArg")
 
-	var cmdArgs []string
 	// Add runParams
-	for _, argValue := range config.RunArgs {
+	for _, argValue := range conf.RunArgs {
 		cmdArgs = append(cmdArgs, argValue)
 	}
+	return
+}
+
+func runImage(ctx context.Context, binary string, client *engine.Client, ns Namespace, image resources.Image, ref string) (err error) {
+	d := display.Service()
+	log := d.BufferedActionLogger("run", image.FullName())
+
+	runArgs, cmdArgs, conf, err := imageRunArgs(ns, image)
+	if err != nil {
+		return
+	}
 
-	ctName, err := absContainerName(image)
+	ctName, err := containerName(ns, image)
 	if err != nil {
 		return
 	}
 
-	err = runDockerImage(log, binary, runArgs, ctName, image.FullName(), cmdArgs...)
+	if client != nil {
+		err = runEngineImage(ctx, log, client, ctName, engineContainerConfig(ns, image, ref, conf, cmdArgs))
+	} else {
+		err = runDockerImage(ctx, log, binary, runArgs, ctName, ref, cmdArgs...)
+	}
 	if err != nil {
 		flushErr := d.Flush()
 		agg := errorz.NewAggregated(err, flushErr)
@@ -190,20 +520,83 @@ func rmDockerContainers(log logger.ActionLogger, binary string, names ...string)
 	return
 }
 
-func undeployContainers(binary string, images []resources.Image) (err error) {
+func rmEngineContainers(log logger.ActionLogger, client *engine.Client, names ...string) (err error) {
+	for _, name := range names {
+		log.Debug("removing container: %s", name)
+		err = client.ContainerRemove(name, true)
+		if err != nil {
+			return fmt.Errorf("Error removing container %s : %w", name, err)
+		}
+	}
+	return
+}
+
+// Remove temporary containers and networks created by this process, e.g. compose runners
+func RemoveTemporaryContainers() (err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	client, err := ss.EngineClient()
+	if err != nil {
+		return
+	}
+	labels := interrupt.TemporaryLabels()
+	if client != nil {
+		ids, err := client.ContainerList(labels)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err = client.ContainerRemove(id, true)
+			if err != nil {
+				return err
+			}
+		}
+		return client.NetworksPrune(labels)
+	}
+
+	psParams := []string{"ps", "-aq"}
+	for labelKey, labelValue := range labels {
+		psParams = append(psParams, "--filter", "label="+labelKey+"="+labelValue)
+	}
+	out, err := exec.Command("docker", psParams...).Output()
+	if err != nil {
+		return
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) > 0 {
+		rmParams := append([]string{"rm", "-f"}, ids...)
+		err = exec.Command("docker", rmParams...).Run()
+		if err != nil {
+			return
+		}
+	}
+	pruneParams := []string{"network", "prune", "-f"}
+	for labelKey, labelValue := range labels {
+		pruneParams = append(pruneParams, "--filter", "label="+labelKey+"="+labelValue)
+	}
+	return exec.Command("docker", pruneParams...).Run()
+}
+
+func undeployContainers(binary string, client *engine.Client, ns Namespace, images []resources.Image) (err error) {
 	d := display.Service()
 	log := d.BufferedActionLogger("rm", "")
 
 	names := []string{}
 	for _, image := range images {
-		ctName, err := absContainerName(image)
+		ctName, err := containerName(ns, image)
 		if err != nil {
 			return err
 		}
 		names = append(names, ctName)
 	}
 	log.Info("Removing containers: %s ...", names)
-	err = rmDockerContainers(log, binary, names...)
+	if client != nil {
+		err = rmEngineContainers(log, client, names...)
+	} else {
+		err = rmDockerContainers(log, binary, names...)
+	}
 	if err != nil {
 		flushErr := d.Flush()
 		agg := errorz.NewAggregated(err, flushErr)
@@ -215,14 +608,16 @@ func undeployContainers(binary string, images []resources.Image) (err error) {
 }
 
 type DockerComposeProjectsDeployer struct {
-	binary   string
-	args     []string
-	projects []resources.Project
+	binary    string
+	namespace Namespace
+	args      []string
+	projects  []resources.Project
+	locked    map[string]string // If not nil tag project images back to their locked id before upping
 }
 
-func (d DockerComposeProjectsDeployer) Pull() (err error) {
+func (d DockerComposeProjectsDeployer) Pull(ctx context.Context) (err error) {
 	for _, project := range d.projects {
-		err = pullDockerComposeProject(project, d.binary)
+		err = pullDockerComposeProject(ctx, d.namespace, project, d.binary)
 		if err != nil {
 			return
 		}
@@ -230,9 +625,19 @@ func (d DockerComposeProjectsDeployer) Pull() (err error) {
 	return
 }
 
-func (d DockerComposeProjectsDeployer) Deploy() (err error) {
+func (d DockerComposeProjectsDeployer) Deploy(ctx context.Context, onlyIfChange bool) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
 	for _, project := range d.projects {
-		err = upDockerComposeProject(project, d.binary, d.args...)
+		if d.locked != nil {
+			err = tagLockedImages(d.binary, project, d.locked)
+			if err != nil {
+				return
+			}
+		}
+		err = deployDockerComposeProject(ctx, d.namespace, project, d.binary, onlyIfChange, d.args...)
 		if err != nil {
 			return
 		}
@@ -240,9 +645,17 @@ func (d DockerComposeProjectsDeployer) Deploy() (err error) {
 	return
 }
 
-func (d DockerComposeProjectsDeployer) Undeploy(rmVolumes bool) (err error) {
+func (d DockerComposeProjectsDeployer) Undeploy(ctx context.Context, rmVolumes bool) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
 	for _, p := range d.projects {
-		err = downDockerComposeProject(p, d.binary, rmVolumes)
+		err = downDockerComposeProject(ctx, d.namespace, p, d.binary, rmVolumes)
+		if err != nil || !d.namespace.IsDefault() {
+			return
+		}
+		err = change.ForgetDeploySignature(p)
 		if err != nil {
 			return
 		}
@@ -250,44 +663,233 @@ func (d DockerComposeProjectsDeployer) Undeploy(rmVolumes bool) (err error) {
 	return
 }
 
-func pullDockerComposeProject(project resources.Project, binary string) (err error) {
-	d := display.Service()
-	log := d.BufferedActionLogger("pull", project.Name())
-	log.Info("Pulling project: %s ...", project.Name())
+// Project name given to compose
+func projectName(ns Namespace, project resources.Project) (name string, err error) {
+	name, err = project.AbsoluteName()
+	name = ns.name(name)
+	return
+}
+
+// Project name as normalized by compose
+func composeProjectName(ns Namespace, project resources.Project) (name string, err error) {
+	name, err = projectName(ns, project)
+	re := regexp.MustCompile("[^-_a-z0-9]")
+	name = re.ReplaceAllString(strings.ToLower(name), "")
+	return
+}
+
+// Does compose containers exist for a project whatever their state
+func composeProjectExists(binary string, ns Namespace, project resources.Project) bool {
+	name, err := composeProjectName(ns, project)
+	if err != nil {
+		return false
+	}
+	filter := "label=com.docker.compose.project=" + name
+	out, err := exec.Command(binary, "ps", "-aq", "--filter", filter).Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+func (d DockerComposeProjectsDeployer) PlanPull() (p plan.Plan, err error) {
+	for _, project := range d.projects {
+		runArgs, cmdParams, err := composePullParams(d.namespace, project)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, plan.Step{
+			Phase:    "pull",
+			Resource: project.QualifiedName(),
+			Action:   plan.PullAction,
+			Reason:   "pull forced",
+			Commands: []string{plan.CommandLine(d.binary, dockerRunParams(runArgs, "", composeImage, cmdParams...)...)},
+		})
+	}
+	return
+}
+
+func (d DockerComposeProjectsDeployer) PlanDeploy(onlyIfChange bool, rebuilt map[string]bool) (p plan.Plan, err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	for _, project := range d.projects {
+		step := plan.Step{Phase: "up", Resource: project.QualifiedName(), Action: plan.NoneAction}
+		digest, err := projectDigest(project, d.binary)
+		if err != nil {
+			return nil, err
+		}
+		deploy, reason, err := mustDeploy(d.namespace, project, digest, onlyIfChange)
+		if err != nil {
+			return nil, err
+		}
+		images, err := project.Images()
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			if rebuilt[image.QualifiedName()] {
+				deploy, reason = true, "image rebuilt"
+			}
+		}
+		exists := composeProjectExists(d.binary, d.namespace, project)
+		if !deploy && !exists {
+			deploy, reason = true, "containers missing"
+		}
+		step.Reason = reason
+		if deploy {
+			runArgs, cmdParams, err := composeUpParams(d.namespace, project, d.args...)
+			if err != nil {
+				return nil, err
+			}
+			step.Action = plan.CreateAction
+			if exists {
+				step.Action = plan.RecreateAction
+			}
+			step.Commands = []string{plan.CommandLine(d.binary, dockerRunParams(runArgs, "", composeImage, cmdParams...)...)}
+		}
+		p = append(p, step)
+	}
+	return
+}
+
+func (d DockerComposeProjectsDeployer) PlanUndeploy(rmVolumes bool) (p plan.Plan, err error) {
+	for _, project := range d.projects {
+		runArgs, cmdParams, err := composeDownParams(d.namespace, project, rmVolumes)
+		if err != nil {
+			return nil, err
+		}
+		step := plan.Step{Phase: "down", Resource: project.QualifiedName(), Action: plan.NoneAction, Reason: "no container"}
+		if composeProjectExists(d.binary, d.namespace, project) {
+			step.Action = plan.RemoveAction
+			step.Reason = "containers found"
+		}
+		step.Commands = []string{plan.CommandLine(d.binary, dockerRunParams(runArgs, "", composeImage, cmdParams...)...)}
+		p = append(p, step)
+	}
+	return
+}
+
+// Digests of project images
+func projectDigest(project resources.Project, binary string) (digest string, err error) {
+	images, err := project.Images()
+	if err != nil {
+		return
+	}
+	var digests []string
+	for _, image := range images {
+		digests = append(digests, image.Name()+"@"+imageDigest(binary, nil, image.FullName()))
+	}
+	digest = strings.Join(digests, ",")
+	return
+}
 
+func deployDockerComposeProject(ctx context.Context, ns Namespace, project resources.Project, binary string, onlyIfChange bool, args ...string) (err error) {
+	digest, err := projectDigest(project, binary)
+	if err != nil {
+		return
+	}
+	deploy, _, err := mustDeploy(ns, project, digest, onlyIfChange)
+	if err != nil {
+		return
+	}
+	// Containers removed out-of-band are redeployed even if the deploy did not change
+	if !deploy && composeProjectExists(binary, ns, project) {
+		d := display.Service()
+		log := d.BufferedActionLogger("up", project.Name())
+		log.Info("Deploy of project: %s did not changed. Do not redeploy it.", project.Name())
+		return nil
+	}
+
+	err = upDockerComposeProject(ctx, ns, project, binary, args...)
+	if err != nil || !ns.IsDefault() {
+		return
+	}
+	return change.StoreDeploySignature(project, digest)
+}
+
+// Docker run args to run compose on a project
+func composeRunArgs(project resources.Project) (runComposeOnDockerArgs []string, err error) {
 	// Docker run level config
 	projectVol := project.Dir() + ":/code:ro"
-	runComposeOnDockerArgs := []string{"--rm",
+	runComposeOnDockerArgs = []string{"--rm",
 		"-v", "/var/run/docker.sock:/var/run/docker.sock:rw", // Mount Docker daemon socket
 		"-v", "/var/lib/docker/image:/var/lib/docker/image:rw", // Mount docker image cache
 		"-v", projectVol, "--workdir", "/code", // Mount project code
 	}
 
-	config, errors := resources.MergedConfig(project)
-	if errors != nil {
-		return errors
+	// Compose runner is a temporary container
+	for labelKey, labelValue := range interrupt.TemporaryLabels() {
+		runComposeOnDockerArgs = append(runComposeOnDockerArgs, "--label", labelKey+"="+labelValue)
+	}
+
+	config, err := resources.MergedConfig(project)
+	if err != nil {
+		return
 	}
 	// Supply environment at docker run level
 	for argKey, argValue := range config.Environment {
 		var envArg string = "-e=" + argKey + "=" + argValue
 		runComposeOnDockerArgs = append(runComposeOnDockerArgs, envArg)
 	}
+	return
+}
+
+func composePullParams(ns Namespace, project resources.Project) (runComposeOnDockerArgs, cmdParams []string, err error) {
+	runComposeOnDockerArgs, err = composeRunArgs(project)
+	if err != nil {
+		return
+	}
 
 	// Set project name
-	absoluteName, err := project.AbsoluteName()
+	absoluteName, err := projectName(ns, project)
 	if err != nil {
-		return err
+		return
 	}
 
-	// Up level config
-	var cmdParams []string
+	// Pull level config
 	cmdParams = append(cmdParams, "--project-name", absoluteName)
 	cmdParams = append(cmdParams, "pull")
 
 	// Add default params
 	//cmdParams = append(cmdParams,)
+	return
+}
 
-	err = runDockerImage(log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
+func composeDownParams(ns Namespace, project resources.Project, rmVolumes bool) (runComposeOnDockerArgs, cmdParams []string, err error) {
+	runComposeOnDockerArgs, err = composeRunArgs(project)
+	if err != nil {
+		return
+	}
+
+	// Set project name
+	absoluteName, err := projectName(ns, project)
+	if err != nil {
+		return
+	}
+
+	// Down level config
+	cmdParams = append(cmdParams, "--project-name", absoluteName)
+	cmdParams = append(cmdParams, "down")
+
+	// Add default params
+	cmdParams = append(cmdParams, "--remove-orphans", "--timeout", defaultComposeDownTimeoutInSec)
+
+	if rmVolumes {
+		cmdParams = append(cmdParams, "--volumes")
+	}
+	return
+}
+
+func pullDockerComposeProject(ctx context.Context, ns Namespace, project resources.Project, binary string) (err error) {
+	d := display.Service()
+	log := d.BufferedActionLogger("pull", project.Name())
+	log.Info("Pulling project: %s ...", project.Name())
+
+	runComposeOnDockerArgs, cmdParams, err := composePullParams(ns, project)
+	if err != nil {
+		return
+	}
+
+	err = runDockerImage(ctx, log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
 	if err != nil {
 		flushErr := d.Flush()
 		err = fmt.Errorf("Error pulling project %s : %w", project.Name(), err)
@@ -299,27 +901,51 @@ func pullDockerComposeProject(project resources.Project, binary string) (err err
 	return
 }
 
-func upDockerComposeProject(project resources.Project, binary string, args ...string) (err error) {
+// Compose files reference images by tag: point the tags of the project images to their locked id
+func tagLockedImages(binary string, project resources.Project, lockedIds map[string]string) (err error) {
+	images, err := project.Images()
+	if err != nil {
+		return
+	}
+	for _, image := range images {
+		id, err := lockedId(lockedIds, *image)
+		if err != nil {
+			return err
+		}
+		out, err := exec.Command(binary, "tag", id, image.FullName()).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Unable to tag locked image %s of %s: %w: %s", id, image.Name(), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return
+}
+
+func upDockerComposeProject(ctx context.Context, ns Namespace, project resources.Project, binary string, args ...string) (err error) {
 	d := display.Service()
 	log := d.BufferedActionLogger("up", project.Name())
 	log.Info("Upping project: %s ...", project.Name())
 
-	// Docker run level config
-	projectVol := project.Dir() + ":/code:ro"
-	runComposeOnDockerArgs := []string{"--rm",
-		"-v", "/var/run/docker.sock:/var/run/docker.sock:rw", // Mount Docker daemon socket
-		"-v", "/var/lib/docker/image:/var/lib/docker/image:rw", // Mount docker image cache
-		"-v", projectVol, "--workdir", "/code", // Mount project code
+	runComposeOnDockerArgs, cmdParams, err := composeUpParams(ns, project, args...)
+	if err != nil {
+		return
 	}
 
-	config, errors := resources.MergedConfig(project)
-	if errors != nil {
-		return errors
+	err = runDockerImage(ctx, log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
+	if err != nil {
+		flushErr := d.Flush()
+		err = fmt.Errorf("Error upping project %s : %w", project.Name(), err)
+		agg := errorz.NewAggregated(err, flushErr)
+		return agg
 	}
-	// Supply environment at docker run level
-	for argKey, argValue := range config.Environment {
-		var envArg string = "-e=" + argKey + "=" + argValue
-		runComposeOnDockerArgs = append(runComposeOnDockerArgs, envArg)
+
+	log.Info("Up finished for project: %s .", project.Name())
+	return
+}
+
+func composeUpParams(ns Namespace, project resources.Project, args ...string) (runComposeOnDockerArgs, cmdParams []string, err error) {
+	runComposeOnDockerArgs, err = composeRunArgs(project)
+	if err != nil {
+		return
 	}
 
 	// compose interesting Options
@@ -332,13 +958,12 @@ func upDockerComposeProject(project resources.Project, binary string, args ...st
 	// --env-file ?
 
 	// Set project name
-	absoluteName, err := project.AbsoluteName()
+	absoluteName, err := projectName(ns, project)
 	if err != nil {
-		return err
+		return
 	}
 
 	// Up level config
-	var cmdParams []string
 	cmdParams = append(cmdParams, "--project-name", absoluteName)
 	//cmdParams = append(cmdParams, "--verbose")
 	cmdParams = append(cmdParams, "up")
@@ -356,61 +981,20 @@ func upDockerComposeProject(project resources.Project, binary string, args ...st
 	cmdParams = append(cmdParams, "--detach", "--no-build", "--remove-orphans", "--force-recreate")
 
 	cmdParams = append(cmdParams, args...)
-
-	err = runDockerImage(log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
-	if err != nil {
-		flushErr := d.Flush()
-		err = fmt.Errorf("Error upping project %s : %w", project.Name(), err)
-		agg := errorz.NewAggregated(err, flushErr)
-		return agg
-	}
-
-	log.Info("Up finished for project: %s .", project.Name())
 	return
 }
 
-func downDockerComposeProject(project resources.Project, binary string, rmVolumes bool) (err error) {
+func downDockerComposeProject(ctx context.Context, ns Namespace, project resources.Project, binary string, rmVolumes bool) (err error) {
 	d := display.Service()
 	log := d.BufferedActionLogger("down", project.Name())
 	log.Info("Downing project: %s ...", project.Name())
 
-	// Docker run level config
-	projectVol := project.Dir() + ":/code:ro"
-	runComposeOnDockerArgs := []string{"--rm",
-		"-v", "/var/run/docker.sock:/var/run/docker.sock:rw", // Mount Docker daemon socket
-		"-v", "/var/lib/docker/image:/var/lib/docker/image:rw", // Mount docker image cache
-		"-v", projectVol, "--workdir", "/code", // Mount project code
-	}
-
-	config, errors := resources.MergedConfig(project)
-	if errors != nil {
-		return errors
-	}
-	// Supply environment at docker run level
-	for argKey, argValue := range config.Environment {
-		var envArg string = "-e=" + argKey + "=" + argValue
-		runComposeOnDockerArgs = append(runComposeOnDockerArgs, envArg)
-	}
-
-	// Set project name
-	absoluteName, err := project.AbsoluteName()
+	runComposeOnDockerArgs, cmdParams, err := composeDownParams(ns, project, rmVolumes)
 	if err != nil {
-		return err
-	}
-
-	// Up level config
-	var cmdParams []string
-	cmdParams = append(cmdParams, "--project-name", absoluteName)
-	cmdParams = append(cmdParams, "down")
-
-	// Add default params
-	cmdParams = append(cmdParams, "--remove-orphans", "--timeout", defaultComposeDownTimeoutInSec)
-
-	if rmVolumes {
-		cmdParams = append(cmdParams, "--volumes")
+		return
 	}
 
-	err = runDockerImage(log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
+	err = runDockerImage(ctx, log, binary, runComposeOnDockerArgs, "", composeImage, cmdParams...)
 	if err != nil {
 		flushErr := d.Flush()
 		err = fmt.Errorf("Error downing project %s : %w", project.Name(), err)
@@ -0,0 +1,98 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/plan"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestEngineContainerConfig(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	conf := &config.Config{Environment: config.EnvConfig{"foo": "bar"}, Volumes: config.VolumesConfig{"/tmp:/tmp"}}
+
+	c := engineContainerConfig(DefaultNamespace, image, image.FullName(), conf, []string{"arg"})
+	assert.Equal(t, image.FullName(), c.Image)
+	assert.Equal(t, []string{"arg"}, c.Cmd)
+	assert.Equal(t, []string{"foo=bar"}, c.Env)
+	assert.Equal(t, []string{"/tmp:/tmp"}, c.HostConfig.Binds, "image volumes should be bound")
+	assert.Empty(t, c.HostConfig.NetworkMode, "default namespace should not set a network")
+
+	ns, err := NewNamespace("test")
+	require.NoError(t, err, "should not error")
+	c = engineContainerConfig(ns, image, image.FullName(), conf, nil)
+	assert.Equal(t, []string{"/tmp:/tmp"}, c.HostConfig.Binds, "image volumes should be bound in namespaces")
+	assert.Equal(t, ns.Network(), c.HostConfig.NetworkMode)
+}
+
+func TestNewLocked(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	deployer, err := NewLocked(image, map[string]string{"p1/i1": "sha256:0a1b2c3d"})
+	require.NoError(t, err, "should not error")
+	d, ok := deployer.(DockerImagesDeployer)
+	require.True(t, ok, "image should be deployed by an images deployer")
+	id, err := lockedId(d.locked, image)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "sha256:0a1b2c3d", id)
+
+	_, err = lockedId(map[string]string{}, image)
+	assert.ErrorIs(t, err, NotLocked, "image missing from the lock should not be deployed")
+
+	conf := engineContainerConfig(DefaultNamespace, image, id, &config.Config{}, nil)
+	assert.Equal(t, "sha256:0a1b2c3d", conf.Image, "locked image should run by its id")
+}
+
+func TestPlanDeployMissingComposeProject(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	project, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	_, err = resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(filepath.Join(project.Dir(), "compose.yaml"), []byte("services: {}\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	// Fake docker binaries inspecting the same images, one of them listing no container
+	binDir := t.TempDir()
+	noContainer := filepath.Join(binDir, "no-container")
+	err = os.WriteFile(noContainer, []byte("#!/bin/sh\n[ \"$1\" = ps ] && exit 0\necho 0a1b2c3d\n"), 0755)
+	require.NoError(t, err, "should not error")
+	anyContainer := filepath.Join(binDir, "any-container")
+	err = os.WriteFile(anyContainer, []byte("#!/bin/sh\necho 0a1b2c3d\n"), 0755)
+	require.NoError(t, err, "should not error")
+
+	err = change.Init()
+	require.NoError(t, err, "should not error")
+	digest, err := projectDigest(project, anyContainer)
+	require.NoError(t, err, "should not error")
+	err = change.StoreDeploySignature(project, digest)
+	require.NoError(t, err, "should not error")
+
+	d := DockerComposeProjectsDeployer{anyContainer, DefaultNamespace, []string{}, []resources.Project{project}, nil}
+	p, err := d.PlanDeploy(true, nil)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 1)
+	assert.Equal(t, plan.NoneAction, p[0].Action, "unchanged project should not be redeployed")
+
+	d.binary = noContainer
+	p, err = d.PlanDeploy(true, nil)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 1)
+	assert.Equal(t, plan.CreateAction, p[0].Action, "project with missing containers should be redeployed")
+	assert.Equal(t, "containers missing", p[0].Reason)
+}
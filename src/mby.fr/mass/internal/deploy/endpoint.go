@@ -0,0 +1,164 @@
+package deploy
+
+import (
+	"encoding/json"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/engine"
+)
+
+const composeServiceLabel = "com.docker.compose.service"
+
+// Deployed container reachable by tests
+type Endpoint struct {
+	Name      string            // Image name or compose service name
+	Container string            // Container name
+	IP        string            // IP in the tests network
+	Ports     map[string]string // Published host port by container port, e.g. 80/tcp
+}
+
+// Subset of a container inspect output
+type containerInspect struct {
+	Name   string
+	Config struct {
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+		Ports map[string][]struct {
+			HostIp   string
+			HostPort string
+		}
+	}
+}
+
+// Network tests of a resource join: the compose default network for projects, the namespace network otherwise
+func (n Namespace) TestNetwork(r resources.Resourcer) (network string, err error) {
+	switch res := r.(type) {
+	case *resources.Project:
+		return n.TestNetwork(*res)
+	case resources.Project:
+		name, err := composeProjectName(n, res)
+		if err != nil {
+			return "", err
+		}
+		return name + "_default", nil
+	}
+	return n.Network(), nil
+}
+
+// Endpoints of the containers deployed for resources in the namespace
+func (n Namespace) Endpoints(rs ...resources.Resourcer) (endpoints []Endpoint, err error) {
+	client, err := engineClient()
+	if err != nil {
+		return
+	}
+	for _, r := range rs {
+		network, err := n.TestNetwork(r)
+		if err != nil {
+			return nil, err
+		}
+		names, err := n.containerNames(client, r)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			inspect, err := inspectContainer(client, name)
+			if err != nil {
+				// Container not deployed, e.g. exited image container removed
+				continue
+			}
+			endpoint := newEndpoint(inspect, network)
+			switch image := r.(type) {
+			case resources.Image:
+				endpoint.Name = image.Name()
+			case *resources.Image:
+				endpoint.Name = image.Name()
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return
+}
+
+func inspectContainer(client *engine.Client, name string) (inspect containerInspect, err error) {
+	var content []byte
+	if client != nil {
+		content, err = client.ContainerInspectRaw(name)
+	} else {
+		content, err = exec.Command("docker", "inspect", name).Output()
+	}
+	if err != nil {
+		return
+	}
+	return parseInspect(content)
+}
+
+// Parse an engine inspect object or a docker inspect array
+func parseInspect(content []byte) (inspect containerInspect, err error) {
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "[") {
+		var inspects []containerInspect
+		err = json.Unmarshal(content, &inspects)
+		if err == nil && len(inspects) > 0 {
+			inspect = inspects[0]
+		}
+		return
+	}
+	err = json.Unmarshal(content, &inspect)
+	return
+}
+
+// IP is taken in network if the container joined it, in the first network otherwise
+func newEndpoint(inspect containerInspect, network string) (e Endpoint) {
+	e.Container = strings.TrimPrefix(inspect.Name, "/")
+	e.Name = e.Container
+	if service, ok := inspect.Config.Labels[composeServiceLabel]; ok {
+		e.Name = service
+	}
+	if settings, ok := inspect.NetworkSettings.Networks[network]; ok {
+		e.IP = settings.IPAddress
+	} else {
+		var networks []string
+		for name := range inspect.NetworkSettings.Networks {
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		if len(networks) > 0 {
+			e.IP = inspect.NetworkSettings.Networks[networks[0]].IPAddress
+		}
+	}
+	e.Ports = map[string]string{}
+	for port, bindings := range inspect.NetworkSettings.Ports {
+		if len(bindings) > 0 {
+			e.Ports[port] = bindings[0].HostPort
+		}
+	}
+	return
+}
+
+var variableReplacer = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// Variables of endpoints: <name>_container, <name>_ip and <name>_port_<port> for published ports.
+// Non alphanumeric chars of names are replaced by _ and udp ports are suffixed by _udp.
+func EndpointsVariables(endpoints []Endpoint) map[string]string {
+	vars := map[string]string{}
+	for _, e := range endpoints {
+		prefix := variableReplacer.ReplaceAllString(e.Name, "_")
+		vars[prefix+"_container"] = e.Container
+		vars[prefix+"_ip"] = e.IP
+		for port, hostPort := range e.Ports {
+			number, protocol, _ := strings.Cut(port, "/")
+			if protocol == "udp" {
+				number += "_udp"
+			}
+			vars[prefix+"_port_"+number] = hostPort
+		}
+	}
+	return vars
+}
@@ -0,0 +1,68 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var inspectJson = `{
+	"Name": "/p1-web_test_0a1b2c3d-web-1",
+	"Config": {"Labels": {"com.docker.compose.service": "web"}},
+	"NetworkSettings": {
+		"Networks": {
+			"bridge": {"IPAddress": "172.17.0.2"},
+			"p1-web_test_0a1b2c3d_default": {"IPAddress": "172.20.0.3"}
+		},
+		"Ports": {
+			"80/tcp": [{"HostIp": "0.0.0.0", "HostPort": "8080"}],
+			"53/udp": [{"HostIp": "0.0.0.0", "HostPort": "5353"}],
+			"443/tcp": null
+		}
+	}
+}`
+
+func TestParseInspect(t *testing.T) {
+	inspect, err := parseInspect([]byte(inspectJson))
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "/p1-web_test_0a1b2c3d-web-1", inspect.Name)
+
+	// docker inspect outputs an array
+	inspect, err = parseInspect([]byte("[" + inspectJson + "]"))
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "/p1-web_test_0a1b2c3d-web-1", inspect.Name)
+
+	_, err = parseInspect([]byte("foo"))
+	assert.Error(t, err, "should error")
+}
+
+func TestNewEndpoint(t *testing.T) {
+	inspect, err := parseInspect([]byte(inspectJson))
+	require.NoError(t, err, "should not error")
+
+	e := newEndpoint(inspect, "p1-web_test_0a1b2c3d_default")
+	assert.Equal(t, "web", e.Name, "compose service should name the endpoint")
+	assert.Equal(t, "p1-web_test_0a1b2c3d-web-1", e.Container)
+	assert.Equal(t, "172.20.0.3", e.IP, "IP should be taken in the tests network")
+	assert.Equal(t, map[string]string{"80/tcp": "8080", "53/udp": "5353"}, e.Ports, "only published ports should be kept")
+
+	e = newEndpoint(inspect, "foo")
+	assert.Equal(t, "172.17.0.2", e.IP, "IP should be taken in the first network")
+}
+
+func TestEndpointsVariables(t *testing.T) {
+	endpoints := []Endpoint{
+		{Name: "p1/i1", Container: "mass-p1-i1_test_0a1b2c3d", IP: "172.20.0.2", Ports: map[string]string{"80/tcp": "8080", "53/udp": "5353"}},
+		{Name: "web", Container: "web-1", IP: "172.20.0.3", Ports: map[string]string{}},
+	}
+	expected := map[string]string{
+		"p1_i1_container":   "mass-p1-i1_test_0a1b2c3d",
+		"p1_i1_ip":          "172.20.0.2",
+		"p1_i1_port_80":     "8080",
+		"p1_i1_port_53_udp": "5353",
+		"web_container":     "web-1",
+		"web_ip":            "172.20.0.3",
+	}
+	assert.Equal(t, expected, EndpointsVariables(endpoints))
+}
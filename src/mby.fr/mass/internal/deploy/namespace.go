@@ -0,0 +1,196 @@
+package deploy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"mby.fr/mass/internal/interrupt"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/engine"
+)
+
+// Isolated deploy namespace. Container and compose project names are suffixed and
+// image containers join the namespace network. The default namespace has no suffix.
+type Namespace struct {
+	Suffix string
+}
+
+var DefaultNamespace = Namespace{}
+
+// Namespace with a unique suffix starting with prefix
+func NewNamespace(prefix string) (ns Namespace, err error) {
+	random := make([]byte, 4)
+	_, err = rand.Read(random)
+	if err != nil {
+		return
+	}
+	ns.Suffix = prefix + "_" + hex.EncodeToString(random)
+	return
+}
+
+func (n Namespace) IsDefault() bool {
+	return n.Suffix == ""
+}
+
+func (n Namespace) name(base string) string {
+	if n.IsDefault() {
+		return base
+	}
+	return base + "_" + n.Suffix
+}
+
+// Network of the namespace. Empty for the default namespace.
+func (n Namespace) Network() string {
+	if n.IsDefault() {
+		return ""
+	}
+	return "mass_" + n.Suffix
+}
+
+// Docker run args attaching a container to the namespace network with an alias
+func (n Namespace) runArgs(alias string) (args []string) {
+	if n.IsDefault() {
+		return
+	}
+	args = append(args, "--network", n.Network(), "--network-alias", alias)
+	// Namespaced containers are removed on interruption
+	for labelKey, labelValue := range interrupt.TemporaryLabels() {
+		args = append(args, "--label", labelKey+"="+labelValue)
+	}
+	return
+}
+
+func engineClient() (*engine.Client, error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return nil, err
+	}
+	return ss.EngineClient()
+}
+
+// Create the namespace network
+func (n Namespace) Create() (err error) {
+	if n.IsDefault() {
+		return
+	}
+	client, err := engineClient()
+	if err != nil {
+		return
+	}
+	labels := interrupt.TemporaryLabels()
+	if client != nil {
+		_, err = client.NetworkCreate(n.Network(), labels)
+		return
+	}
+	params := []string{"network", "create"}
+	for labelKey, labelValue := range labels {
+		params = append(params, "--label", labelKey+"="+labelValue)
+	}
+	params = append(params, n.Network())
+	return exec.Command("docker", params...).Run()
+}
+
+// Remove the namespace network. Its containers must be removed first.
+func (n Namespace) Remove() (err error) {
+	if n.IsDefault() {
+		return
+	}
+	client, err := engineClient()
+	if err != nil {
+		return
+	}
+	if client != nil {
+		return client.NetworkRemove(n.Network())
+	}
+	return exec.Command("docker", "network", "rm", n.Network()).Run()
+}
+
+// Write logs and inspect output of the resources containers in dir
+func (n Namespace) CollectDiagnostics(dir string, rs ...resources.Resourcer) (err error) {
+	client, err := engineClient()
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, r := range rs {
+		ctNames, err := n.containerNames(client, r)
+		if err != nil {
+			return err
+		}
+		names = append(names, ctNames...)
+	}
+	for _, name := range names {
+		logs, inspect := containerDiagnostics(client, name)
+		err = os.WriteFile(filepath.Join(dir, name+".log"), logs, 0644)
+		if err != nil {
+			return
+		}
+		err = os.WriteFile(filepath.Join(dir, name+".inspect.json"), inspect, 0644)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Names of the containers deployed for a resource in the namespace
+func (n Namespace) containerNames(client *engine.Client, r resources.Resourcer) (names []string, err error) {
+	switch res := r.(type) {
+	case *resources.Project:
+		return n.containerNames(client, *res)
+	case *resources.Image:
+		return n.containerNames(client, *res)
+	case resources.Image:
+		name, err := containerName(n, res)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	case resources.Project:
+		projectName, err := composeProjectName(n, res)
+		if err != nil {
+			return nil, err
+		}
+		labels := map[string]string{"com.docker.compose.project": projectName}
+		if client != nil {
+			return client.ContainerList(labels)
+		}
+		out, err := exec.Command("docker", "ps", "-a", "--format", "{{.Names}}", "--filter", "label=com.docker.compose.project="+projectName).Output()
+		if err != nil {
+			return nil, err
+		}
+		names = strings.Fields(string(out))
+	}
+	return
+}
+
+// Logs and inspect output of a container. Errors are reported in the outputs.
+func containerDiagnostics(client *engine.Client, name string) (logs, inspect []byte) {
+	var err error
+	if client != nil {
+		var b strings.Builder
+		err = client.ContainerLogs(name, &b, &b, false)
+		logs = []byte(b.String())
+		if err == nil {
+			inspect, err = client.ContainerInspectRaw(name)
+		}
+	} else {
+		logs, err = exec.Command("docker", "logs", name).CombinedOutput()
+		if err == nil {
+			inspect, err = exec.Command("docker", "inspect", name).CombinedOutput()
+		}
+	}
+	if err != nil {
+		logs = append(logs, []byte("\n"+err.Error()+"\n")...)
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/interrupt"
+)
+
+func TestNamespace(t *testing.T) {
+	assert.True(t, DefaultNamespace.IsDefault(), "default namespace should be default")
+	assert.Equal(t, "foo", DefaultNamespace.name("foo"), "default namespace should not suffix names")
+	assert.Equal(t, "", DefaultNamespace.Network(), "default namespace should not have a network")
+	assert.Empty(t, DefaultNamespace.runArgs("foo"), "default namespace should not have run args")
+
+	ns, err := NewNamespace("test")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ns.IsDefault(), "new namespace should not be default")
+	assert.True(t, strings.HasPrefix(ns.Suffix, "test_"), "bad suffix: %s", ns.Suffix)
+	assert.Len(t, ns.Suffix, len("test_")+8, "bad suffix length")
+	assert.Equal(t, "foo_"+ns.Suffix, ns.name("foo"))
+	assert.Equal(t, "mass_"+ns.Suffix, ns.Network())
+
+	args := ns.runArgs("i1")
+	assert.Equal(t, []string{"--network", ns.Network(), "--network-alias", "i1"}, args[:4])
+	for labelKey, labelValue := range interrupt.TemporaryLabels() {
+		assert.Contains(t, args, labelKey+"="+labelValue, "namespaced containers should be temporary")
+	}
+
+	other, err := NewNamespace("test")
+	require.NoError(t, err, "should not error")
+	assert.NotEqual(t, ns.Suffix, other.Suffix, "namespaces should be unique")
+}
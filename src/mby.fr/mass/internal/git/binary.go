@@ -0,0 +1,126 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log format separating fields by unit separators and commits by record separators
+const logFormat = "--format=%H%x1f%an%x1f%at%x1f%B%x1e"
+
+type binaryRepository struct {
+	root string
+}
+
+func openBinary(dir string) (repo binaryRepository, err error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return repo, fmt.Errorf("%w: %s", NotARepository, dir)
+	}
+	repo.root = strings.TrimSpace(string(out))
+	return
+}
+
+// Run git in the repository root returning its trimmed stdout
+func (r binaryRepository) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Absolute paths as git runs in the repository root
+func absPaths(paths ...string) (abs []string, err error) {
+	for _, path := range paths {
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return
+		}
+		abs = append(abs, path)
+	}
+	return
+}
+
+func (r binaryRepository) Revision() (string, error) {
+	return r.git("rev-parse", "HEAD")
+}
+
+func (r binaryRepository) IsClean(path string) (bool, error) {
+	paths, err := absPaths(path)
+	if err != nil {
+		return false, err
+	}
+	out, err := r.git(append([]string{"status", "--porcelain", "--"}, paths...)...)
+	return out == "", err
+}
+
+func (r binaryRepository) Commit(message string, paths ...string) (string, error) {
+	paths, err := absPaths(paths...)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.git(append([]string{"add", "--"}, paths...)...)
+	if err != nil {
+		return "", err
+	}
+	// Only commit paths, keeping other staged changes
+	_, err = r.git(append([]string{"commit", "-m", message, "--"}, paths...)...)
+	if err != nil {
+		return "", err
+	}
+	return r.Revision()
+}
+
+func (r binaryRepository) Tag(name, message string) error {
+	_, err := r.git("tag", "-a", name, "-m", message)
+	return err
+}
+
+func (r binaryRepository) Tags(prefix string) ([]string, error) {
+	out, err := r.git("tag", "--list", prefix+"*")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func (r binaryRepository) Log(since string, paths ...string) (commits []Commit, err error) {
+	paths, err = absPaths(paths...)
+	if err != nil {
+		return
+	}
+	revisions := "HEAD"
+	if since != "" {
+		revisions = since + "..HEAD"
+	}
+	out, err := r.git(append([]string{"log", logFormat, revisions, "--"}, paths...)...)
+	if err != nil {
+		return
+	}
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		commit := Commit{Hash: fields[0], Author: fields[1], Date: time.Unix(timestamp, 0)}
+		commit.Subject, commit.Body = splitMessage(fields[3])
+		commits = append(commits, commit)
+	}
+	return
+}
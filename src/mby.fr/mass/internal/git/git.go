@@ -0,0 +1,103 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Git backends
+const (
+	BinaryBackend = "binary" // Run the local git binary
+	GoBackend     = "go"     // Use a pure Go git implementation
+)
+
+const shortRevisionLength = 7
+
+var UnknownBackend error = fmt.Errorf("Unknown git backend")
+var NotARepository error = fmt.Errorf("Not in a git repository")
+var DirtyTree error = fmt.Errorf("Git tree is not clean")
+
+// Local git repository. Paths are absolute or relative to the working dir.
+type Repository interface {
+	// Full SHA of HEAD
+	Revision() (string, error)
+	// No uncommitted nor untracked change under path
+	IsClean(path string) (bool, error)
+	// Commit paths changes and return the commit SHA
+	Commit(message string, paths ...string) (string, error)
+	// Create an annotated tag on HEAD
+	Tag(name, message string) error
+	// Tag names starting with prefix
+	Tags(prefix string) ([]string, error)
+	// Commits reachable from HEAD but not from since touching paths, newest first.
+	// All history is logged if since is empty.
+	Log(since string, paths ...string) ([]Commit, error)
+}
+
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string // First line of the message
+	Body    string // Message after the subject
+}
+
+// Annotated tag of a released resource version, e.g. myProject/myImage/v1.2.0
+func ReleaseTag(name, version string) string {
+	return ReleaseTagPrefix(name) + version
+}
+
+func ReleaseTagPrefix(name string) string {
+	return name + "/v"
+}
+
+// Split a commit message in subject and body
+func splitMessage(message string) (subject, body string) {
+	subject, body, _ = strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(subject), strings.TrimSpace(body)
+}
+
+// Open the repository containing dir
+func Open(backend, dir string) (Repository, error) {
+	switch backend {
+	case "", BinaryBackend:
+		return openBinary(dir)
+	case GoBackend:
+		return openGo(dir)
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownBackend, backend)
+	}
+}
+
+// Full SHA of HEAD of the repository containing dir or empty if not in a git repository
+func Revision(backend, dir string) string {
+	repo, err := Open(backend, dir)
+	if err != nil {
+		return ""
+	}
+	revision, err := repo.Revision()
+	if err != nil {
+		return ""
+	}
+	return revision
+}
+
+func ShortRevision(revision string) string {
+	if len(revision) > shortRevisionLength {
+		return revision[:shortRevisionLength]
+	}
+	return revision
+}
+
+// Return DirtyTree if path has uncommitted changes
+func CheckClean(repo Repository, path string) (err error) {
+	clean, err := repo.IsClean(path)
+	if err != nil {
+		return
+	}
+	if !clean {
+		err = fmt.Errorf("%w: %s", DirtyTree, path)
+	}
+	return
+}
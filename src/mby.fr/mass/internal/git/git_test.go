@@ -0,0 +1,170 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/test"
+)
+
+func initRepo(t *testing.T) string {
+	dir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	err = os.MkdirAll(filepath.Join(dir, "p1", "i1"), 0755)
+	require.NoError(t, err, "should not error")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	commands := [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "tester"},
+		{"config", "user.email", "tester@example.com"},
+		{"config", "commit.gpgsign", "false"},
+		{"config", "tag.gpgsign", "false"},
+	}
+	for _, args := range commands {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, "should not error: %s", out)
+	}
+	writeFile(t, filepath.Join(dir, "p1", "i1", "resource.yaml"), "version: 0.0.1-dev\n")
+	out, err := exec.Command("git", "-C", dir, "add", ".").CombinedOutput()
+	require.NoError(t, err, "should not error: %s", out)
+	out, err = exec.Command("git", "-C", dir, "commit", "-q", "-m", "init").CombinedOutput()
+	require.NoError(t, err, "should not error: %s", out)
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err, "should not error")
+}
+
+func TestOpenNotARepository(t *testing.T) {
+	dir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	err = os.MkdirAll(dir, 0755)
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+
+	for _, backend := range []string{BinaryBackend, GoBackend} {
+		_, err = Open(backend, dir)
+		assert.True(t, errors.Is(err, NotARepository), "should error with %s backend", backend)
+		assert.Equal(t, "", Revision(backend, dir), "should be empty with %s backend", backend)
+	}
+
+	_, err = Open("svn", dir)
+	assert.True(t, errors.Is(err, UnknownBackend), "should error")
+}
+
+func TestShortRevision(t *testing.T) {
+	assert.Equal(t, "0123456", ShortRevision("0123456789abcdef"))
+	assert.Equal(t, "0123", ShortRevision("0123"))
+	assert.Equal(t, "", ShortRevision(""))
+}
+
+func TestRepository(t *testing.T) {
+	for _, backend := range []string{BinaryBackend, GoBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := initRepo(t)
+			imageDir := filepath.Join(dir, "p1", "i1")
+			otherDir := filepath.Join(dir, "p1", "i2")
+
+			repo, err := Open(backend, imageDir)
+			require.NoError(t, err, "should not error")
+			initial, err := repo.Revision()
+			require.NoError(t, err, "should not error")
+			assert.Len(t, initial, 40)
+			assert.Equal(t, initial, Revision(backend, imageDir))
+
+			err = CheckClean(repo, imageDir)
+			assert.NoError(t, err, "should not error")
+
+			// Untracked file in another dir does not dirty the image dir
+			err = os.MkdirAll(otherDir, 0755)
+			require.NoError(t, err, "should not error")
+			writeFile(t, filepath.Join(otherDir, "resource.yaml"), "version: 0.0.1-dev\n")
+			err = CheckClean(repo, imageDir)
+			assert.NoError(t, err, "should not error")
+
+			resourceFile := filepath.Join(imageDir, "resource.yaml")
+			writeFile(t, resourceFile, "version: 0.0.1\n")
+			err = CheckClean(repo, imageDir)
+			assert.True(t, errors.Is(err, DirtyTree), "should error")
+
+			commit, err := repo.Commit("Release i1", resourceFile)
+			require.NoError(t, err, "should not error")
+			assert.NotEqual(t, initial, commit)
+			head, err := repo.Revision()
+			require.NoError(t, err, "should not error")
+			assert.Equal(t, commit, head)
+			err = CheckClean(repo, imageDir)
+			assert.NoError(t, err, "should not error")
+			clean, err := repo.IsClean(otherDir)
+			require.NoError(t, err, "should not error")
+			assert.False(t, clean, "other dir should not be committed")
+
+			err = repo.Tag("p1/i1/v0.0.1", "Release i1 0.0.1")
+			require.NoError(t, err, "should not error")
+			out, err := exec.Command("git", "-C", dir, "cat-file", "-t", "p1/i1/v0.0.1").Output()
+			require.NoError(t, err, "should not error")
+			assert.Equal(t, "tag\n", string(out), "should be an annotated tag")
+			out, err = exec.Command("git", "-C", dir, "rev-list", "-n", "1", "p1/i1/v0.0.1").Output()
+			require.NoError(t, err, "should not error")
+			assert.Equal(t, commit+"\n", string(out))
+
+			err = repo.Tag("p1/i1/v0.0.1", "Release i1 0.0.1")
+			assert.Error(t, err, "should error on existing tag")
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	for _, backend := range []string{BinaryBackend, GoBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := initRepo(t)
+			imageDir := filepath.Join(dir, "p1", "i1")
+			otherDir := filepath.Join(dir, "p1", "i2")
+			err := os.MkdirAll(otherDir, 0755)
+			require.NoError(t, err, "should not error")
+
+			repo, err := Open(backend, imageDir)
+			require.NoError(t, err, "should not error")
+			err = repo.Tag("p1/i1/v0.0.1", "Release i1 0.0.1")
+			require.NoError(t, err, "should not error")
+
+			writeFile(t, filepath.Join(imageDir, "Dockerfile"), "FROM alpine\n")
+			_, err = repo.Commit("feat(i1): add Dockerfile\n\nWith an alpine base.", filepath.Join(imageDir, "Dockerfile"))
+			require.NoError(t, err, "should not error")
+			writeFile(t, filepath.Join(otherDir, "Dockerfile"), "FROM alpine\n")
+			_, err = repo.Commit("fix(i2): add Dockerfile", filepath.Join(otherDir, "Dockerfile"))
+			require.NoError(t, err, "should not error")
+
+			commits, err := repo.Log("p1/i1/v0.0.1", imageDir)
+			require.NoError(t, err, "should not error")
+			require.Len(t, commits, 1)
+			assert.Equal(t, "feat(i1): add Dockerfile", commits[0].Subject)
+			assert.Equal(t, "With an alpine base.", commits[0].Body)
+			assert.Equal(t, "tester", commits[0].Author)
+			assert.Len(t, commits[0].Hash, 40)
+			assert.False(t, commits[0].Date.IsZero(), "should be dated")
+
+			commits, err = repo.Log("", imageDir, otherDir)
+			require.NoError(t, err, "should not error")
+			require.Len(t, commits, 3)
+			assert.Equal(t, "fix(i2): add Dockerfile", commits[0].Subject, "should be newest first")
+			assert.Equal(t, "init", commits[2].Subject)
+
+			tags, err := repo.Tags("p1/i1/")
+			require.NoError(t, err, "should not error")
+			assert.Equal(t, []string{"p1/i1/v0.0.1"}, tags)
+			tags, err = repo.Tags("p1/i2/")
+			require.NoError(t, err, "should not error")
+			assert.Empty(t, tags)
+		})
+	}
+}
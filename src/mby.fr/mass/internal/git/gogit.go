@@ -0,0 +1,205 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type goRepository struct {
+	root string
+	repo *gogit.Repository
+}
+
+func openGo(dir string) (repo goRepository, err error) {
+	r, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return repo, fmt.Errorf("%w: %s", NotARepository, dir)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return
+	}
+	return goRepository{wt.Filesystem.Root(), r}, nil
+}
+
+// Slash separated path relative to the repository root
+func (r goRepository) relPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// Resolve symlinks as the root is resolved, e.g. /tmp on macOS
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	root, err := filepath.EvalSymlinks(r.root)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(path), nil
+}
+
+func (r goRepository) Revision() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+func (r goRepository) IsClean(path string) (bool, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return false, err
+	}
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return false, err
+	}
+	for file, s := range status {
+		if rel != "." && file != rel && !strings.HasPrefix(file, rel+"/") {
+			continue
+		}
+		if s.Staging != gogit.Unmodified || s.Worktree != gogit.Unmodified {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// All staged changes are committed, not only paths changes
+func (r goRepository) Commit(message string, paths ...string) (string, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		rel, err := r.relPath(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = wt.Add(rel)
+		if err != nil {
+			return "", err
+		}
+	}
+	hash, err := wt.Commit(message, &gogit.CommitOptions{})
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+func (r goRepository) Tag(name, message string) error {
+	head, err := r.repo.Head()
+	if err != nil {
+		return err
+	}
+	_, err = r.repo.CreateTag(name, head.Hash(), &gogit.CreateTagOptions{Message: message})
+	return err
+}
+
+func (r goRepository) Tags(prefix string) (tags []string, err error) {
+	refs, err := r.repo.Tags()
+	if err != nil {
+		return
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if name := ref.Name().Short(); strings.HasPrefix(name, prefix) {
+			tags = append(tags, name)
+		}
+		return nil
+	})
+	return
+}
+
+// Commit hash of a revision, peeling annotated tags
+func (r goRepository) resolveCommit(revision string) (hash plumbing.Hash, err error) {
+	resolved, err := r.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return
+	}
+	if tag, err := r.repo.TagObject(*resolved); err == nil {
+		return tag.Target, nil
+	}
+	return *resolved, nil
+}
+
+// Hashes of commits reachable from a revision
+func (r goRepository) ancestors(revision string) (hashes map[plumbing.Hash]bool, err error) {
+	hashes = map[plumbing.Hash]bool{}
+	from, err := r.resolveCommit(revision)
+	if err != nil {
+		return
+	}
+	iter, err := r.repo.Log(&gogit.LogOptions{From: from})
+	if err != nil {
+		return
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		hashes[c.Hash] = true
+		return nil
+	})
+	return
+}
+
+func (r goRepository) Log(since string, paths ...string) (commits []Commit, err error) {
+	var excluded map[plumbing.Hash]bool
+	if since != "" {
+		excluded, err = r.ancestors(since)
+		if err != nil {
+			return
+		}
+	}
+	var rels []string
+	for _, path := range paths {
+		rel, err := r.relPath(path)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, rel)
+	}
+	filter := func(file string) bool {
+		for _, rel := range rels {
+			if rel == "." || file == rel || strings.HasPrefix(file, rel+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return
+	}
+	opts := &gogit.LogOptions{From: head.Hash()}
+	if len(rels) > 0 {
+		opts.PathFilter = filter
+	}
+	iter, err := r.repo.Log(opts)
+	if err != nil {
+		return
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if excluded[c.Hash] {
+			return nil
+		}
+		commit := Commit{Hash: c.Hash.String(), Author: c.Author.Name, Date: c.Author.When}
+		commit.Subject, commit.Body = splitMessage(c.Message)
+		commits = append(commits, commit)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,329 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mby.fr/mass/internal/build"
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/errorz"
+)
+
+const (
+	DotFormat     = "dot"
+	MermaidFormat = "mermaid"
+	TextFormat    = "text"
+)
+
+var UnknownFormat error = fmt.Errorf("Unknown graph format")
+
+type EdgeKind int
+
+const (
+	ContainsEdge = EdgeKind(iota)
+	DependsOnEdge
+)
+
+type Node struct {
+	Id      string
+	Label   string
+	Kind    string
+	Changed bool
+}
+
+type Edge struct {
+	From, To string
+	Kind     EdgeKind
+}
+
+type Graph struct {
+	Name  string
+	Nodes []Node
+	Edges []Edge
+}
+
+func (g *Graph) node(id string) (*Node, bool) {
+	for i := range g.Nodes {
+		if g.Nodes[i].Id == id {
+			return &g.Nodes[i], true
+		}
+	}
+	return nil, false
+}
+
+func (g *Graph) AddNode(n Node) {
+	if _, ok := g.node(n.Id); !ok {
+		g.Nodes = append(g.Nodes, n)
+	}
+}
+
+func (g *Graph) AddEdge(e Edge) {
+	for _, existing := range g.Edges {
+		if existing == e {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, e)
+}
+
+func (g Graph) children(id string, kind EdgeKind) (ids []string) {
+	for _, e := range g.Edges {
+		if e.From == id && e.Kind == kind {
+			ids = append(ids, e.To)
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+func (g Graph) roots() (ids []string) {
+	for _, n := range g.Nodes {
+		contained := false
+		for _, e := range g.Edges {
+			if e.To == n.Id && e.Kind == ContainsEdge {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			ids = append(ids, n.Id)
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+func nodeId(r resources.Resourcer) string {
+	return r.QualifiedName()
+}
+
+func resourceNode(r resources.Resourcer) Node {
+	return Node{Id: nodeId(r), Label: r.Name(), Kind: r.Kind().String()}
+}
+
+func workspaceNode() (n Node, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	name := ss.Settings().Name
+	n = Node{Id: "workspace/" + name, Label: name, Kind: "workspace"}
+	return
+}
+
+func (g *Graph) addImage(root Node, image resources.Image, workspaceImages []resources.Image, markChanges bool, errors *errorz.Aggregated) {
+	project := resourceNode(image.Project)
+	g.AddNode(project)
+	g.AddEdge(Edge{root.Id, project.Id, ContainsEdge})
+
+	node := resourceNode(image)
+	if _, ok := g.node(node.Id); ok {
+		return
+	}
+	if markChanges {
+		changed, _, err := change.DoesImageChanged(image)
+		if err != nil {
+			errors.Add(err)
+		}
+		node.Changed = changed
+	}
+	g.AddNode(node)
+	g.AddEdge(Edge{project.Id, node.Id, ContainsEdge})
+
+	deps, errs := build.Dependencies(image, workspaceImages)
+	errors.Concat(errs)
+	for _, dep := range deps {
+		g.addImage(root, dep, workspaceImages, markChanges, errors)
+		g.AddEdge(Edge{node.Id, nodeId(dep), DependsOnEdge})
+		if dep.Project.Name() != image.Project.Name() {
+			g.AddEdge(Edge{project.Id, nodeId(dep.Project), DependsOnEdge})
+		}
+	}
+}
+
+// Build the graph of resources with their images dependencies.
+// Images dependencies not in resources are added to the graph.
+func Build(res []resources.Resourcer, markChanges bool) (g Graph, errors errorz.Aggregated) {
+	root, err := workspaceNode()
+	if err != nil {
+		errors.Add(err)
+		return
+	}
+	g.Name = root.Label
+	g.AddNode(root)
+
+	if markChanges {
+		err = change.Init()
+		if err != nil {
+			errors.Add(err)
+			return
+		}
+	}
+
+	workspaceImages, err := resources.ListImages()
+	if err != nil {
+		errors.Add(err)
+		return
+	}
+
+	for _, r := range res {
+		switch v := r.(type) {
+		case *resources.Env:
+			r = *v
+		case *resources.Project:
+			r = *v
+		case *resources.Image:
+			r = *v
+		}
+
+		switch v := r.(type) {
+		case resources.Env:
+			node := resourceNode(v)
+			g.AddNode(node)
+			g.AddEdge(Edge{root.Id, node.Id, ContainsEdge})
+		case resources.Project:
+			node := resourceNode(v)
+			g.AddNode(node)
+			g.AddEdge(Edge{root.Id, node.Id, ContainsEdge})
+			images, err := v.Images()
+			if err != nil {
+				errors.Add(err)
+				continue
+			}
+			for _, i := range images {
+				g.addImage(root, *i, workspaceImages, markChanges, &errors)
+			}
+		case resources.Image:
+			g.addImage(root, v, workspaceImages, markChanges, &errors)
+		}
+	}
+	return
+}
+
+var nonIdChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+func mermaidId(id string) string {
+	return nonIdChars.ReplaceAllString(id, "_")
+}
+
+func sortedNodes(g Graph) []Node {
+	nodes := append([]Node{}, g.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Id < nodes[j].Id
+	})
+	return nodes
+}
+
+func sortedEdges(g Graph) []Edge {
+	edges := append([]Edge{}, g.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Kind != edges[j].Kind {
+			return edges[i].Kind < edges[j].Kind
+		}
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+func RenderDot(w io.Writer, g Graph) (err error) {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "digraph %q {\n", g.Name)
+	for _, n := range sortedNodes(g) {
+		attrs := fmt.Sprintf("label=%q", n.Label)
+		switch n.Kind {
+		case "workspace":
+			attrs += " shape=folder"
+		case "project":
+			attrs += " shape=tab"
+		case "image":
+			attrs += " shape=box"
+		case "env":
+			attrs += " shape=ellipse"
+		}
+		if n.Changed {
+			attrs += " style=filled fillcolor=orange"
+		}
+		fmt.Fprintf(&b, "\t%q [%s];\n", n.Id, attrs)
+	}
+	for _, e := range sortedEdges(g) {
+		if e.Kind == DependsOnEdge {
+			fmt.Fprintf(&b, "\t%q -> %q [style=dashed label=\"depends on\"];\n", e.From, e.To)
+		} else {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", e.From, e.To)
+		}
+	}
+	b.WriteString("}\n")
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func RenderMermaid(w io.Writer, g Graph) (err error) {
+	b := strings.Builder{}
+	b.WriteString("graph TD\n")
+	for _, n := range sortedNodes(g) {
+		fmt.Fprintf(&b, "\t%s[\"%s %s\"]", mermaidId(n.Id), n.Kind, n.Label)
+		if n.Changed {
+			b.WriteString(":::changed")
+		}
+		b.WriteString("\n")
+	}
+	for _, e := range sortedEdges(g) {
+		if e.Kind == DependsOnEdge {
+			fmt.Fprintf(&b, "\t%s -.->|depends on| %s\n", mermaidId(e.From), mermaidId(e.To))
+		} else {
+			fmt.Fprintf(&b, "\t%s --> %s\n", mermaidId(e.From), mermaidId(e.To))
+		}
+	}
+	b.WriteString("\tclassDef changed fill:#f96\n")
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func renderTextNode(b *strings.Builder, g Graph, id string, depth int) {
+	n, _ := g.node(id)
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(b, "%s%s %s", indent, n.Kind, n.Label)
+	if n.Changed {
+		b.WriteString(" (changed)")
+	}
+	b.WriteString("\n")
+	for _, dep := range g.children(id, DependsOnEdge) {
+		if d, ok := g.node(dep); ok {
+			fmt.Fprintf(b, "%s  -> depends on %s %s\n", indent, d.Kind, d.Label)
+		}
+	}
+	for _, child := range g.children(id, ContainsEdge) {
+		renderTextNode(b, g, child, depth+1)
+	}
+}
+
+func RenderText(w io.Writer, g Graph) (err error) {
+	b := strings.Builder{}
+	for _, root := range g.roots() {
+		renderTextNode(&b, g, root, 0)
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func Render(w io.Writer, g Graph, format string) (err error) {
+	switch format {
+	case DotFormat:
+		err = RenderDot(w, g)
+	case MermaidFormat:
+		err = RenderMermaid(w, g)
+	case TextFormat, "":
+		err = RenderText(w, g)
+	default:
+		err = fmt.Errorf("%w: %s", UnknownFormat, format)
+	}
+	return
+}
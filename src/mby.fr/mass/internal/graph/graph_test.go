@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+)
+
+func sampleGraph() (g Graph) {
+	g.Name = "wks"
+	g.AddNode(Node{Id: "workspace/wks", Label: "wks", Kind: "workspace"})
+	g.AddNode(Node{Id: "project/p1", Label: "p1", Kind: "project"})
+	g.AddNode(Node{Id: "image/p1/a", Label: "p1/a", Kind: "image", Changed: true})
+	g.AddNode(Node{Id: "image/p1/b", Label: "p1/b", Kind: "image"})
+	g.AddEdge(Edge{"workspace/wks", "project/p1", ContainsEdge})
+	g.AddEdge(Edge{"project/p1", "image/p1/b", ContainsEdge})
+	g.AddEdge(Edge{"project/p1", "image/p1/a", ContainsEdge})
+	g.AddEdge(Edge{"image/p1/a", "image/p1/b", DependsOnEdge})
+	// Duplicates are ignored
+	g.AddEdge(Edge{"image/p1/a", "image/p1/b", DependsOnEdge})
+	g.AddNode(Node{Id: "image/p1/b", Label: "p1/b", Kind: "image"})
+	return
+}
+
+func TestRenderText(t *testing.T) {
+	b := strings.Builder{}
+	err := Render(&b, sampleGraph(), TextFormat)
+	require.NoError(t, err, "should not error")
+	expected := `workspace wks
+  project p1
+    image p1/a (changed)
+      -> depends on image p1/b
+    image p1/b
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestRenderDot(t *testing.T) {
+	b := strings.Builder{}
+	err := Render(&b, sampleGraph(), DotFormat)
+	require.NoError(t, err, "should not error")
+	expected := `digraph "wks" {
+	"image/p1/a" [label="p1/a" shape=box style=filled fillcolor=orange];
+	"image/p1/b" [label="p1/b" shape=box];
+	"project/p1" [label="p1" shape=tab];
+	"workspace/wks" [label="wks" shape=folder];
+	"project/p1" -> "image/p1/a";
+	"project/p1" -> "image/p1/b";
+	"workspace/wks" -> "project/p1";
+	"image/p1/a" -> "image/p1/b" [style=dashed label="depends on"];
+}
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestRenderMermaid(t *testing.T) {
+	b := strings.Builder{}
+	err := Render(&b, sampleGraph(), MermaidFormat)
+	require.NoError(t, err, "should not error")
+	expected := `graph TD
+	image_p1_a["image p1/a"]:::changed
+	image_p1_b["image p1/b"]
+	project_p1["project p1"]
+	workspace_wks["workspace wks"]
+	project_p1 --> image_p1_a
+	project_p1 --> image_p1_b
+	workspace_wks --> project_p1
+	image_p1_a -.->|depends on| image_p1_b
+	classDef changed fill:#f96
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	b := strings.Builder{}
+	err := Render(&b, sampleGraph(), "foo")
+	assert.ErrorIs(t, err, UnknownFormat)
+}
+
+func TestBuild(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	for _, p := range []string{"p1", "p2"} {
+		_, err := resources.Init[resources.Project](filepath.Join(wksPath, p))
+		require.NoError(t, err, "should not error")
+	}
+	base, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "base"))
+	require.NoError(t, err, "should not error")
+	web, err := resources.Init[resources.Image](filepath.Join(wksPath, "p2", "web"))
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(web.AbsBuildFile(), []byte("FROM p1/base:0.0.1-dev\n"), 0644)
+	require.NoError(t, err, "should not error")
+
+	g, errors := Build([]resources.Resourcer{web}, true)
+	require.False(t, errors.GotError(), "should not error: %s", errors)
+
+	assert.Contains(t, g.Edges, Edge{"image/p2/web", "image/p1/base", DependsOnEdge})
+	assert.Contains(t, g.Edges, Edge{"project/p2", "project/p1", DependsOnEdge})
+	assert.Contains(t, g.Edges, Edge{"project/p1", "image/p1/base", ContainsEdge})
+	n, ok := g.node("image/" + base.Name())
+	require.True(t, ok, "dependency should be in graph")
+	assert.True(t, n.Changed, "never built image should be changed")
+}
@@ -0,0 +1,99 @@
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	// Label of temporary containers, valued with the pid of the mass process which created them
+	TemporaryLabel = "mass.temporary"
+
+	InterruptedExitCode = 130 // 128 + SIGINT
+	ForcedExitCode      = 131
+)
+
+var (
+	ctx, cancel = context.WithCancel(context.Background())
+	interrupted int32
+	exit        = os.Exit
+
+	cleanupsLock sync.Mutex
+	cleanups     = map[int]func(){}
+	nextCleanup  int
+)
+
+// Context of in-flight actions cancelled on interruption
+func Context() context.Context {
+	return ctx
+}
+
+func Interrupted() bool {
+	return atomic.LoadInt32(&interrupted) == 1
+}
+
+// Labels identifying temporary containers created by this process
+func TemporaryLabels() map[string]string {
+	return map[string]string{TemporaryLabel: strconv.Itoa(os.Getpid())}
+}
+
+// Register a cleanup called on interruption, e.g. to tear down a namespace.
+// Call the returned func to unregister it once the cleanup is no longer needed.
+func OnInterrupt(cleanup func()) (unregister func()) {
+	cleanupsLock.Lock()
+	defer cleanupsLock.Unlock()
+	id := nextCleanup
+	nextCleanup++
+	cleanups[id] = cleanup
+	return func() {
+		cleanupsLock.Lock()
+		defer cleanupsLock.Unlock()
+		delete(cleanups, id)
+	}
+}
+
+// Call registered cleanups, last registered first
+func runCleanups() {
+	cleanupsLock.Lock()
+	var registered []func()
+	for id := nextCleanup - 1; id >= 0; id-- {
+		if cleanup, ok := cleanups[id]; ok {
+			registered = append(registered, cleanup)
+		}
+	}
+	cleanupsLock.Unlock()
+	for _, cleanup := range registered {
+		cleanup()
+	}
+}
+
+// On first signal cancel the context, call registered cleanups then cleanup and exit with InterruptedExitCode.
+// A second signal exits immediately with ForcedExitCode.
+func Handle(cleanup func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		atomic.StoreInt32(&interrupted, 1)
+		cancel()
+		go func() {
+			<-signals
+			exit(ForcedExitCode)
+		}()
+		runCleanups()
+		cleanup()
+		exit(InterruptedExitCode)
+	}()
+}
+
+// Block if interrupted to let the handler cleanup and exit with its own exit code
+func WaitIfInterrupted() {
+	if Interrupted() {
+		select {}
+	}
+}
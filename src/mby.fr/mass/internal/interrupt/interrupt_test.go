@@ -0,0 +1,66 @@
+package interrupt
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemporaryLabels(t *testing.T) {
+	assert.Equal(t, map[string]string{TemporaryLabel: strconv.Itoa(os.Getpid())}, TemporaryLabels())
+}
+
+func TestOnInterrupt(t *testing.T) {
+	var called []string
+	unregisterFirst := OnInterrupt(func() { called = append(called, "first") })
+	defer unregisterFirst()
+	unregister := OnInterrupt(func() { called = append(called, "unregistered") })
+	unregisterLast := OnInterrupt(func() { called = append(called, "last") })
+	defer unregisterLast()
+	unregister()
+
+	runCleanups()
+	assert.Equal(t, []string{"last", "first"}, called, "registered cleanups should run last registered first")
+}
+
+func TestHandle(t *testing.T) {
+	exitCodes := make(chan int, 2)
+	exit = func(code int) {
+		exitCodes <- code
+	}
+	defer func() { exit = os.Exit }()
+
+	cleaned := make(chan bool)
+	Handle(func() {
+		// Second signal received while cleaning up
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		<-cleaned
+	})
+	assert.False(t, Interrupted(), "should not be interrupted yet")
+	assert.NoError(t, Context().Err(), "context should not be cancelled yet")
+
+	err := syscall.Kill(os.Getpid(), syscall.SIGINT)
+	require.NoError(t, err, "should not error")
+
+	select {
+	case code := <-exitCodes:
+		assert.Equal(t, ForcedExitCode, code, "second signal should force exit")
+	case <-time.After(time.Second):
+		t.Fatal("second signal should force exit")
+	}
+	assert.True(t, Interrupted(), "should be interrupted")
+	assert.Error(t, Context().Err(), "context should be cancelled")
+
+	close(cleaned)
+	select {
+	case code := <-exitCodes:
+		assert.Equal(t, InterruptedExitCode, code, "should exit after cleanup")
+	case <-time.After(time.Second):
+		t.Fatal("should exit after cleanup")
+	}
+}
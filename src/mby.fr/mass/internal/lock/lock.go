@@ -0,0 +1,200 @@
+package lock
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/trust"
+)
+
+// Lock file in the workspace dir
+const DefaultLockFile = "mass.lock"
+
+// Drifting fields
+const (
+	MissingField       = "lock"
+	FullNameField      = "fullName"
+	ImageIdField       = "imageId"
+	SignatureField     = "signature"
+	BuildArgsHashField = "buildArgsHash"
+)
+
+var Drifted error = fmt.Errorf("Working tree drifted from lock")
+
+var lockFileMutex = &sync.Mutex{}
+
+// Built or pulled image of a version and signature
+type Entry struct {
+	FullName      string    `yaml:"fullName"`
+	ImageId       string    `yaml:"imageId"`
+	Signature     string    `yaml:"signature"`
+	BuildArgsHash string    `yaml:"buildArgsHash"`
+	Date          time.Time `yaml:"date"`
+}
+
+// Entries by image name
+type Lock struct {
+	Images map[string]Entry `yaml:"images"`
+}
+
+// Difference between a lock entry and the working tree
+type Drift struct {
+	Image   string
+	Field   string
+	Locked  string
+	Current string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s: locked %q, current %q", d.Image, d.Field, d.Locked, d.Current)
+}
+
+func Path() (path string, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	return filepath.Join(ss.WorkspaceDir(), DefaultLockFile), nil
+}
+
+// Hex encoded hash of merged config build args
+func buildArgsHash(image resources.Image) (hash string, err error) {
+	conf, err := resources.MergedConfig(image)
+	if err != nil {
+		return
+	}
+	sign, err := trust.SignObject(conf.BuildArgs)
+	if err != nil {
+		return
+	}
+	return hex.EncodeToString([]byte(sign)), nil
+}
+
+// Entry of the working tree image with its local image id
+func NewEntry(image resources.Image, imageId string) (e Entry, err error) {
+	e.FullName = image.FullName()
+	e.ImageId = imageId
+	e.Date = time.Now()
+	e.Signature, err = change.ImageSignature(image)
+	if err != nil {
+		return
+	}
+	e.BuildArgsHash, err = buildArgsHash(image)
+	return
+}
+
+// Empty lock if the lock file does not exist
+func Read() (l Lock, err error) {
+	path, err := Path()
+	if err != nil {
+		return
+	}
+	l.Images = map[string]Entry{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return
+	}
+	err = yaml.Unmarshal(content, &l)
+	if l.Images == nil {
+		l.Images = map[string]Entry{}
+	}
+	return
+}
+
+func Write(l Lock) (err error) {
+	path, err := Path()
+	if err != nil {
+		return
+	}
+	content, err := yaml.Marshal(l)
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// Add or replace image entries in the lock file
+func Update(entries map[string]Entry) (err error) {
+	lockFileMutex.Lock()
+	defer lockFileMutex.Unlock()
+	l, err := Read()
+	if err != nil {
+		return
+	}
+	for name, e := range entries {
+		l.Images[name] = e
+	}
+	return Write(l)
+}
+
+// Drifts of the working tree image from its lock entry. Image id is not compared if empty.
+func (l Lock) Verify(image resources.Image, imageId string) (drifts []Drift, err error) {
+	locked, ok := l.Images[image.Name()]
+	if !ok {
+		return []Drift{{Image: image.Name(), Field: MissingField, Current: image.FullName()}}, nil
+	}
+	current, err := NewEntry(image, imageId)
+	if err != nil {
+		return
+	}
+	compare := func(field, locked, current string) {
+		if locked != current {
+			drifts = append(drifts, Drift{Image: image.Name(), Field: field, Locked: locked, Current: current})
+		}
+	}
+	compare(FullNameField, locked.FullName, current.FullName)
+	if imageId != "" {
+		compare(ImageIdField, locked.ImageId, current.ImageId)
+	}
+	compare(SignatureField, locked.Signature, current.Signature)
+	compare(BuildArgsHashField, locked.BuildArgsHash, current.BuildArgsHash)
+	return
+}
+
+// Images of resources, projects are expanded to their images
+func Images(res []resources.Resourcer) (images []resources.Image, err error) {
+	byName := map[string]resources.Image{}
+	for _, r := range res {
+		switch v := r.(type) {
+		case *resources.Image:
+			byName[v.Name()] = *v
+		case resources.Image:
+			byName[v.Name()] = v
+		case *resources.Project:
+			projectImages, err := v.Images()
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range projectImages {
+				byName[i.Name()] = *i
+			}
+		case resources.Project:
+			projectImages, err := v.Images()
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range projectImages {
+				byName[i.Name()] = *i
+			}
+		}
+	}
+	for _, image := range byName {
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Name() < images[j].Name()
+	})
+	return
+}
@@ -0,0 +1,89 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestUpdateAndVerify(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	err := change.Init()
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	other, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i2"))
+	require.NoError(t, err, "should not error")
+
+	l, err := Read()
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, l.Images, "lock should be empty before any build")
+
+	entry, err := NewEntry(image, "sha256:1234")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, image.FullName(), entry.FullName)
+	assert.NotEmpty(t, entry.Signature)
+	assert.NotEmpty(t, entry.BuildArgsHash)
+	err = Update(map[string]Entry{image.Name(): entry})
+	require.NoError(t, err, "should not error")
+	assert.FileExists(t, filepath.Join(wksPath, DefaultLockFile))
+
+	l, err = Read()
+	require.NoError(t, err, "should not error")
+	drifts, err := l.Verify(image, "sha256:1234")
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, drifts, "should not drift")
+
+	drifts, err = l.Verify(other, "")
+	require.NoError(t, err, "should not error")
+	require.Len(t, drifts, 1)
+	assert.Equal(t, MissingField, drifts[0].Field)
+
+	// Source, build args and image changes
+	err = os.WriteFile(filepath.Join(image.AbsSourceDir(), "main.go"), []byte("package main\n"), 0644)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(filepath.Join(image.Dir(), config.DefaultConfigFile), []byte("buildargs:\n  foo: bar\n"), 0644)
+	require.NoError(t, err, "should not error")
+	image, err = resources.Read[resources.Image](image.Dir())
+	require.NoError(t, err, "should not error")
+	drifts, err = l.Verify(image, "sha256:5678")
+	require.NoError(t, err, "should not error")
+	var fields []string
+	for _, d := range drifts {
+		fields = append(fields, d.Field)
+	}
+	assert.Equal(t, []string{ImageIdField, SignatureField, BuildArgsHashField}, fields)
+
+	// Image id is not compared if unknown
+	drifts, err = l.Verify(image, "")
+	require.NoError(t, err, "should not error")
+	assert.Len(t, drifts, 2)
+}
+
+func TestImages(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	i2, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i2"))
+	require.NoError(t, err, "should not error")
+	_, err = resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	project, err := resources.Read[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+
+	images, err := Images([]resources.Resourcer{&i2, project})
+	require.NoError(t, err, "should not error")
+	require.Len(t, images, 2)
+	assert.Equal(t, "p1/i1", images[0].Name())
+	assert.Equal(t, "p1/i2", images[1].Name())
+}
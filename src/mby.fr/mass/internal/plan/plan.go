@@ -0,0 +1,68 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type Action string
+
+const (
+	BuildAction    Action = "build"
+	PullAction     Action = "pull"
+	TagAction      Action = "tag"
+	CreateAction   Action = "create"
+	RecreateAction Action = "recreate"
+	RemoveAction   Action = "remove"
+	NoneAction     Action = "none"
+)
+
+// What a phase would do on a resource and the commands it would run
+type Step struct {
+	Phase    string   `json:"phase" yaml:"phase"`
+	Resource string   `json:"resource" yaml:"resource"`
+	Action   Action   `json:"action" yaml:"action"`
+	Reason   string   `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Commands []string `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+type Plan []Step
+
+// Render a command line, quoting args which would be split by a shell
+func CommandLine(binary string, args ...string) string {
+	parts := []string{binary}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Write an aligned table of steps followed by the commands of each step
+func (p Plan) Write(w io.Writer) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tRESOURCE\tACTION\tREASON")
+	for _, s := range p {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Phase, s.Resource, s.Action, s.Reason)
+	}
+	err = tw.Flush()
+	if err != nil {
+		return
+	}
+
+	for _, s := range p {
+		if len(s.Commands) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s %s:\n", s.Phase, s.Resource)
+		for _, c := range s.Commands {
+			fmt.Fprintf(w, "  %s\n", c)
+		}
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandLine(t *testing.T) {
+	assert.Equal(t, "docker rm -f foo", CommandLine("docker", "rm", "-f", "foo"), "should not quote simple args")
+	assert.Equal(t, `docker run "-e=FOO=bar baz" img ""`, CommandLine("docker", "run", "-e=FOO=bar baz", "img", ""), "should quote args with spaces and empty args")
+}
+
+func TestWrite(t *testing.T) {
+	p := Plan{
+		{Phase: "build", Resource: "image/p1/i1", Action: BuildAction, Reason: "signature changed", Commands: []string{"docker build ."}},
+		{Phase: "up", Resource: "image/p1/i1", Action: NoneAction, Reason: "deploy signature unchanged"},
+	}
+	b := strings.Builder{}
+	err := p.Write(&b)
+	require.NoError(t, err, "should not error")
+
+	lines := strings.Split(b.String(), "\n")
+	assert.Equal(t, "PHASE  RESOURCE     ACTION  REASON", lines[0])
+	assert.Equal(t, "build  image/p1/i1  build   signature changed", lines[1])
+	assert.Equal(t, "up     image/p1/i1  none    deploy signature unchanged", lines[2])
+	assert.Contains(t, b.String(), "\nbuild image/p1/i1:\n  docker build .\n", "should list commands")
+	assert.NotContains(t, b.String(), "up image/p1/i1:", "should not list step without commands")
+}
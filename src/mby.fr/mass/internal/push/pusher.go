@@ -0,0 +1,211 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"mby.fr/mass/internal/build"
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/command"
+	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/mass/version"
+	"mby.fr/utils/engine"
+)
+
+var NotPushableResource error = fmt.Errorf("Not pushable resource")
+var NotReleased error = fmt.Errorf("Image version is neither released nor promoted")
+var NoRegistry error = fmt.Errorf("No registry configured")
+
+type Pusher interface {
+	// Dev versions are pushed if force. Released versions are pushed again from another build if allowOverwrite.
+	// Running pushes are aborted when ctx is done.
+	Push(ctx context.Context, force bool, allowOverwrite bool) error
+}
+
+func New(rs ...resources.Resourcer) (Pusher, error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return nil, err
+	}
+	registry, ok, err := ss.WorkingRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		env, _ := ss.WorkingEnv()
+		return nil, fmt.Errorf("%w for env: %s", NoRegistry, env)
+	}
+	client, err := ss.EngineClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []resources.Image
+	added := map[string]bool{}
+	for _, r := range rs {
+		imgs, err := pushableImages(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range imgs {
+			// Do not push an image twice
+			if !added[i.Name()] {
+				added[i.Name()] = true
+				images = append(images, i)
+			}
+		}
+	}
+	return ImagesPusher{client, registry, images}, nil
+}
+
+func pushableImages(r resources.Resourcer) (images []resources.Image, err error) {
+	switch res := r.(type) {
+	case *resources.Project:
+		return pushableImages(*res)
+	case *resources.Image:
+		return pushableImages(*res)
+	case resources.Project:
+		imgs, err := res.Images()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range imgs {
+			images = append(images, *i)
+		}
+	case resources.Image:
+		images = append(images, res)
+	default:
+		return nil, fmt.Errorf("%w: %s", NotPushableResource, r.QualifiedName())
+	}
+	return
+}
+
+// Push with the binary of the image build backend: images built by podman or buildah are not in docker storage
+func binary(image resources.Image) (string, error) {
+	builder, err := build.BackendName(image)
+	if err != nil {
+		return "", err
+	}
+	switch builder {
+	case build.PodmanBackend, build.BuildahBackend:
+		return builder, nil
+	default:
+		return "docker", nil
+	}
+}
+
+type ImagesPusher struct {
+	client   *engine.Client // If not nil push docker images with the engine API instead of the binary
+	registry settings.Registry
+	images   []resources.Image
+}
+
+// Only released or promoted versions are pushed unless forced.
+func checkPushable(image resources.Image, force bool) (err error) {
+	if force {
+		return
+	}
+	isDev, err := version.IsDev(image.Version())
+	if err != nil {
+		return
+	}
+	if isDev {
+		err = fmt.Errorf("%w: %s", NotReleased, image.FullName())
+	}
+	return
+}
+
+// Check all images before pushing anything
+func (p ImagesPusher) Push(ctx context.Context, force bool, allowOverwrite bool) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	for _, image := range p.images {
+		err = checkPushable(image, force)
+		if err != nil {
+			return
+		}
+		err = change.CheckPushImmutable(image, allowOverwrite)
+		if err != nil {
+			return
+		}
+	}
+	for _, image := range p.images {
+		err = p.pushImage(ctx, image)
+		if err != nil {
+			return
+		}
+		err = change.StorePushSignature(image)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p ImagesPusher) pushImage(ctx context.Context, image resources.Image) (err error) {
+	d := display.Service()
+	log := d.BufferedActionLogger("push", image.Name())
+	log.Info("Pushing image: %s ...", image.FullName())
+
+	bin, err := binary(image)
+	if err != nil {
+		return
+	}
+	if p.client != nil && bin == "docker" {
+		err = p.pushWithEngine(ctx, log, image)
+	} else {
+		err = p.pushWithBinary(ctx, bin, log, image)
+	}
+	if err != nil {
+		log.Flush()
+		return fmt.Errorf("Error pushing image %s : %w", image.FullName(), err)
+	}
+
+	log.Info("Push finished for image: %s .", image.FullName())
+	return
+}
+
+func (p ImagesPusher) params(binary string, image resources.Image) (params []string) {
+	if binary == "docker" {
+		if p.registry.CredentialsFile != "" {
+			// docker reads config.json in the --config directory
+			params = append(params, "--config", filepath.Dir(p.registry.CredentialsFile))
+		}
+		params = append(params, "push")
+	} else {
+		params = append(params, "push")
+		if p.registry.CredentialsFile != "" {
+			params = append(params, "--authfile", p.registry.CredentialsFile)
+		}
+		if p.registry.Insecure {
+			params = append(params, "--tls-verify=false")
+		}
+	}
+	params = append(params, image.FullName())
+	return
+}
+
+func (p ImagesPusher) pushWithBinary(ctx context.Context, binary string, log logger.ActionLogger, image resources.Image) (err error) {
+	pushParams := p.params(binary, image)
+	log.Debug("push params: %s", pushParams)
+	cmd := exec.Command(binary, pushParams...)
+	return command.RunLoggingContext(ctx, cmd, log)
+}
+
+func (p ImagesPusher) pushWithEngine(ctx context.Context, log logger.ActionLogger, image resources.Image) (err error) {
+	var auth string
+	if p.registry.CredentialsFile != "" {
+		auth, err = engine.RegistryAuth(p.registry.CredentialsFile, p.registry.Host)
+		if err != nil {
+			return
+		}
+	}
+	return p.client.Push(ctx, image.FullName(), auth, log.Out())
+}
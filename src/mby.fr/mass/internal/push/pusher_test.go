@@ -0,0 +1,104 @@
+package push
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/build"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+)
+
+func configureRegistry(t *testing.T, wksPath string) {
+	settingsFile := filepath.Join(wksPath, ".mass", "settings.yaml")
+	f, err := os.OpenFile(settingsFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err, "should not error")
+	defer f.Close()
+	_, err = f.WriteString("registries:\n  dev:\n    host: localhost:5000\n    namespace: team\n    insecure: true\n    credentialsFile: /creds/config.json\n")
+	require.NoError(t, err, "should not error")
+}
+
+func TestNewWithoutRegistry(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	_, err = New(image)
+	assert.ErrorIs(t, err, NoRegistry)
+	assert.Equal(t, "p1/i1:"+image.Version(), image.FullName(), "full name should not be qualified without registry")
+}
+
+func TestPushOnlyReleasedVersions(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	configureRegistry(t, wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	ss, err := settings.GetSettingsService()
+	require.NoError(t, err, "should not error")
+	registry, ok, err := ss.WorkingRegistry()
+	require.NoError(t, err, "should not error")
+	require.True(t, ok, "registry should be configured for dev env")
+	assert.Equal(t, "localhost:5000/team", registry.Prefix())
+	assert.Equal(t, "localhost:5000/team/p1/i1:"+image.Version(), image.FullName(), "full name should be qualified by registry")
+	assert.Equal(t, "p1/i1:"+image.Version(), image.LocalName())
+
+	pusher, err := New(image)
+	require.NoError(t, err, "should not error")
+	err = pusher.Push(context.Background(), false, false)
+	assert.ErrorIs(t, err, NotReleased, "dev version should not be pushed")
+
+	err = checkPushable(image, true)
+	assert.NoError(t, err, "forced dev version should be pushable")
+	_, _, err = image.Promote()
+	require.NoError(t, err, "should not error")
+	err = checkPushable(image, false)
+	assert.NoError(t, err, "promoted version should be pushable")
+	_, _, err = image.Release()
+	require.NoError(t, err, "should not error")
+	err = checkPushable(image, false)
+	assert.NoError(t, err, "released version should be pushable")
+}
+
+func TestPushParams(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	configureRegistry(t, wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	registry := settings.Registry{Host: "localhost:5000", Insecure: true, CredentialsFile: "/creds/config.json"}
+
+	p := ImagesPusher{registry: registry}
+	assert.Equal(t, []string{"--config", "/creds", "push", image.FullName()}, p.params("docker", image))
+	assert.Equal(t, []string{"push", "--authfile", "/creds/config.json", "--tls-verify=false", image.FullName()}, p.params("podman", image))
+}
+
+func TestBinary(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	cases := []struct {
+		builder, binary string
+	}{
+		{"", "docker"},
+		{build.BuildxBackend, "docker"},
+		{build.PodmanBackend, "podman"},
+		{build.BuildahBackend, "buildah"},
+	}
+	for _, c := range cases {
+		image.Builder = c.builder
+		bin, err := binary(image)
+		require.NoError(t, err, "should not error")
+		assert.Equal(t, c.binary, bin, "image builder %q should push with %s", c.builder, c.binary)
+	}
+}
@@ -37,6 +37,12 @@ func MergedConfig(res Resourcer) (conf *config.Config, err error) {
 			return nil, err
 		}
 	}
+	// Env tags are layered on top of project and image tags.
+	// Copy them because merging mutates envConfig maps.
+	envTags := config.Config{Tags: config.TagsConfig{}}
+	for k, v := range envConfig.Tags {
+		envTags.Tags[k] = v
+	}
 
 	switch r := res.(type) {
 	//case *Env, *Project, *Image:
@@ -66,6 +72,7 @@ func MergedConfig(res Resourcer) (conf *config.Config, err error) {
 			return nil, err
 		} else {
 			c := config.Merge(envConfig, pc)
+			c = config.Merge(c, envTags)
 			conf = &c
 		}
 	case Image:
@@ -88,6 +95,7 @@ func MergedConfig(res Resourcer) (conf *config.Config, err error) {
 		} else {
 			c = config.Merge(c, ic)
 		}
+		c = config.Merge(c, envTags)
 		conf = &c
 	}
 	return
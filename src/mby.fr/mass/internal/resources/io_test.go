@@ -173,3 +173,21 @@ func TestWriteThenRead(t *testing.T) {
 	assert.Equal(t, ProjectKind, loadedImage.Project.Kind(), "bad parent project kind")
 	assert.Equal(t, parentDir, loadedImage.Project.Dir(), "bad parent project dir")
 }
+
+func TestWriteThenReadTestRunner(t *testing.T) {
+	path, err := test.BuildRandTempPath()
+	os.MkdirAll(path, 0755)
+	defer os.RemoveAll(path)
+
+	i, err := buildImage(path)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "", i.TestRunner(), "test runner should be empty by default")
+	i.Runner = "command"
+	err = Write(i)
+	require.NoError(t, err, "should not error")
+
+	loadedImage, err := Read[Image](path)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "command", loadedImage.TestRunner(), "bad test runner")
+	assert.Equal(t, path+"/"+DefaultTestDir, loadedImage.AbsTestDir(), "test dir should not be lost")
+}
@@ -68,17 +68,23 @@ func (r base) Match(name string, k Kind) bool {
 
 type Tester interface {
 	AbsTestDir() string
+	TestRunner() string
 }
 
 type testable struct {
 	resource      Resourcer //`yaml:"base,inline"`
 	testDirectory string    `yaml:"testDirectory"`
+	Runner        string    `yaml:"testRunner,omitempty"` // Test runner: venom, command or container. Default to venom.
 }
 
 func (t testable) AbsTestDir() string {
 	return absResourcePath(t.resource.Dir(), t.testDirectory)
 }
 
+func (t testable) TestRunner() string {
+	return t.Runner
+}
+
 func (t testable) init() (err error) {
 	// Create test dir
 	err = os.MkdirAll(t.AbsTestDir(), 0755)
@@ -154,9 +160,11 @@ type Image struct {
 	testable    `yaml:"testable,inline"`
 	versionable `yaml:"versionable,inline"`
 
-	SourceDirectory string `yaml:"sourceDirectory"`
-	BuildFile       string `yaml:"buildFile"`
-	Project Project `yaml:"-"` // Ignore this field for yaml marshalling
+	SourceDirectory string   `yaml:"sourceDirectory"`
+	BuildFile       string   `yaml:"buildFile"`
+	DependsOn       []string `yaml:"dependsOn,omitempty"` // Explicit image dependencies not declared in BuildFile
+	Builder         string   `yaml:"builder,omitempty"`   // Override the settings build backend for this image
+	Project         Project  `yaml:"-"`                   // Ignore this field for yaml marshalling
 }
 
 func (i Image) init() (err error) {
@@ -182,7 +190,7 @@ func (i Image) init() (err error) {
 	// Init Build file
 	buildfileContent := ""
 	//buildfileContent := "FROM alpine\n"
-	_, err = file.SoftInitFile(i.BuildFile, buildfileContent)
+	_, err = file.SoftInitFile(i.AbsBuildFile(), buildfileContent)
 
 	return
 }
@@ -203,14 +211,44 @@ func (i Image) Name() string {
 	return i.Project.Name() + "/" + i.ImageName()
 }
 
-func (i Image) FullName() string {
+// Qualified name of an image includes its project name
+func (i Image) QualifiedName() string {
+	return fmt.Sprintf("%s/%s", i.Kind(), i.Name())
+}
+
+// Image tags cannot contain the + of versions build metadata
+func (i Image) tag() string {
 	if i.Version() != "" {
-		return strings.ToLower(i.Name()) + ":" + i.Version()
+		return strings.ReplaceAll(i.Version(), "+", "_")
 	} else {
-		return strings.ToLower(i.Name()) + ":latest"
+		return "latest"
 	}
 }
 
+// Image repository qualified by the working env registry if one is configured
+func (i Image) Repository() string {
+	repository := strings.ToLower(i.Name())
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return repository
+	}
+	registry, ok, err := ss.WorkingRegistry()
+	if err != nil || !ok {
+		return repository
+	}
+	return registry.Prefix() + "/" + repository
+}
+
+// Image reference qualified by the working env registry if one is configured
+func (i Image) FullName() string {
+	return i.Repository() + ":" + i.tag()
+}
+
+// Image reference without registry as referenced in other images BuildFile
+func (i Image) LocalName() string {
+	return strings.ToLower(i.Name()) + ":" + i.tag()
+}
+
 func (i Image) AbsoluteName() (name string, err error) {
 	ss, err := settings.GetSettingsService()
 	if err != nil {
@@ -224,6 +262,32 @@ func (i Image) Match(name string, k Kind) bool {
 	return i.base.Match(name, k) || name == i.ImageName() && (k == AllKind || k == i.Kind())
 }
 
+// Version changes are written in the image resource file
+
+func (i *Image) Bump(bumpMinor, bumpMajor bool) (toVer, fromVer string, err error) {
+	toVer, fromVer, err = i.versionable.Bump(bumpMinor, bumpMajor)
+	if err == nil {
+		err = Write(i)
+	}
+	return
+}
+
+func (i *Image) Promote() (toVer, fromVer string, err error) {
+	toVer, fromVer, err = i.versionable.Promote()
+	if err == nil {
+		err = Write(i)
+	}
+	return
+}
+
+func (i *Image) Release() (toVer, fromVer string, err error) {
+	toVer, fromVer, err = i.versionable.Release()
+	if err == nil {
+		err = Write(i)
+	}
+	return
+}
+
 /*
 func (i Image) GetVersionable() *versionable {
 	return &(i.versionable)
@@ -32,6 +32,9 @@ var InvalidArgument error = fmt.Errorf("Invalid argument error")
 // project1/image2 project1/image3
 // project1 image2 image3
 // project1
+// project1/* */api-* (glob selectors)
+// label:tier=backend (label selectors)
+// project1/* !project1/legacy (exclusions)
 func ResolveExpression(expressions string, expectedKinds ...Kind) (resources []Resourcer, aggErr errorz.Aggregated) {
 	splittedExpr, exprKinds, err := splitExpressions(expressions)
 	if err != nil {
@@ -82,16 +85,26 @@ func ResolveExpression(expressions string, expectedKinds ...Kind) (resources []R
 	}
 
 	// resolve all expressions versus all expr kinds
+	var exclusions []string
+	included := false
 	for _, expr := range splittedExpr {
-		res, errors := resolveExpresionForKinds(expr, *NewKindSet(exprKinds...))
+		if isExclusion(expr) {
+			exclusions = append(exclusions, expr)
+			continue
+		}
+		included = true
+		res, errors := resolveAnyExpressionForKinds(expr, *NewKindSet(exprKinds...))
 		//fmt.Printf("Resolved exprs: %s with kind: %s and found: %s\n", expr, exprKind, res)
 		if errors.GotError() {
 			aggErr.Concat(errors)
 			continue
 		}
-		resources = append(resources, res)
+		resources = appendDistinctResources(resources, res...)
 	}
 
+	resources, errors := applyExclusions(resources, exclusions, *NewKindSet(exprKinds...), included)
+	aggErr.Concat(errors)
+
 	return
 }
 
@@ -108,6 +121,11 @@ func splitExpressions(expressions string) (strippedExpressions []string, kinds [
 	firstExpr := splittedExpr[0]
 	firstExprIndex := 1
 
+	if isSelector(firstExpr) || isExclusion(firstExpr) {
+		// Selectors may contain commas but are not kinds
+		return splittedExpr, nil, nil
+	}
+
 	splittedFirstExpr := strings.Split(firstExpr, ",")
 
 	for _, firstExprPart := range splittedFirstExpr {
@@ -54,7 +54,6 @@ func buildScanner(rootPath string, resKind Kind, maxDepth int, c chan<- interfac
 			return fs.SkipDir
 		}
 
-		fmt.Println("scanning", path)
 		if d.Name() == DefaultResourceFile {
 			parentDir := filepath.Dir(path)
 			res, err := ReadResourcer(parentDir)
@@ -136,6 +135,18 @@ func Scan[T Resourcer](path string) (projects []T, err error) {
 	return ScanMaxDepth[T](path, -1)
 }
 
+func ScanEnvs(path string) (envs []Env, err error) {
+	return Scan[Env](path)
+}
+
+func ScanProjects(path string) (projects []Project, err error) {
+	return Scan[Project](path)
+}
+
+func ScanImages(path string) (images []Image, err error) {
+	return Scan[Image](path)
+}
+
 func scanResourcesFrom(fromDir string, resourceKind Kind, maxDepth int) (resources []Resourcer, err error) {
 	c := make(chan interface{})
 	finished := make(chan bool)
@@ -0,0 +1,189 @@
+package resources
+
+import (
+	"path"
+	"strings"
+
+	"mby.fr/utils/errorz"
+)
+
+const ExclusionPrefix = "!"
+const LabelSelectorPrefix = "label:"
+
+// Selector expressions match several resources
+// project1/*
+// */api-*
+// label:tier=backend
+// label:tier=backend,lang=go
+// label:tier
+func isSelector(expr string) bool {
+	return strings.HasPrefix(expr, LabelSelectorPrefix) || strings.ContainsAny(expr, "*?[")
+}
+
+func isExclusion(expr string) bool {
+	return strings.HasPrefix(expr, ExclusionPrefix)
+}
+
+// List all workspace resources of kinds
+func listResources(kinds KindSet) (res []Resourcer, err error) {
+	if kinds.Contains(EnvKind) {
+		envs, err := ListEnvs()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range envs {
+			res = append(res, r)
+		}
+	}
+	if kinds.Contains(ProjectKind) {
+		projects, err := ListProjects()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range projects {
+			res = append(res, r)
+		}
+	}
+	if kinds.Contains(ImageKind) {
+		images, err := ListImages()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range images {
+			res = append(res, r)
+		}
+	}
+	return
+}
+
+func matchLabels(r Resourcer, selector string) (ok bool, err error) {
+	conf, err := MergedConfig(r)
+	if err != nil {
+		return
+	}
+	labels := map[string]string{}
+	if conf != nil && conf.Labels != nil {
+		labels = conf.Labels
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, withValue := strings.Cut(requirement, "=")
+		actual, found := labels[key]
+		if !found || withValue && actual != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Resolve a selector expression into all workspace resources it matches
+func resolveSelectorForKinds(expr string, kinds KindSet) (resources []Resourcer, aggErr errorz.Aggregated) {
+	var pattern, labelSelector string
+	if strings.HasPrefix(expr, LabelSelectorPrefix) {
+		labelSelector = strings.TrimPrefix(expr, LabelSelectorPrefix)
+	} else {
+		kindInExpr, name := splitExpression(expr)
+		if kindInExpr != AllKind {
+			if !kinds.Contains(kindInExpr) {
+				aggErr.Add(InconsistentExpressionType{expr, &kinds})
+				return
+			}
+			kinds = *NewKindSet(kindInExpr)
+		}
+		pattern = name
+		if _, err := path.Match(pattern, ""); err != nil {
+			aggErr.Add(err)
+			return
+		}
+	}
+
+	candidates, err := listResources(kinds)
+	if err != nil {
+		aggErr.Add(err)
+		return
+	}
+	for _, r := range candidates {
+		if labelSelector != "" {
+			ok, err := matchLabels(r, labelSelector)
+			if err != nil {
+				aggErr.Add(err)
+				return nil, aggErr
+			}
+			if ok {
+				resources = append(resources, r)
+			}
+		} else if ok, _ := path.Match(pattern, r.Name()); ok {
+			resources = append(resources, r)
+		}
+	}
+
+	if len(resources) == 0 {
+		aggErr.Add(ResourceNotFound{expr, NewKindSet(kinds.Kinds()...)})
+	}
+	return
+}
+
+// Resolve an expression which may be a simple resource expression or a selector
+func resolveAnyExpressionForKinds(expr string, kinds KindSet) (resources []Resourcer, aggErr errorz.Aggregated) {
+	if isSelector(expr) {
+		return resolveSelectorForKinds(expr, kinds)
+	}
+	res, aggErr := resolveExpresionForKinds(expr, kinds)
+	if !aggErr.GotError() {
+		resources = append(resources, res)
+	}
+	return
+}
+
+func resourceKey(r Resourcer) string {
+	return r.Kind().String() + "/" + r.Name()
+}
+
+// Append resources not already present in slice
+func appendDistinctResources(resources []Resourcer, toAdd ...Resourcer) []Resourcer {
+	present := map[string]bool{}
+	for _, r := range resources {
+		present[resourceKey(r)] = true
+	}
+	for _, r := range toAdd {
+		if !present[resourceKey(r)] {
+			present[resourceKey(r)] = true
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// Remove resources matched by exclusion expressions.
+// If nothing was included, exclusions apply on all resources of kinds.
+func applyExclusions(resources []Resourcer, exclusions []string, kinds KindSet, included bool) (filtered []Resourcer, aggErr errorz.Aggregated) {
+	if len(exclusions) == 0 {
+		return resources, aggErr
+	}
+	if !included {
+		all, err := listResources(kinds)
+		if err != nil {
+			aggErr.Add(err)
+			return
+		}
+		resources = all
+	}
+
+	excluded := map[string]bool{}
+	for _, expr := range exclusions {
+		res, errors := resolveAnyExpressionForKinds(strings.TrimPrefix(expr, ExclusionPrefix), kinds)
+		if errors.GotError() {
+			aggErr.Concat(errors)
+			continue
+		}
+		for _, r := range res {
+			excluded[resourceKey(r)] = true
+		}
+	}
+
+	for _, r := range resources {
+		if !excluded[resourceKey(r)] {
+			filtered = append(filtered, r)
+		}
+	}
+	return
+}
@@ -0,0 +1,84 @@
+package resources
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resourceNames(resources []Resourcer) (names []string) {
+	for _, r := range resources {
+		names = append(names, r.Name())
+	}
+	sort.Strings(names)
+	return
+}
+
+func writeLabels(t *testing.T, dir, labels string) {
+	content := "labels:\n" + labels
+	err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644)
+	require.NoError(t, err, "must not error")
+}
+
+func TestIsSelector(t *testing.T) {
+	assert.True(t, isSelector("p1/*"))
+	assert.True(t, isSelector("*/api-?"))
+	assert.True(t, isSelector("label:tier=backend"))
+	assert.False(t, isSelector("p1/i11"))
+	assert.False(t, isSelector("!p1/i11"))
+	assert.True(t, isExclusion("!p1/i11"))
+}
+
+func TestResolveSelectorExpression(t *testing.T) {
+	fakeWorkspacePath := initWorkspace(t)
+	defer os.RemoveAll(fakeWorkspacePath)
+	writeLabels(t, filepath.Join(fakeWorkspacePath, project1), "  tier: backend\n")
+	writeLabels(t, filepath.Join(fakeWorkspacePath, project2, image21), "  tier: backend\n  lang: go\n")
+	writeLabels(t, filepath.Join(fakeWorkspacePath, project3, image31), "  tier: frontend\n")
+
+	cases := []struct {
+		exprIn         string
+		kindsIn        []Kind
+		resNamesWanted []string
+		errWanted      error
+	}{
+		{project1 + "/*", []Kind{}, []string{project1 + "/" + image11, project1 + "/" + image12, project1 + "/" + image13}, nil}, // case 0
+		{"*/i3?", []Kind{ImageKind}, []string{project2 + "/" + image33, project3 + "/" + image31, project3 + "/" + image32, project3 + "/" + image33}, nil},
+		{"p/*", []Kind{}, []string{project1, project2, project3}, nil},
+		{"p/p[12]", []Kind{}, []string{project1, project2}, nil},
+		{"p/*", []Kind{ImageKind}, nil, InconsistentExpressionType{"p/*", NewKindSet(ImageKind)}},
+		{"*/notExist*", []Kind{}, nil, ResourceNotFound{"*/notExist*", NewKindSet(AllKind)}}, // case 5
+
+		// Labels
+		{"label:tier=backend", []Kind{ImageKind}, []string{project1 + "/" + image11, project1 + "/" + image12, project1 + "/" + image13, project2 + "/" + image21}, nil},
+		{"label:tier=backend", []Kind{ProjectKind}, []string{project1}, nil},
+		{"label:tier=backend,lang=go", []Kind{}, []string{project2 + "/" + image21}, nil},
+		{"label:tier", []Kind{ImageKind}, []string{project1 + "/" + image11, project1 + "/" + image12, project1 + "/" + image13, project2 + "/" + image21, project3 + "/" + image31}, nil},
+		{"label:tier=unknown", []Kind{}, nil, ResourceNotFound{"label:tier=unknown", NewKindSet(AllKind)}}, // case 10
+
+		// Exclusions
+		{project1 + "/* !" + project1 + "/" + image12, []Kind{}, []string{project1 + "/" + image11, project1 + "/" + image13}, nil},
+		{"label:tier=backend !" + project1 + "/*", []Kind{ImageKind}, []string{project2 + "/" + image21}, nil},
+		{"p !" + project2, []Kind{}, []string{project1, project3}, nil},
+		{"!p/" + project2 + " !p/" + project3, []Kind{ProjectKind}, []string{project1}, nil},
+		{project1 + " " + project1 + " p/p*", []Kind{ProjectKind}, []string{project1, project2, project3}, nil}, // case 15
+		{project1 + "/* !" + project1 + "/notExist", []Kind{}, []string{project1 + "/" + image11, project1 + "/" + image12, project1 + "/" + image13}, ResourceNotFound{project1 + "/notExist", NewKindSet(AllKind)}},
+	}
+
+	for i, c := range cases {
+		err := os.Chdir(fakeWorkspacePath)
+		require.NoError(t, err, "must not error for chdir on case %d", i)
+		resources, aggErr := ResolveExpression(c.exprIn, c.kindsIn...)
+		if c.errWanted == nil {
+			assert.False(t, aggErr.GotError(), "should return no aggregated error on case %d: %s", i, aggErr)
+		} else {
+			assert.True(t, aggErr.GotError(), "should return aggregated error on case %d", i)
+			assert.ErrorIs(t, aggErr, c.errWanted, "bad error for case %d", i)
+		}
+		assert.Equal(t, c.resNamesWanted, resourceNames(resources), "bad resources for case %d", i)
+	}
+}
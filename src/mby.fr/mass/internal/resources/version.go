@@ -3,6 +3,7 @@ package resources
 import (
 	"fmt"
 
+	"mby.fr/mass/internal/settings"
 	"mby.fr/mass/version"
 )
 
@@ -26,18 +27,13 @@ type VersionBumper interface {
 	Release() (string, string, error)
 }
 
-func writeVersionable(v versionable) (err error) {
-	err = Write(v.resource)
-	return
-}
-
 type versionable struct {
 	resource Resourcer
-	ver string `yaml:"version"`
+	Ver      string `yaml:"version"`
 }
 
 func buildVersionable(res Resourcer, version string) (v versionable) {
-	v = versionable{resource: res, ver: version}
+	v = versionable{resource: res, Ver: version}
 	return
 }
 
@@ -46,31 +42,52 @@ func (v versionable) init() error {
 }
 
 func (v versionable) Version() string {
-	return v.ver
+	return v.Ver
+}
+
+// Versioning scheme of the resource from settings
+func (v versionable) scheme() (scheme version.Scheme, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	versioning := ss.Settings().ImageVersioning(v.resource.Name())
+	return version.NewScheme(versioning.Scheme, versioning.Channels, versioning.BuildMetadata)
+}
+
+// Versions which are neither dev versions nor release candidates of the res scheme are immutable
+func (v versionable) IsImmutable() (res bool, err error) {
+	scheme, err := v.scheme()
+	if err != nil {
+		return
+	}
+	isDev, err := scheme.IsDev(v.Ver)
+	if err != nil || isDev {
+		return
+	}
+	isRc, err := scheme.IsReleaseCandidate(v.Ver)
+	if err != nil {
+		return
+	}
+	return !isRc, nil
 }
 
-// Bump res version always set qualifier to dev except if qualifier is rc
-// Version lifecycle :
+// Bump res version always set qualifier to dev except if qualifier is a pre-release
+// Version lifecycle with the default semver scheme :
 // - 1.0.0 -> 1.0.1-dev
 // - 1.0.0-rc1 -> 1.0.0-rc2
 // - 1.0.3-dev -> 1.0.3-dev
 func (v *versionable) Bump(bumpMinor, bumpMajor bool) (toVer, fromVer string, err error) {
-	fromVer = v.ver
-	var isDev, isRc bool
-	if bumpMajor {
-		toVer, err = version.NextMajor(fromVer)
-		if err != nil {
-			return
-		}
-		toVer, err = version.Dev(toVer)
-	} else if bumpMinor {
-		toVer, err = version.NextMinor(fromVer)
-		if err != nil {
-			return
-		}
-		toVer, err = version.Dev(toVer)
+	fromVer = v.Ver
+	scheme, err := v.scheme()
+	if err != nil {
+		return
+	}
+	var isDev, isPreRelease bool
+	if bumpMajor || bumpMinor {
+		toVer, err = scheme.NextDev(fromVer, bumpMinor, bumpMajor)
 	} else {
-		isDev, err = version.IsDev(fromVer)
+		isDev, err = scheme.IsDev(fromVer)
 		if err != nil {
 			return
 		}
@@ -78,49 +95,62 @@ func (v *versionable) Bump(bumpMinor, bumpMajor bool) (toVer, fromVer string, er
 			err = AlreadyBumped
 			return
 		}
-		isRc, err = version.IsRc(fromVer)
+		isPreRelease, err = scheme.IsPreRelease(fromVer)
 		if err != nil {
 			return
 		}
-		if isRc {
-			toVer, err = version.NextRc(fromVer)
+		if isPreRelease {
+			toVer, err = scheme.NextPreRelease(fromVer)
 		} else {
-			toVer, err = version.NextDev(fromVer)
+			toVer, err = scheme.NextDev(fromVer, false, false)
 		}
 	}
 	if err != nil {
 		return
 	}
 
-	v.ver = toVer
+	v.Ver = toVer
 	return
 }
 
-// Promote res version from dev to rc.
+// Promote res version from dev to pre-release or to the next pre-release channel.
 func (v *versionable) Promote() (toVer, fromVer string, err error) {
-	fromVer = v.ver
-	var isDev, isRc bool
-	isDev, err = version.IsDev(fromVer)
+	fromVer = v.Ver
+	scheme, err := v.scheme()
+	if err != nil {
+		return
+	}
+	var isDev, isPreRelease, ok bool
+	isDev, err = scheme.IsDev(fromVer)
 	if err != nil {
 		return
 	}
 	if isDev {
-		toVer, err = version.NextRc(fromVer)
+		toVer, err = scheme.NextPreRelease(fromVer)
 		if err != nil {
 			return
 		}
 
-		v.ver = toVer
-		err = writeVersionable(*v)
+		v.Ver = toVer
 		return
 	}
 
-	isRc, err = version.IsRc(fromVer)
+	isPreRelease, err = scheme.IsPreRelease(fromVer)
 	if err != nil {
 		return
 	}
-	if isRc {
-		err = AlreadyPromoted
+	if isPreRelease {
+		toVer, ok, err = scheme.NextChannel(fromVer)
+		if err != nil {
+			return
+		}
+		if !ok {
+			toVer = ""
+			err = AlreadyPromoted
+			return
+		}
+
+		v.Ver = toVer
 		return
 	}
 
@@ -128,36 +158,39 @@ func (v *versionable) Promote() (toVer, fromVer string, err error) {
 	return
 }
 
-// Release res version from rc to release
-func (v *versionable) Release() (toVer, fromVer string, err error) {
-	var isDev, isRc bool
-	fromVer = v.ver
-	isRc, err = version.IsRc(fromVer)
+// Release version of a pre-release res version without changing it
+func (v versionable) NextRelease() (toVer string, err error) {
+	scheme, err := v.scheme()
 	if err != nil {
 		return
 	}
-	if isRc {
-		toVer, err = version.Release(fromVer)
-		if err != nil {
-			return
-		}
+	isPreRelease, err := scheme.IsPreRelease(v.Ver)
+	if err != nil {
+		return
+	}
+	if isPreRelease {
+		return scheme.Release(v.Ver)
+	}
+	isDev, err := scheme.IsDev(v.Ver)
+	if err != nil {
+		return
+	}
+	if isDev {
+		err = NotPromoted
+		return
+	}
+	err = AlreadyReleased
+	return
+}
 
-		v.ver = toVer
-		err = writeVersionable(*v)
-		if err != nil {
-			return
-		}
-	} else {
-		isDev, err = version.IsDev(fromVer)
-		if err != nil {
-			return
-		}
-		if isDev {
-			err = NotPromoted
-			return
-		}
-		err = AlreadyReleased
+// Release res version from pre-release to release
+func (v *versionable) Release() (toVer, fromVer string, err error) {
+	fromVer = v.Ver
+	toVer, err = v.NextRelease()
+	if err != nil {
+		toVer = ""
 		return
 	}
+	v.Ver = toVer
 	return
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"mby.fr/mass/internal/commontest"
@@ -34,14 +35,14 @@ func TestBump(t *testing.T) {
 	assert.Equal(t, "", toVer, "Bad bumped message")
 	assert.Equal(t, image.Version(), fromVer, "Bad bumped message")
 
-	image.versionable.ver = "2.0.1-rc3"
+	image.versionable.Ver = "2.0.1-rc3"
 	toVer, fromVer, err = image.Bump(false, false)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "2.0.1-rc4", image.Version(), "Bad bumped version")
 	assert.Equal(t, "2.0.1-rc4", toVer, "Bad bumped message")
 	assert.Equal(t, "2.0.1-rc3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "3.0.3"
+	image.versionable.Ver = "3.0.3"
 	toVer, fromVer, err = image.Bump(false, false)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "3.0.4-dev", image.Version(), "Bad bumped version")
@@ -49,28 +50,28 @@ func TestBump(t *testing.T) {
 	assert.Equal(t, "3.0.3", fromVer, "Bad bumped message")
 
 	// Bump next major
-	image.versionable.ver = "4.0.1-rc3"
+	image.versionable.Ver = "4.0.1-rc3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "5.0.0-dev", image.Version(), "Bad bumped version")
 	assert.Equal(t, "5.0.0-dev", toVer, "Bad bumped message")
 	assert.Equal(t, "4.0.1-rc3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "5.0.3"
+	image.versionable.Ver = "5.0.3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "6.0.0-dev", image.Version(), "Bad bumped version")
 	assert.Equal(t, "6.0.0-dev", toVer, "Bad bumped message")
 	assert.Equal(t, "5.0.3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "4.0.1-rc3"
+	image.versionable.Ver = "4.0.1-rc3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "5.0.0-dev", image.Version(), "Bad bumped version")
 	assert.Equal(t, "5.0.0-dev", toVer, "Bad bumped message")
 	assert.Equal(t, "4.0.1-rc3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "5.0.3"
+	image.versionable.Ver = "5.0.3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "6.0.0-dev", image.Version(), "Bad bumped version")
@@ -78,14 +79,14 @@ func TestBump(t *testing.T) {
 	assert.Equal(t, "5.0.3", fromVer, "Bad bumped message")
 
 	// Bump next minor
-	image.versionable.ver = "6.0.1-rc3"
+	image.versionable.Ver = "6.0.1-rc3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "7.0.0-dev", image.Version(), "Bad bumped version")
 	assert.Equal(t, "7.0.0-dev", toVer, "Bad bumped message")
 	assert.Equal(t, "6.0.1-rc3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "7.0.3"
+	image.versionable.Ver = "7.0.3"
 	toVer, fromVer, err = image.Bump(false, true)
 	require.NoError(t, err, "Bump must not return an error")
 	assert.Equal(t, "8.0.0-dev", image.Version(), "Bad bumped version")
@@ -106,14 +107,14 @@ func TestPromote(t *testing.T) {
 	assert.Equal(t, "0.0.1-rc1", toVer, "Bad bumped message")
 	assert.Equal(t, "0.0.1-dev", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "2.0.1-rc3"
+	image.versionable.Ver = "2.0.1-rc3"
 	toVer, fromVer, err = image.Promote()
 	require.Error(t, err, "must return an error")
 	assert.Equal(t, AlreadyPromoted, err, "Bad promote error")
 	assert.Equal(t, "", toVer, "Bad bumped message")
 	assert.Equal(t, image.Version(), fromVer, "Bad bumped message")
 
-	image.versionable.ver = "3.0.3"
+	image.versionable.Ver = "3.0.3"
 	toVer, fromVer, err = image.Promote()
 	require.Error(t, err, "must return an error")
 	assert.Equal(t, NotPromotable, err, "Bad promote error")
@@ -134,17 +135,78 @@ func TestRelease(t *testing.T) {
 	assert.Equal(t, "", toVer, "Bad bumped message")
 	assert.Equal(t, image.Version(), fromVer, "Bad bumped message")
 
-	image.versionable.ver = "2.0.1-rc3"
+	image.versionable.Ver = "2.0.1-rc3"
 	toVer, fromVer, err = image.Release()
 	require.NoError(t, err, "must not return an error")
 	assert.Equal(t, "2.0.1", image.Version(), "Bad released version")
 	assert.Equal(t, "2.0.1", toVer, "Bad bumped message")
 	assert.Equal(t, "2.0.1-rc3", fromVer, "Bad bumped message")
 
-	image.versionable.ver = "3.0.3"
+	image.versionable.Ver = "3.0.3"
 	toVer, fromVer, err = image.Release()
 	require.Error(t, err, "must return an error")
 	assert.Equal(t, AlreadyReleased, err, "Bad release error")
 	assert.Equal(t, "", toVer, "Bad bumped message")
 	assert.Equal(t, image.Version(), fromVer, "Bad bumped message")
 }
+
+func TestPromoteWithChannelsScheme(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, imagePath := commontest.InitRandImage(t, wksPath)
+	image, err := buildImage(imagePath)
+	require.NoError(t, err, "should not return an error")
+
+	settingsFile, err := os.OpenFile(filepath.Join(wksPath, ".mass", "settings.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err, "should not return an error")
+	_, err = settingsFile.WriteString("versioning:\n  images:\n    " + image.Name() + ":\n      scheme: semver-channels\n      channels: [alpha, beta]\n")
+	settingsFile.Close()
+	require.NoError(t, err, "should not return an error")
+
+	toVer, _, err := image.Promote()
+	require.NoError(t, err, "must not return an error")
+	assert.Equal(t, "0.0.1-alpha.1", toVer, "Bad promoted version")
+
+	toVer, _, err = image.Bump(false, false)
+	require.NoError(t, err, "must not return an error")
+	assert.Equal(t, "0.0.1-alpha.2", toVer, "Bad bumped version")
+
+	toVer, _, err = image.Promote()
+	require.NoError(t, err, "must not return an error")
+	assert.Equal(t, "0.0.1-beta.1", toVer, "Bad promoted version")
+
+	_, _, err = image.Promote()
+	assert.Equal(t, AlreadyPromoted, err, "Bad promote error")
+
+	toVer, _, err = image.Release()
+	require.NoError(t, err, "must not return an error")
+	assert.Equal(t, "0.0.1", toVer, "Bad released version")
+}
+
+func TestVersionChangesAreWritten(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, imagePath := commontest.InitRandImage(t, wksPath)
+	image, err := buildImage(imagePath)
+	require.NoError(t, err, "should not return an error")
+
+	toVer, _, err := image.Promote()
+	require.NoError(t, err, "must not return an error")
+	loadedImage, err := Read[Image](imagePath)
+	require.NoError(t, err, "should not return an error")
+	assert.Equal(t, toVer, loadedImage.Version(), "promoted version should be written")
+
+	next, err := loadedImage.NextRelease()
+	require.NoError(t, err, "must not return an error")
+	assert.Equal(t, "0.0.1", next, "Bad next release")
+	assert.Equal(t, toVer, loadedImage.Version(), "next release should not change version")
+
+	toVer, _, err = loadedImage.Release()
+	require.NoError(t, err, "must not return an error")
+	loadedImage, err = Read[Image](imagePath)
+	require.NoError(t, err, "should not return an error")
+	assert.Equal(t, toVer, loadedImage.Version(), "released version should be written")
+
+	_, err = loadedImage.NextRelease()
+	assert.Equal(t, AlreadyReleased, err, "Bad next release error")
+}
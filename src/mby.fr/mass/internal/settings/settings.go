@@ -6,11 +6,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 
 	"mby.fr/mass/internal/templates"
+	"mby.fr/utils/cache"
+	"mby.fr/utils/engine"
 )
 
 const defaultSettingsDir = ".mass"
@@ -19,6 +23,8 @@ var settingsFile = filepath.Join(defaultSettingsDir, "settings.yaml")
 
 var PathNotFound = fmt.Errorf("Unable to found settings path")
 var NotExistingEnv = fmt.Errorf("Env don't exists")
+var UnknownEngine = fmt.Errorf("Unknown engine")
+var UnknownCache = fmt.Errorf("Unknown shared cache backend")
 
 // Default settings
 const defaultEnvsDir = "envs"
@@ -26,22 +32,105 @@ const defaultProjectsDir = "."
 const defaultCacheDir = ".cache"
 const defaultTemplatesDir = ".templates"
 const defaultEnvToUse = "dev"
+const defaultEngine = CliEngine
+const defaultBuilder = "docker"
+const defaultCacheTimeout = 5 * time.Second
+
+// Engines used to build, run and pull images
+const (
+	CliEngine = "cli" // Run the docker binary
+	ApiEngine = "api" // Call the Docker Engine API on its socket
+)
+
+// Backends of caches shared by a team
+const (
+	DirCache  = "dir"  // Shared directory, e.g. an NFS mount
+	HttpCache = "http" // Http key value server
+)
 
 var defaultEnvs = []string{"dev", "stage", "prod"}
 
 var SelectedEnvironment string = ""
 var LoggingLevel int = 0
+var SelectedEngine string = ""
 
 // --- Settings ---
 
 type Settings struct {
-	Name               string   `yaml:"workspace"`
-	EnvsDir            string   `yaml:"envsDirectory"`
-	ProjectsDir        string   `yaml:"projectsDirectory"`
-	CacheDir           string   `yaml:"cacheDirectory"`
-	TemplatesDir       string   `yaml:"templatesDirectory"`
-	Environments       []string `yaml:"environments"`
-	DefaultEnvironment string   `yaml:"defaultEnvironment"`
+	Name               string              `yaml:"workspace"`
+	EnvsDir            string              `yaml:"envsDirectory"`
+	ProjectsDir        string              `yaml:"projectsDirectory"`
+	CacheDir           string              `yaml:"cacheDirectory"`
+	TemplatesDir       string              `yaml:"templatesDirectory"`
+	Environments       []string            `yaml:"environments"`
+	DefaultEnvironment string              `yaml:"defaultEnvironment"`
+	Engine             string              `yaml:"engine"`
+	EngineSocket       string              `yaml:"engineSocket"` // Default to DOCKER_HOST or /var/run/docker.sock
+	Builder            string              `yaml:"builder"`      // Build backend: docker, podman, buildah or buildx
+	Registries         map[string]Registry `yaml:"registries"`   // Registry to push to by env name
+	Versioning         Versioning          `yaml:"versioning"`
+	Git                Git                 `yaml:"git"`
+	SharedCaches       []SharedCache       `yaml:"sharedCaches"` // Read through on local cache misses and written back, in order
+}
+
+// Cache shared by developers and CI runners.
+// Signatures include absolute paths so workspaces must be checked out at the same path to share entries.
+type SharedCache struct {
+	Backend string        `yaml:"backend"` // dir or http
+	Path    string        `yaml:"path"`    // Directory of the dir backend, relative to the workspace if not absolute
+	Url     string        `yaml:"url"`     // Base url of the http backend
+	Timeout time.Duration `yaml:"timeout"` // Bound of each cache operation, e.g. 2s. Default to 5s.
+}
+
+// Git integration of the version lifecycle
+type Git struct {
+	Backend      string `yaml:"backend"`      // binary or go. Default to binary.
+	RequireClean bool   `yaml:"requireClean"` // Refuse to promote or release an image with uncommitted changes
+	Commit       bool   `yaml:"commit"`       // Commit version changes of promoted and released images
+	Tag          bool   `yaml:"tag"`          // Create an annotated tag <project>/<image>/v<version> on release
+}
+
+// Versioning scheme of the workspace images
+type Versioning struct {
+	Scheme        string                `yaml:"scheme"`        // semver, semver-channels or calver. Default to semver.
+	Channels      []string              `yaml:"channels"`      // Pre-release channels of semver-channels
+	BuildMetadata bool                  `yaml:"buildMetadata"` // Add a +build.<n> metadata to versions
+	Images        map[string]Versioning `yaml:"images"`        // Override by image name, e.g. myProject/myImage
+}
+
+// Versioning of an image: its override completed by the workspace versioning
+func (s Settings) ImageVersioning(name string) (v Versioning) {
+	v = Versioning{Scheme: s.Versioning.Scheme, Channels: s.Versioning.Channels, BuildMetadata: s.Versioning.BuildMetadata}
+	// Viper lower cases map keys
+	override, ok := s.Versioning.Images[strings.ToLower(name)]
+	if !ok {
+		return
+	}
+	if override.Scheme != "" {
+		v.Scheme = override.Scheme
+	}
+	if len(override.Channels) > 0 {
+		v.Channels = override.Channels
+	}
+	v.BuildMetadata = override.BuildMetadata
+	return
+}
+
+// Registry images are pushed to
+type Registry struct {
+	Host            string `yaml:"host"`
+	Namespace       string `yaml:"namespace"`
+	Insecure        bool   `yaml:"insecure"`        // Skip TLS verification with podman or buildah. Docker daemon must declare it in insecure-registries
+	CredentialsFile string `yaml:"credentialsFile"` // Docker config.json file storing registry auths
+}
+
+// Prefix of images references pushed to this registry, e.g. localhost:5000/myNamespace
+func (r Registry) Prefix() string {
+	prefix := strings.Trim(r.Host, "/")
+	if r.Namespace != "" {
+		prefix += "/" + strings.Trim(r.Namespace, "/")
+	}
+	return prefix
 }
 
 func Default() Settings {
@@ -52,6 +141,8 @@ func Default() Settings {
 		TemplatesDir:       defaultTemplatesDir,
 		Environments:       defaultEnvs,
 		DefaultEnvironment: defaultEnvToUse,
+		Engine:             defaultEngine,
+		Builder:            defaultBuilder,
 	}
 }
 
@@ -72,6 +163,8 @@ func initViper(workspacePath string) {
 	viper.SetDefault("TemplatesDir", defaultTemplatesDir)
 	viper.SetDefault("Environments", defaultEnvs)
 	viper.SetDefault("DefaultEnvironment", defaultEnvToUse)
+	viper.SetDefault("Engine", defaultEngine)
+	viper.SetDefault("Builder", defaultBuilder)
 }
 
 // Store settings erasing previous settings
@@ -268,6 +361,66 @@ func (s SettingsService) WorkingEnv() (string, error) {
 	return envToUse, nil
 }
 
+// Registry of the working env if one is configured
+func (s SettingsService) WorkingRegistry() (registry Registry, ok bool, err error) {
+	env, err := s.WorkingEnv()
+	if err != nil {
+		return
+	}
+	registry, ok = s.settings.Registries[env]
+	ok = ok && registry.Host != ""
+	return
+}
+
+// Engine API client to use or nil if the docker binary must be used.
+func (s SettingsService) EngineClient() (*engine.Client, error) {
+	engineToUse := s.settings.Engine
+	if SelectedEngine != "" {
+		// User specified an engine
+		engineToUse = SelectedEngine
+	}
+
+	switch engineToUse {
+	case CliEngine, "":
+		return nil, nil
+	case ApiEngine:
+		if s.settings.EngineSocket != "" {
+			return engine.NewClient(s.settings.EngineSocket), nil
+		}
+		return engine.FromEnv()
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownEngine, engineToUse)
+	}
+}
+
+// Shared caches of a named cache, e.g. testResults, in settings order
+func (s SettingsService) SharedCaches(name string) (caches []cache.Cache, err error) {
+	for _, shared := range s.settings.SharedCaches {
+		timeout := shared.Timeout
+		if timeout == 0 {
+			timeout = defaultCacheTimeout
+		}
+		var c cache.Cache
+		switch shared.Backend {
+		case DirCache:
+			path := shared.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(s.workspacePath, path)
+			}
+			c, err = cache.NewSharedDirCache(filepath.Join(path, name), timeout)
+			if err != nil {
+				return nil, err
+			}
+		case HttpCache:
+			c = cache.NewHttpCache(strings.TrimSuffix(shared.Url, "/")+"/"+name, timeout)
+		default:
+			return nil, fmt.Errorf("%w: %s", UnknownCache, shared.Backend)
+		}
+		caches = append(caches, c)
+	}
+	return
+}
+
 // singleton
 var lock = &sync.Mutex{}
 
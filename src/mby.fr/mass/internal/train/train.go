@@ -0,0 +1,174 @@
+package train
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/resources"
+	"mby.fr/utils/errorz"
+)
+
+const (
+	// Release manifests file in the project dir
+	DefaultManifestFile = "releases.yaml"
+	firstVersion        = "1.0.0"
+)
+
+var EmptyTrain error = fmt.Errorf("No image to release")
+var InvalidTransition error = fmt.Errorf("Invalid release transition")
+
+// Version change of an image in a release train
+type Transition struct {
+	Image *resources.Image
+	From  string
+	To    string
+}
+
+// Release of a project: all its images are released together or none is
+type Train struct {
+	Project     resources.Project
+	Version     string
+	Transitions []Transition
+}
+
+// Record of a train: released version of each image by image name
+type Manifest struct {
+	Project string            `yaml:"project"`
+	Version string            `yaml:"version"`
+	Date    time.Time         `yaml:"date"`
+	Images  map[string]string `yaml:"images"`
+}
+
+// Compute the release transition of every project image validated by check. Nothing is written.
+// All invalid transitions are reported in an aggregated error.
+func Plan(project resources.Project, check func(resources.Image) error) (t Train, err error) {
+	t.Project = project
+	images, err := project.Images()
+	if err != nil {
+		return
+	}
+	if len(images) == 0 {
+		return t, fmt.Errorf("%w in project %s", EmptyTrain, project.Name())
+	}
+	errors := errorz.Aggregated{}
+	for _, image := range images {
+		toVer, err := image.NextRelease()
+		if err == nil && check != nil {
+			err = check(*image)
+		}
+		if err != nil {
+			errors.Add(fmt.Errorf("%w of %s: %s", InvalidTransition, image.QualifiedName(), err))
+			continue
+		}
+		t.Transitions = append(t.Transitions, Transition{Image: image, From: image.Version(), To: toVer})
+	}
+	if errors.GotError() {
+		return t, errors
+	}
+
+	manifests, err := Manifests(project)
+	if err != nil {
+		return
+	}
+	t.Version, err = nextVersion(manifests)
+	return
+}
+
+// Next minor of the latest train version
+func nextVersion(manifests []Manifest) (string, error) {
+	if len(manifests) == 0 {
+		return firstVersion, nil
+	}
+	latest, err := semver.NewVersion(manifests[len(manifests)-1].Version)
+	if err != nil {
+		return "", err
+	}
+	return latest.IncMinor().String(), nil
+}
+
+// Release all images of the train then record its manifest.
+// On error every written resource file is restored.
+func (t Train) Apply() (m Manifest, err error) {
+	backups := map[string][]byte{}
+	defer func() {
+		if err != nil {
+			t.rollback(backups)
+		}
+	}()
+	for _, transition := range t.Transitions {
+		path := filepath.Join(transition.Image.Dir(), resources.DefaultResourceFile)
+		backups[path], err = os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		var toVer string
+		toVer, _, err = transition.Image.Release()
+		if err != nil {
+			err = fmt.Errorf("unable to release %s: %w", transition.Image.QualifiedName(), err)
+			return
+		}
+		if toVer != transition.To {
+			err = fmt.Errorf("%w of %s: released %s instead of %s", InvalidTransition, transition.Image.QualifiedName(), toVer, transition.To)
+			return
+		}
+	}
+
+	m = t.Manifest(time.Now())
+	err = appendManifest(t.Project, m)
+	return
+}
+
+// Restore resource files and in memory versions of the train images
+func (t Train) rollback(backups map[string][]byte) {
+	for path, content := range backups {
+		os.WriteFile(path, content, 0644)
+	}
+	for _, transition := range t.Transitions {
+		transition.Image.Ver = transition.From
+	}
+}
+
+func (t Train) Manifest(date time.Time) Manifest {
+	m := Manifest{Project: t.Project.Name(), Version: t.Version, Date: date, Images: map[string]string{}}
+	for _, transition := range t.Transitions {
+		m.Images[transition.Image.Name()] = transition.To
+	}
+	return m
+}
+
+func manifestFile(project resources.Project) string {
+	return filepath.Join(project.Dir(), DefaultManifestFile)
+}
+
+// Release manifests of a project, oldest first
+func Manifests(project resources.Project) (manifests []Manifest, err error) {
+	content, err := os.ReadFile(manifestFile(project))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	err = yaml.Unmarshal(content, &manifests)
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].Date.Before(manifests[j].Date)
+	})
+	return
+}
+
+func appendManifest(project resources.Project, m Manifest) (err error) {
+	manifests, err := Manifests(project)
+	if err != nil {
+		return
+	}
+	content, err := yaml.Marshal(append(manifests, m))
+	if err != nil {
+		return
+	}
+	return os.WriteFile(manifestFile(project), content, 0644)
+}
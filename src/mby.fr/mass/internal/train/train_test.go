@@ -0,0 +1,122 @@
+package train
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+)
+
+func initProject(t *testing.T, wksPath string, promoted ...bool) resources.Project {
+	projectDir := filepath.Join(wksPath, "p1")
+	_, err := resources.Init[resources.Project](projectDir)
+	require.NoError(t, err, "should not error")
+	for n, promote := range promoted {
+		image, err := resources.Init[resources.Image](filepath.Join(projectDir, "i"+string(rune('1'+n))))
+		require.NoError(t, err, "should not error")
+		if promote {
+			_, _, err = image.Promote()
+			require.NoError(t, err, "should not error")
+		}
+	}
+	project, err := resources.Read[resources.Project](projectDir)
+	require.NoError(t, err, "should not error")
+	return project
+}
+
+func readVersion(t *testing.T, dir string) string {
+	image, err := resources.Read[resources.Image](dir)
+	require.NoError(t, err, "should not error")
+	return image.Version()
+}
+
+func TestPlanAndApply(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	project := initProject(t, wksPath, true, true)
+
+	train, err := Plan(project, nil)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.0", train.Version)
+	require.Len(t, train.Transitions, 2)
+	assert.Equal(t, "0.0.1-rc1", train.Transitions[0].From)
+	assert.Equal(t, "0.0.1", train.Transitions[0].To)
+	assert.Equal(t, "0.0.1-rc1", readVersion(t, train.Transitions[0].Image.Dir()), "plan should not write")
+
+	m, err := train.Apply()
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, map[string]string{"p1/i1": "0.0.1", "p1/i2": "0.0.1"}, m.Images)
+	for _, transition := range train.Transitions {
+		assert.Equal(t, "0.0.1", readVersion(t, transition.Image.Dir()))
+	}
+
+	manifests, err := Manifests(project)
+	require.NoError(t, err, "should not error")
+	require.Len(t, manifests, 1)
+	assert.Equal(t, "p1", manifests[0].Project)
+	assert.Equal(t, "1.0.0", manifests[0].Version)
+	assert.Equal(t, m.Images, manifests[0].Images)
+
+	// All images are already released
+	_, err = Plan(project, nil)
+	assert.True(t, errors.Is(err, InvalidTransition), "should error")
+	assert.Contains(t, err.Error(), resources.AlreadyReleased.Error())
+
+	next, err := nextVersion(manifests)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.1.0", next)
+}
+
+func TestPlanValidatesAllImages(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	project := initProject(t, wksPath, true, false, true)
+
+	checked := 0
+	vetoed := errors.New("vetoed")
+	_, err := Plan(project, func(image resources.Image) error {
+		checked++
+		if image.ImageName() == "i3" {
+			return vetoed
+		}
+		return nil
+	})
+	assert.True(t, errors.Is(err, InvalidTransition), "should error")
+	assert.Contains(t, err.Error(), "image/p1/i2")
+	assert.Contains(t, err.Error(), "image/p1/i3")
+	assert.Equal(t, 2, checked, "check should be called on each releasable image")
+
+	_, err = os.Stat(filepath.Join(project.Dir(), DefaultManifestFile))
+	assert.True(t, os.IsNotExist(err), "no manifest should be written")
+}
+
+func TestApplyRollsBack(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	project := initProject(t, wksPath, true, true)
+
+	train, err := Plan(project, nil)
+	require.NoError(t, err, "should not error")
+
+	// Second image resource file cannot be read anymore
+	i2ResourceFile := filepath.Join(train.Transitions[1].Image.Dir(), resources.DefaultResourceFile)
+	err = os.Remove(i2ResourceFile)
+	require.NoError(t, err, "should not error")
+	err = os.Mkdir(i2ResourceFile, 0755)
+	require.NoError(t, err, "should not error")
+
+	_, err = train.Apply()
+	assert.Error(t, err, "should error")
+	assert.Equal(t, "0.0.1-rc1", readVersion(t, train.Transitions[0].Image.Dir()), "first image should be rolled back")
+	assert.Equal(t, "0.0.1-rc1", train.Transitions[0].Image.Version(), "first image should be rolled back in memory")
+
+	manifests, err := Manifests(project)
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, manifests, "no manifest should be written")
+}
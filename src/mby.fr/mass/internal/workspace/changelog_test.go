@@ -0,0 +1,44 @@
+package workspace
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/changelog"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestChangelogResources(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	gitCommand := func(args ...string) {
+		args = append([]string{"-C", wksPath, "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, "should not error: %s", out)
+	}
+	err = os.WriteFile(filepath.Join(image.AbsSourceDir(), "main.go"), []byte("package main\n"), 0644)
+	require.NoError(t, err, "should not error")
+	gitCommand("init", "-q")
+	gitCommand("add", ".")
+	gitCommand("commit", "-q", "-m", "feat: first feature")
+
+	ChangelogResources([]string{"p1/i1"})
+
+	content, err := os.ReadFile(filepath.Join(image.Dir(), changelog.DefaultChangelogFile))
+	require.NoError(t, err, "changelog should be written")
+	assert.Contains(t, string(content), "- first feature")
+	content, err = os.ReadFile(filepath.Join(wksPath, "p1", changelog.DefaultReleaseNotesFile))
+	require.NoError(t, err, "release notes should be written")
+	assert.Contains(t, string(content), "## p1/i1 0.0.1")
+}
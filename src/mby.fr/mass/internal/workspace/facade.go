@@ -1,29 +1,90 @@
 package workspace
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	//"fmt"
 
 	"mby.fr/mass/internal/build"
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/changelog"
 	"mby.fr/mass/internal/deploy"
 	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/git"
+	"mby.fr/mass/internal/graph"
+	"mby.fr/mass/internal/interrupt"
+	"mby.fr/mass/internal/lock"
+	"mby.fr/mass/internal/push"
 	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/mass/internal/train"
 	"mby.fr/mass/testing"
 	"mby.fr/utils/concurrent"
 	"mby.fr/utils/errorz"
 )
 
 var (
-	NoCacheBuild bool
-	ForceBuild   bool
-	ForcePull    bool
-	RmVolumes    bool
-	BumpMinor    bool
-	BumpMajor    bool
+	NoCacheBuild   bool
+	ForceBuild     bool
+	ForcePull      bool
+	RmVolumes      bool
+	BumpMinor      bool
+	BumpMajor      bool
+	BumpAuto       bool
+	GraphFormat    string
+	ForcePush      bool
+	ForceDeploy    bool
+	Parallel       int
+	FailFast       bool
+	KeepTestEnv    bool
+	TestReport     string
+	ForcePromote   bool
+	ForceRelease   bool
+	ReleaseTrain   bool
+	Locked         bool
+	AllowOverwrite bool
 )
 
+// Context of in-flight actions cancelled on interruption
+func actionContext() context.Context {
+	return interrupt.Context()
+}
+
+// Exit on error unless interrupted: the interrupt handler exits once cleaned up
+func fatal(d display.Displayer, msg string) {
+	interrupt.WaitIfInterrupted()
+	d.Fatal(msg)
+}
+
+// Called on interruption once in-flight actions are cancelled
+func CleanupInterrupted() {
+	d := display.Service()
+	d.Warn("Interrupted, cleaning up ...")
+	err := deploy.RemoveTemporaryContainers()
+	if err != nil {
+		d.Warn(fmt.Sprintf("Unable to remove temporary containers: %s", err))
+	}
+	d.Flush()
+}
+
+// Worker pool options from flags. Default parallelism is the count of CPUs.
+func poolOptions() concurrent.Options {
+	parallelism := Parallel
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	return concurrent.Options{Parallelism: parallelism, FailFast: FailFast}
+}
+
 func printErrors(errors errorz.Aggregated) {
 	if errors.GotError() {
 		display := display.Service()
@@ -76,20 +137,61 @@ func DisplayResourcesVersion(args []string) {
 	d.Info("Version finished")
 }
 
+func GraphResources(args []string) {
+	d := display.Service()
+	d.Info("Graph starting ...")
+
+	res := ResolveExpression(args, resources.AllKind)
+	g, errors := graph.Build(res, true)
+	printErrors(errors)
+
+	builder := strings.Builder{}
+	err := graph.Render(&builder, g, GraphFormat)
+	if err != nil {
+		d.Fatal(fmt.Sprintf("Encountered error rendering graph: %s", err))
+	}
+	d.Display(builder.String())
+
+	d.Flush()
+	d.Info("Graph finished")
+}
+
 func forgeVersionBumpMessage(fromVer, toVer string) (string) {
 	return fmt.Sprintf("%s => %s", fromVer, toVer)
 }
 
+// Version bumper of a resource. Resolved images are values while bumpers have pointer receivers.
+func asVersionBumper(r resources.Resourcer) (vb resources.VersionBumper, ok bool) {
+	var i interface{} = r
+	if image, isImage := r.(resources.Image); isImage {
+		i = &image
+	}
+	vb, ok = i.(resources.VersionBumper)
+	return
+}
+
 func BumpResources(args []string) {
 	d := display.Service()
 	d.Info("Bump starting ...")
 
 	res := ResolveExpression(args, resources.ImageKind)
 	for _, r := range res {
-		var i interface{} = r
-		vb, ok := i.(resources.VersionBumper)
+		vb, ok := asVersionBumper(r)
 		if ok {
-			toVer, fromVer, err := vb.Bump(BumpMinor, BumpMajor)
+			bumpMinor, bumpMajor := BumpMinor, BumpMajor
+			if BumpAuto {
+				level, err := autoBumpLevel(r)
+				if err != nil {
+					d.Warn(fmt.Sprintf("Error bumping resource %s: %s\n", r.QualifiedName(), err))
+					continue
+				}
+				if level == changelog.NoBump {
+					d.Display(fmt.Sprintf("No change to bump for resource %s\n", r.QualifiedName()))
+					continue
+				}
+				bumpMinor, bumpMajor = level == changelog.MinorBump, level == changelog.MajorBump
+			}
+			toVer, fromVer, err := vb.Bump(bumpMinor, bumpMajor)
 			if err != nil {
 				d.Warn(fmt.Sprintf("Error bumping resource %s: %s\n", r.QualifiedName(), err))
 			} else {
@@ -104,15 +206,125 @@ func BumpResources(args []string) {
 	d.Info("Bump finished")
 }
 
+// Changes of an image since its previous release
+func imageChangelog(image resources.Image) (release changelog.Release, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	repo, err := git.Open(ss.Settings().Git.Backend, image.Dir())
+	if err != nil {
+		return
+	}
+	return changelog.Collect(repo, image)
+}
+
+// Bump level inferred from conventional commits since the previous release.
+// Returns AlreadyBumped if the image version already accounts for it.
+func autoBumpLevel(r resources.Resourcer) (level changelog.Level, err error) {
+	var image resources.Image
+	switch v := r.(type) {
+	case *resources.Image:
+		image = *v
+	case resources.Image:
+		image = v
+	default:
+		return
+	}
+	release, err := imageChangelog(image)
+	if err != nil {
+		return
+	}
+	level = release.Level()
+	if level == changelog.NoBump {
+		return
+	}
+	bumped, err := changelog.AlreadyBumped(release.Previous, image.Version(), level)
+	if err == nil && bumped {
+		err = fmt.Errorf("%w for a %s change", resources.AlreadyBumped, level)
+	}
+	return
+}
+
+// Images must have a passing test record for their current version and signature unless forced
+func checkTested(r resources.Resourcer, force bool) (err error) {
+	if force {
+		return
+	}
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	switch image := r.(type) {
+	case *resources.Image:
+		return change.CheckTested(*image)
+	case resources.Image:
+		return change.CheckTested(image)
+	}
+	return
+}
+
+// Resource dir must not have uncommitted changes if git requireClean setting is enabled
+func checkCleanTree(r resources.Resourcer) (err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	gitSettings := ss.Settings().Git
+	if !gitSettings.RequireClean {
+		return
+	}
+	repo, err := git.Open(gitSettings.Backend, r.Dir())
+	if err != nil {
+		return
+	}
+	return git.CheckClean(repo, r.Dir())
+}
+
+// Commit the resource file of a version change and tag released versions as configured by git settings
+func commitVersionChange(r resources.Resourcer, action, fromVer, toVer string, release bool) (err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	gitSettings := ss.Settings().Git
+	tag := release && gitSettings.Tag
+	if !gitSettings.Commit && !tag {
+		return
+	}
+	repo, err := git.Open(gitSettings.Backend, r.Dir())
+	if err != nil {
+		return
+	}
+	message := fmt.Sprintf("%s %s %s => %s", action, r.QualifiedName(), fromVer, toVer)
+	if gitSettings.Commit {
+		_, err = repo.Commit(message, filepath.Join(r.Dir(), resources.DefaultResourceFile))
+		if err != nil {
+			return
+		}
+	}
+	if tag {
+		err = repo.Tag(git.ReleaseTag(r.Name(), toVer), message)
+	}
+	return
+}
+
 func PromoteResources(args []string) {
 	d := display.Service()
 	d.Info("Promote starting ...")
 
 	res := ResolveExpression(args, resources.ImageKind)
 	for _, r := range res {
-		var i interface{} = r
-		vb, ok := i.(resources.VersionBumper)
+		vb, ok := asVersionBumper(r)
 		if ok {
+			err := checkTested(r, ForcePromote)
+			if err == nil {
+				err = checkCleanTree(r)
+			}
+			if err != nil {
+				d.Warn(fmt.Sprintf("Error promoting resource %s: %s\n", r.QualifiedName(), err))
+				continue
+			}
 			toVer, fromVer, err := vb.Promote()
 			if err != nil {
 				d.Warn(fmt.Sprintf("Error promoting resource %s: %s\n", r.QualifiedName(), err))
@@ -120,6 +332,10 @@ func PromoteResources(args []string) {
 				msg := forgeVersionBumpMessage(fromVer, toVer)
 				msg = fmt.Sprintf("Promoted resource %s: %s\n", r.QualifiedName(), msg)
 				d.Display(msg)
+				err = commitVersionChange(r, "Promote", fromVer, toVer, false)
+				if err != nil {
+					d.Warn(fmt.Sprintf("Error committing promote of resource %s: %s\n", r.QualifiedName(), err))
+				}
 			}
 		}
 	}
@@ -129,14 +345,26 @@ func PromoteResources(args []string) {
 }
 
 func ReleaseResources(args []string) {
+	if ReleaseTrain {
+		ReleaseTrains(args)
+		return
+	}
+
 	d := display.Service()
 	d.Info("Release starting ...")
 
 	res := ResolveExpression(args, resources.ImageKind)
 	for _, r := range res {
-		var i interface{} = r
-		vb, ok := i.(resources.VersionBumper)
+		vb, ok := asVersionBumper(r)
 		if ok {
+			err := checkTested(r, ForceRelease)
+			if err == nil {
+				err = checkCleanTree(r)
+			}
+			if err != nil {
+				d.Warn(fmt.Sprintf("Error releasing resource %s: %s\n", r.QualifiedName(), err))
+				continue
+			}
 			toVer, fromVer, err := vb.Release()
 			if err != nil {
 				d.Warn(fmt.Sprintf("Error releasing resource %s: %s\n", r.QualifiedName(), err))
@@ -144,6 +372,10 @@ func ReleaseResources(args []string) {
 				msg := forgeVersionBumpMessage(fromVer, toVer)
 				msg = fmt.Sprintf("Released resource %s: %s\n", r.QualifiedName(), msg)
 				d.Display(msg)
+				err = commitVersionChange(r, "Release", fromVer, toVer, true)
+				if err != nil {
+					d.Warn(fmt.Sprintf("Error committing release of resource %s: %s\n", r.QualifiedName(), err))
+				}
 			}
 		}
 	}
@@ -152,41 +384,213 @@ func ReleaseResources(args []string) {
 	d.Info("Release finished")
 }
 
-func buildResource(res resources.Resourcer) error {
-	builder, err := build.New(res)
+// Write the changelog of each image and the release notes of their projects
+func ChangelogResources(args []string) {
+	d := display.Service()
+	d.Info("Changelog starting ...")
+
+	res := ResolveExpression(args, resources.ImageKind)
+	releases := map[string][]changelog.Release{}
+	projects := map[string]resources.Project{}
+	for _, r := range res {
+		var image resources.Image
+		switch v := r.(type) {
+		case *resources.Image:
+			image = *v
+		case resources.Image:
+			image = v
+		default:
+			continue
+		}
+		release, err := imageChangelog(image)
+		if err == nil {
+			err = changelog.WriteChangelog(filepath.Join(image.Dir(), changelog.DefaultChangelogFile), release)
+		}
+		if err != nil {
+			d.Warn(fmt.Sprintf("Error writing changelog of resource %s: %s\n", r.QualifiedName(), err))
+			continue
+		}
+		since := release.Previous
+		if since == "" {
+			since = "first commit"
+		}
+		d.Display(fmt.Sprintf("Wrote changelog of resource %s: %d changes since %s, %s bump\n", r.QualifiedName(), len(release.Entries), since, release.Level()))
+		projectDir := image.Project.Dir()
+		projects[projectDir] = image.Project
+		releases[projectDir] = append(releases[projectDir], release)
+	}
+
+	for dir, project := range projects {
+		err := changelog.WriteReleaseNotes(filepath.Join(dir, changelog.DefaultReleaseNotesFile), project.Name(), releases[dir])
+		if err != nil {
+			d.Warn(fmt.Sprintf("Error writing release notes of resource %s: %s\n", project.QualifiedName(), err))
+		}
+	}
+
+	d.Flush()
+	d.Info("Changelog finished")
+}
+
+func asProject(r resources.Resourcer) (project resources.Project, ok bool) {
+	switch v := r.(type) {
+	case *resources.Project:
+		return *v, true
+	case resources.Project:
+		return v, true
+	}
+	return
+}
+
+// Release all images of a project or none of them
+func releaseTrain(d display.Displayer, project resources.Project) (err error) {
+	t, err := train.Plan(project, func(image resources.Image) error {
+		err := checkTested(image, ForceRelease)
+		if err == nil {
+			err = checkCleanTree(image)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+	m, err := t.Apply()
+	if err != nil {
+		return
+	}
+	for _, transition := range t.Transitions {
+		msg := forgeVersionBumpMessage(transition.From, transition.To)
+		d.Display(fmt.Sprintf("Released resource %s: %s\n", transition.Image.QualifiedName(), msg))
+		err = commitVersionChange(transition.Image, "Release", transition.From, transition.To, true)
+		if err != nil {
+			d.Warn(fmt.Sprintf("Error committing release of resource %s: %s\n", transition.Image.QualifiedName(), err))
+		}
+	}
+	d.Display(fmt.Sprintf("Released train %s %s\n", m.Project, m.Version))
+	return nil
+}
+
+func ReleaseTrains(args []string) {
+	d := display.Service()
+	d.Info("Release train starting ...")
+
+	res := ResolveExpression(args, resources.ProjectKind)
+	errors := errorz.Aggregated{}
+	for _, r := range res {
+		project, ok := asProject(r)
+		if !ok {
+			continue
+		}
+		err := releaseTrain(d, project)
+		if err != nil {
+			errors.Add(fmt.Errorf("train of %s not released: %w", project.QualifiedName(), err))
+		}
+	}
+	if errors.GotError() {
+		fatal(d, fmt.Sprintf("Encountered error during release train phase: %s", errors))
+	}
+
+	d.Flush()
+	d.Info("Release train finished")
+}
+
+func writeReleaseTrains(w io.Writer, project resources.Project, manifests []train.Manifest) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "--- Release trains of %s\n", project.QualifiedName())
+	fmt.Fprintln(tw, "VERSION\tDATE\tIMAGES")
+	for _, m := range manifests {
+		var images []string
+		for name, version := range m.Images {
+			images = append(images, name+":"+version)
+		}
+		sort.Strings(images)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Version, m.Date.Format(time.RFC3339), strings.Join(images, " "))
+	}
+	return tw.Flush()
+}
+
+func ReleaseTrainsHistory(args []string) {
+	d := display.Service()
+	d.Info("Release trains starting ...")
+
+	res := ResolveExpression(args, resources.ProjectKind)
+	for _, r := range res {
+		project, ok := asProject(r)
+		if !ok {
+			continue
+		}
+		manifests, err := train.Manifests(project)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error during release trains phase: %s", err))
+		}
+		builder := strings.Builder{}
+		err = writeReleaseTrains(&builder, project, manifests)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error writing release trains: %s", err))
+		}
+		d.Display(builder.String())
+	}
+
+	d.Flush()
+	d.Info("Release trains finished")
+}
+
+func buildResources(ctx context.Context, res []resources.Resourcer) error {
+	// A single builder for all resources to respect dependencies between images
+	builder, err := build.New(res...)
 	if err != nil {
 		return err
 	}
-	err = builder.Build(!ForceBuild, NoCacheBuild, ForcePull)
+	err = builder.Build(ctx, poolOptions(), !ForceBuild, NoCacheBuild, ForcePull, AllowOverwrite)
 	//fmt.Println("Build finished")
 	return err
 }
 
 func BuildResources(args []string) {
+	if DryRun {
+		PlanResources(BuildPhase, args)
+		return
+	}
+
 	d := display.Service()
 	d.Info("Build starting ...")
 
 	res := ResolveExpression(args, resources.AllKind)
-	builder := func(r resources.Resourcer) (void interface{}, err error) {
-		err = buildResource(r)
-		return
+	err := buildResources(actionContext(), res)
+	if err == nil {
+		err = lockResources(res)
 	}
-	_, err := concurrent.RunWaiting(builder, res...)
 	if err != nil {
-		d.Fatal(fmt.Sprintf("Encountered error during build phase: %s", err))
+		fatal(d, fmt.Sprintf("Encountered error during build phase: %s", err))
 	}
 
 	d.Flush()
 	d.Info("Build finished")
 }
 
-func pullResource(res resources.Resourcer) error {
+func PushResources(args []string) {
+	d := display.Service()
+	d.Info("Push starting ...")
+
+	res := ResolveExpression(args, resources.AllKind)
+	pusher, err := push.New(res...)
+	if err == nil {
+		err = pusher.Push(actionContext(), ForcePush, AllowOverwrite)
+	}
+	if err != nil {
+		fatal(d, fmt.Sprintf("Encountered error during push phase: %s", err))
+	}
+
+	d.Flush()
+	d.Info("Push finished")
+}
+
+func pullResource(ctx context.Context, res resources.Resourcer) error {
 	deployer, err := deploy.New(res)
 	if err != nil {
 		return err
 	}
 
-	err = deployer.Pull()
+	err = deployer.Pull(ctx)
 	//fmt.Println("Build finished")
 	return err
 }
@@ -196,103 +600,483 @@ func PullResources(args []string) {
 	d.Info("Pull starting ...")
 
 	res := ResolveExpression(args, resources.AllKind)
-	puller := func(r resources.Resourcer) (void interface{}, err error) {
-		err = pullResource(r)
+	puller := func(ctx context.Context, r resources.Resourcer) (void interface{}, err error) {
+		err = pullResource(ctx, r)
 		return
 	}
-	_, err := concurrent.RunWaiting(puller, res...)
+	_, err := concurrent.RunWaitingContext(actionContext(), poolOptions(), puller, res...)
+	if err == nil {
+		err = lockResources(res)
+	}
 	if err != nil {
-		d.Fatal(fmt.Sprintf("Encountered error during pull phase: %s", err))
+		fatal(d, fmt.Sprintf("Encountered error during pull phase: %s", err))
 	}
 
 	d.Flush()
 	d.Info("Pull finished")
 }
 
-func upResource(res resources.Resourcer) error {
-	deployer, err := deploy.New(res)
+// Record built or pulled images of resources in the lock file. Images not found locally are skipped.
+func lockResources(res []resources.Resourcer) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	images, err := lock.Images(res)
+	if err != nil {
+		return
+	}
+	entries := map[string]lock.Entry{}
+	for _, image := range images {
+		imageId, err := deploy.ImageId(image)
+		if err != nil {
+			return err
+		}
+		if imageId == "" {
+			continue
+		}
+		entries[image.Name()], err = lock.NewEntry(image, imageId)
+		if err != nil {
+			return err
+		}
+	}
+	return lock.Update(entries)
+}
+
+// Drifts between the lock file and the working tree of resources images
+func lockDrifts(res []resources.Resourcer) (drifts []lock.Drift, err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	l, err := lock.Read()
+	if err != nil {
+		return
+	}
+	images, err := lock.Images(res)
+	if err != nil {
+		return
+	}
+	for _, image := range images {
+		imageId, err := deploy.ImageId(image)
+		if err != nil {
+			return nil, err
+		}
+		imageDrifts, err := l.Verify(image, imageId)
+		if err != nil {
+			return nil, err
+		}
+		if imageId == "" && len(imageDrifts) == 0 {
+			imageDrifts = []lock.Drift{{Image: image.Name(), Field: lock.ImageIdField, Locked: l.Images[image.Name()].ImageId}}
+		}
+		drifts = append(drifts, imageDrifts...)
+	}
+	return
+}
+
+// Resources images must be available locally as locked
+func checkLocked(res []resources.Resourcer) (err error) {
+	drifts, err := lockDrifts(res)
+	if err != nil {
+		return
+	}
+	if len(drifts) > 0 {
+		errors := errorz.Aggregated{}
+		for _, drift := range drifts {
+			errors.Add(fmt.Errorf("%w: %s", lock.Drifted, drift))
+		}
+		return errors
+	}
+	return
+}
+
+func writeDrifts(w io.Writer, drifts []lock.Drift) error {
+	abbrev := func(value string) string {
+		if len(value) > 19 {
+			return value[:19]
+		}
+		return value
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tFIELD\tLOCKED\tCURRENT")
+	for _, drift := range drifts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", drift.Image, drift.Field, abbrev(drift.Locked), abbrev(drift.Current))
+	}
+	return tw.Flush()
+}
+
+// Report drifts between the lock file and the working tree
+func VerifyResources(args []string) {
+	d := display.Service()
+	d.Info("Verify starting ...")
+
+	res := ResolveExpression(args, resources.AllKind)
+	drifts, err := lockDrifts(res)
+	if err != nil {
+		fatal(d, fmt.Sprintf("Encountered error during verify phase: %s", err))
+	}
+	if len(drifts) > 0 {
+		builder := strings.Builder{}
+		err = writeDrifts(&builder, drifts)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error writing drifts: %s", err))
+		}
+		d.Display(builder.String())
+		fatal(d, fmt.Sprintf("%s: %d drift(s)", lock.Drifted, len(drifts)))
+	}
+	d.Display("Working tree matches the lock\n")
+
+	d.Flush()
+	d.Info("Verify finished")
+}
+
+// Image ids by image name of the lock file
+func lockedIds() (ids map[string]string, err error) {
+	l, err := lock.Read()
+	if err != nil {
+		return
+	}
+	ids = map[string]string{}
+	for name, entry := range l.Images {
+		ids[name] = entry.ImageId
+	}
+	return
+}
+
+// If lockedIds is not nil, images are deployed by their locked id
+func upResource(ctx context.Context, res resources.Resourcer, lockedIds map[string]string) (err error) {
+	var deployer deploy.Deployer
+	if lockedIds != nil {
+		deployer, err = deploy.NewLocked(res, lockedIds)
+	} else {
+		deployer, err = deploy.New(res)
+	}
 	if err != nil {
 		return err
 	}
 
-	err = deployer.Deploy()
+	err = deployer.Deploy(ctx, !ForceDeploy)
 	//fmt.Println("Build finished")
 	return err
 }
 
 func UpResources(args []string) {
-	if ForcePull {
+	if DryRun {
+		PlanResources(UpPhase, args)
+		return
+	}
+
+	d := display.Service()
+	res := ResolveExpression(args, resources.AllKind)
+	var ids map[string]string
+	if Locked {
+		// Deploy images by their locked id without building nor pulling them
+		err := checkLocked(res)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error during up phase: %s", err))
+		}
+		ids, err = lockedIds()
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error during up phase: %s", err))
+		}
+	} else if ForcePull {
 		PullResources(args)
 	} else {
 		BuildResources(args)
 	}
 
-	d := display.Service()
 	d.Info("Up starting ...")
 
-	res := ResolveExpression(args, resources.AllKind)
-	upper := func(r resources.Resourcer) (void interface{}, err error) {
-		err = upResource(r)
+	upper := func(ctx context.Context, r resources.Resourcer) (void interface{}, err error) {
+		err = upResource(ctx, r, ids)
 		return
 	}
-	_, err := concurrent.RunWaiting(upper, res...)
+	_, err := concurrent.RunWaitingContext(actionContext(), poolOptions(), upper, res...)
 	if err != nil {
-		d.Fatal(fmt.Sprintf("Encountered error during up phase: %s", err))
+		fatal(d, fmt.Sprintf("Encountered error during up phase: %s", err))
 	}
 
 	d.Flush()
 	d.Info("Up finished")
 }
 
-func downResource(res resources.Resourcer) error {
+func downResource(ctx context.Context, res resources.Resourcer) error {
 	deployer, err := deploy.New(res)
 	if err != nil {
 		return err
 	}
 
-	err = deployer.Undeploy(RmVolumes)
+	err = deployer.Undeploy(ctx, RmVolumes)
 	return err
 }
 
 func DownResources(args []string) {
+	if DryRun {
+		PlanResources(DownPhase, args)
+		return
+	}
+
 	d := display.Service()
 	d.Info("Down starting ...")
 
 	res := ResolveExpression(args, resources.AllKind)
-	downer := func(r resources.Resourcer) (void interface{}, err error) {
-		err = downResource(r)
+	downer := func(ctx context.Context, r resources.Resourcer) (void interface{}, err error) {
+		err = downResource(ctx, r)
 		return
 	}
-	_, err := concurrent.RunWaiting(downer, res...)
+	_, err := concurrent.RunWaitingContext(actionContext(), poolOptions(), downer, res...)
 	if err != nil {
-		d.Fatal(fmt.Sprintf("Encountered error during down phase: %s", err))
+		fatal(d, fmt.Sprintf("Encountered error during down phase: %s", err))
 	}
 
 	d.Flush()
 	d.Info("Down finished")
 }
 
-func TestResources(args []string) {
-	UpResources(args)
-
+// Deploy resources in a namespace and run their tests collecting results in report
+func testInNamespace(ns deploy.Namespace, res []resources.Resourcer, reportDir string, report *testing.Report) (err error) {
 	d := display.Service()
-	d.Info("Test starting ...")
+	upper := func(ctx context.Context, r resources.Resourcer) (void interface{}, err error) {
+		deployer, err := deploy.NewNamespaced(ns, r)
+		if err != nil {
+			return
+		}
+		err = deployer.Deploy(ctx, false)
+		return
+	}
+	_, err = concurrent.RunWaitingContext(actionContext(), poolOptions(), upper, res...)
+	if err != nil {
+		return fmt.Errorf("Encountered error during up phase: %w", err)
+	}
 
-	res := ResolveExpression(args, resources.AllKind)
 	d.Info(fmt.Sprintf("Will test resources:"))
 	for _, r := range res {
 		d.Info(fmt.Sprintf(" - %s", r.QualifiedName()))
 	}
 
-	tester := func(r resources.Resourcer) (void interface{}, err error) {
-		err = testing.VenomTests(d, r)
+	endpoints, err := ns.Endpoints(res...)
+	if err != nil {
+		return fmt.Errorf("Encountered error listing endpoints: %w", err)
+	}
+	target := testing.Target{Namespace: ns, Variables: deploy.EndpointsVariables(endpoints)}
+
+	// Results are gathered even for failing resources
+	var mutex sync.Mutex
+	testsDir := filepath.Join(reportDir, "tests")
+	tester := func(ctx context.Context, r resources.Resourcer) (void interface{}, err error) {
+		results, err := testing.ResourceTests(ctx, d, target, testsDir, r)
+		mutex.Lock()
+		report.Add(results...)
+		mutex.Unlock()
 		return
 	}
-	_, err := concurrent.RunWaiting(tester, res...)
+	_, err = concurrent.RunWaitingContext(actionContext(), poolOptions(), tester, res...)
 	if err != nil {
-		d.Fatal(fmt.Sprintf("Encountered error during test phase: %s", err))
+		return fmt.Errorf("Encountered error during test phase: %w", err)
+	}
+	return
+}
+
+// Undeploy resources with their volumes and remove the namespace network
+func teardownNamespace(ns deploy.Namespace, res []resources.Resourcer) error {
+	errors := errorz.Aggregated{}
+	for _, r := range res {
+		deployer, err := deploy.NewNamespaced(ns, r)
+		if err == nil {
+			// Teardown must not be cancelled
+			err = deployer.Undeploy(context.Background(), true)
+		}
+		errors.Add(err)
+	}
+	errors.Add(ns.Remove())
+	return errors.Return()
+}
+
+func collectDiagnostics(ns deploy.Namespace, res []resources.Resourcer) (dir string, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	dir = filepath.Join(ss.CacheDir(), "diagnostics", ns.Suffix)
+	err = ns.CollectDiagnostics(dir, res...)
+	return
+}
+
+// Record test results of images for promote and release gates
+func recordTestResults(res []resources.Resourcer, report testing.Report) (err error) {
+	err = change.Init()
+	if err != nil {
+		return
+	}
+	images := map[string]resources.Image{}
+	for _, r := range res {
+		switch v := r.(type) {
+		case *resources.Image:
+			images[v.QualifiedName()] = *v
+		case resources.Image:
+			images[v.QualifiedName()] = v
+		}
+		if project, ok := asProject(r); ok {
+			projectImages, err := project.Images()
+			if err != nil {
+				return err
+			}
+			for _, i := range projectImages {
+				images[i.QualifiedName()] = *i
+			}
+		}
+	}
+	date := time.Now()
+	for _, result := range report.Results {
+		image, ok := images[result.Resource]
+		if !ok || result.Status == testing.SkippedStatus {
+			continue
+		}
+		record := change.TestRecord{Status: string(result.Status), Passed: !result.Failed(), Tests: result.Tests, Failures: result.Failures, Duration: result.Duration, Date: date}
+		err = change.RecordTest(image, record)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeTestHistory(w io.Writer, image resources.Image, records []change.TestRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "--- Test history of %s\n", image.QualifiedName())
+	fmt.Fprintln(tw, "DATE\tIMAGE\tSIGNATURE\tSTATUS\tTESTS\tFAILURES\tDURATION")
+	for _, r := range records {
+		signature := r.Signature
+		if len(signature) > 12 {
+			signature = signature[:12]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%.1fs\n", r.Date.Format(time.RFC3339), r.FullName, signature, strings.ToUpper(r.Status), r.Tests, r.Failures, r.Duration)
+	}
+	return tw.Flush()
+}
+
+func TestHistoryResources(args []string) {
+	d := display.Service()
+	d.Info("Test history starting ...")
+
+	err := change.Init()
+	if err != nil {
+		fatal(d, fmt.Sprintf("Encountered error during test history phase: %s", err))
+	}
+	res := ResolveExpression(args, resources.ImageKind)
+	for _, r := range res {
+		var image resources.Image
+		switch v := r.(type) {
+		case *resources.Image:
+			image = *v
+		case resources.Image:
+			image = v
+		default:
+			continue
+		}
+		records, err := change.TestHistory(image)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error during test history phase: %s", err))
+		}
+		builder := strings.Builder{}
+		err = writeTestHistory(&builder, image, records)
+		if err != nil {
+			fatal(d, fmt.Sprintf("Encountered error writing test history: %s", err))
+		}
+		d.Display(builder.String())
+	}
+
+	d.Flush()
+	d.Info("Test history finished")
+}
+
+// Report dir of a test run: --report or a namespace dir in the cache dir
+func testReportDir(ns deploy.Namespace) (dir string, err error) {
+	if TestReport != "" {
+		return TestReport, nil
+	}
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	dir = filepath.Join(ss.CacheDir(), "reports", ns.Suffix)
+	return
+}
+
+func TestResources(args []string) {
+	if ForcePull {
+		PullResources(args)
+	} else {
+		BuildResources(args)
+	}
+
+	d := display.Service()
+	res := ResolveExpression(args, resources.AllKind)
+	ns, err := deploy.NewNamespace("test")
+	if err == nil {
+		err = ns.Create()
+	}
+	if err != nil {
+		fatal(d, fmt.Sprintf("Encountered error creating test namespace: %s", err))
+	}
+	reportDir, err := testReportDir(ns)
+	if err != nil {
+		fatal(d, fmt.Sprintf("Encountered error during test phase: %s", err))
+	}
+	d.Info(fmt.Sprintf("Test starting in namespace %s ...", ns.Suffix))
+	if !KeepTestEnv {
+		// Compose deployed containers are not temporary: tear the namespace down on interruption
+		unregister := interrupt.OnInterrupt(func() {
+			teardownErr := teardownNamespace(ns, res)
+			if teardownErr != nil {
+				d.Warn(fmt.Sprintf("Encountered error during teardown: %s", teardownErr))
+			}
+		})
+		defer unregister()
+	}
+
+	report := testing.Report{}
+	err = testInNamespace(ns, res, reportDir, &report)
+	if err != nil {
+		dir, diagErr := collectDiagnostics(ns, res)
+		if diagErr != nil {
+			d.Warn(fmt.Sprintf("Unable to collect diagnostics: %s", diagErr))
+		} else {
+			d.Info(fmt.Sprintf("Diagnostics collected in %s", dir))
+		}
+	}
+
+	recordErr := recordTestResults(res, report)
+	if recordErr != nil {
+		d.Warn(fmt.Sprintf("Unable to record test results: %s", recordErr))
+	}
+
+	if KeepTestEnv {
+		d.Info(fmt.Sprintf("Keeping test namespace %s", ns.Suffix))
+	} else {
+		teardownErr := teardownNamespace(ns, res)
+		if teardownErr != nil {
+			d.Warn(fmt.Sprintf("Encountered error during teardown: %s", teardownErr))
+		}
 	}
 
 	d.Flush()
+	reportErr := report.Write(reportDir)
+	if reportErr != nil {
+		d.Warn(fmt.Sprintf("Unable to write test report: %s", reportErr))
+	} else {
+		d.Info(fmt.Sprintf("Test report written in %s", reportDir))
+	}
+	summary := strings.Builder{}
+	report.WriteSummary(&summary)
+	d.Display(summary.String())
+
+	if err != nil {
+		fatal(d, err.Error())
+	}
+	if report.Failed() {
+		fatal(d, testing.TestsFailed.Error())
+	}
+
 	d.Info("Test finished")
 }
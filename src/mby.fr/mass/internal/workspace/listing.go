@@ -0,0 +1,196 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/settings"
+	"mby.fr/utils/errorz"
+)
+
+const (
+	TableOutput = "table"
+	JsonOutput  = "json"
+	YamlOutput  = "yaml"
+)
+
+var (
+	ListOutput string
+	ListFormat string
+)
+
+var UnknownOutput error = fmt.Errorf("Unknown output")
+
+type ResourceRow struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Name      string `json:"name" yaml:"name"`
+	Dir       string `json:"directory" yaml:"directory"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	BuildFile string `json:"buildFile,omitempty" yaml:"buildFile,omitempty"`
+	TestDir   string `json:"testDirectory,omitempty" yaml:"testDirectory,omitempty"`
+}
+
+func describeResource(r resources.Resourcer) (row ResourceRow) {
+	row = ResourceRow{Kind: r.Kind().String(), Name: r.QualifiedName(), Dir: r.Dir()}
+	var i interface{} = r
+	if v, ok := i.(resources.Versioner); ok {
+		row.Version = v.Version()
+	}
+	if t, ok := i.(resources.Tester); ok {
+		row.TestDir = t.AbsTestDir()
+	}
+	switch v := r.(type) {
+	case resources.Image:
+		row.BuildFile = v.AbsBuildFile()
+	case *resources.Image:
+		row.BuildFile = v.AbsBuildFile()
+	}
+	return
+}
+
+// Scan all workspace resources
+func scanAllResources() (res []resources.Resourcer, err error) {
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	envs, err := resources.ScanEnvs(ss.EnvsDir())
+	if err != nil {
+		return
+	}
+	for _, r := range envs {
+		res = append(res, r)
+	}
+	projects, err := resources.ScanProjects(ss.ProjectsDir())
+	if err != nil {
+		return
+	}
+	for _, r := range projects {
+		res = append(res, r)
+	}
+	images, err := resources.ScanImages(ss.ProjectsDir())
+	if err != nil {
+		return
+	}
+	for _, r := range images {
+		res = append(res, r)
+	}
+	return
+}
+
+// List resources matching expression. A project is listed with its images.
+func listResources(args []string) (rows []ResourceRow, errors errorz.Aggregated) {
+	var res []resources.Resourcer
+	if len(args) == 0 {
+		all, err := scanAllResources()
+		if err != nil {
+			errors.Add(err)
+			return
+		}
+		res = all
+	} else {
+		resolved, errs := resources.ResolveExpression(strings.Join(args, " "), resources.AllKind)
+		errors.Concat(errs)
+		for _, r := range resolved {
+			res = append(res, r)
+			if p, ok := r.(resources.Project); ok {
+				images, err := p.Images()
+				if err != nil {
+					errors.Add(err)
+					continue
+				}
+				for _, i := range images {
+					res = append(res, *i)
+				}
+			}
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, r := range res {
+		row := describeResource(r)
+		if !listed[row.Name] {
+			listed[row.Name] = true
+			rows = append(rows, row)
+		}
+	}
+	return
+}
+
+func writeTable(w io.Writer, rows []ResourceRow) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tVERSION\tDIRECTORY\tBUILD FILE\tTEST DIRECTORY")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Kind, r.Name, r.Version, r.Dir, r.BuildFile, r.TestDir)
+	}
+	return tw.Flush()
+}
+
+// Write resource rows in table, json or yaml output. A go template format takes precedence over output.
+func WriteResourceRows(w io.Writer, rows []ResourceRow, output, format string) (err error) {
+	if rows == nil {
+		rows = []ResourceRow{}
+	}
+	if format != "" {
+		t, err := template.New("format").Parse(format)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			err = t.Execute(w, r)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	switch output {
+	case TableOutput, "":
+		err = writeTable(w, rows)
+	case JsonOutput:
+		content, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", content)
+		return err
+	case YamlOutput:
+		content, err := yaml.Marshal(rows)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	default:
+		err = fmt.Errorf("%w: %s", UnknownOutput, output)
+	}
+	return
+}
+
+func ListResources(args []string) {
+	d := display.Service()
+	d.Info("List starting ...")
+
+	rows, errors := listResources(args)
+	printErrors(errors)
+
+	builder := strings.Builder{}
+	err := WriteResourceRows(&builder, rows, ListOutput, ListFormat)
+	if err != nil {
+		d.Fatal(fmt.Sprintf("Encountered error listing resources: %s", err))
+	}
+	d.Display(builder.String())
+
+	d.Flush()
+	d.Info("List finished")
+}
@@ -0,0 +1,86 @@
+package workspace
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestListResources(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	rows, errors := listResources(nil)
+	require.False(t, errors.GotError(), "should not error: %s", errors)
+	names := []string{}
+	for _, r := range rows {
+		names = append(names, r.Name)
+	}
+	assert.Contains(t, names, "env/dev", "should list default envs")
+	assert.Contains(t, names, "project/p1")
+	assert.Contains(t, names, "image/p1/i1")
+
+	rows, errors = listResources([]string{"p1"})
+	require.False(t, errors.GotError(), "should not error: %s", errors)
+	require.Len(t, rows, 2, "project should be listed with its image")
+	assert.Equal(t, "project/p1", rows[0].Name)
+	assert.Equal(t, ResourceRow{
+		Kind:      "image",
+		Name:      "image/p1/i1",
+		Dir:       image.Dir(),
+		Version:   image.Version(),
+		BuildFile: image.AbsBuildFile(),
+		TestDir:   image.AbsTestDir(),
+	}, rows[1])
+}
+
+func TestWriteResourceRows(t *testing.T) {
+	rows := []ResourceRow{
+		{Kind: "project", Name: "project/p1", Dir: "/wks/p1", TestDir: "/wks/p1/test"},
+		{Kind: "image", Name: "image/p1/i1", Dir: "/wks/p1/i1", Version: "0.0.1-dev", BuildFile: "/wks/p1/i1/Dockerfile", TestDir: "/wks/p1/i1/test"},
+	}
+
+	b := strings.Builder{}
+	err := WriteResourceRows(&b, rows, TableOutput, "")
+	require.NoError(t, err, "should not error")
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 3, "should output header and 2 lines")
+	assert.Equal(t, strings.Index(lines[0], "DIRECTORY"), strings.Index(lines[2], "/wks/p1/i1"), "columns should be aligned")
+
+	b.Reset()
+	err = WriteResourceRows(&b, rows, JsonOutput, "")
+	require.NoError(t, err, "should not error")
+	var fromJson []ResourceRow
+	err = json.Unmarshal([]byte(b.String()), &fromJson)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, rows, fromJson)
+
+	b.Reset()
+	err = WriteResourceRows(&b, rows, YamlOutput, "")
+	require.NoError(t, err, "should not error")
+	var fromYaml []ResourceRow
+	err = yaml.Unmarshal([]byte(b.String()), &fromYaml)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, rows, fromYaml)
+
+	b.Reset()
+	err = WriteResourceRows(&b, rows, TableOutput, "{{.Name}} {{.Version}}")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "project/p1 \nimage/p1/i1 0.0.1-dev\n", b.String())
+
+	err = WriteResourceRows(&b, rows, "xml", "")
+	assert.ErrorIs(t, err, UnknownOutput)
+}
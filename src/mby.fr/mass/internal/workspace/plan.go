@@ -0,0 +1,159 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/build"
+	"mby.fr/mass/internal/deploy"
+	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/plan"
+	"mby.fr/mass/internal/resources"
+)
+
+const (
+	BuildPhase = "build"
+	UpPhase    = "up"
+	DownPhase  = "down"
+)
+
+var (
+	DryRun     bool
+	PlanOutput string
+)
+
+var UnknownPhase error = fmt.Errorf("Unknown phase")
+
+func planBuild(res []resources.Resourcer) (plan.Plan, error) {
+	builder, err := build.New(res...)
+	if err != nil {
+		return nil, err
+	}
+	return builder.Plan(!ForceBuild, NoCacheBuild, ForcePull)
+}
+
+func planPull(res []resources.Resourcer) (p plan.Plan, err error) {
+	for _, r := range res {
+		deployer, err := deploy.New(r)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := deployer.PlanPull()
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, steps...)
+	}
+	return
+}
+
+// Up builds or pulls images before deploying them
+func planUp(res []resources.Resourcer) (p plan.Plan, err error) {
+	if ForcePull {
+		p, err = planPull(res)
+	} else {
+		p, err = planBuild(res)
+	}
+	if err != nil {
+		return
+	}
+	rebuilt := map[string]bool{}
+	for _, s := range p {
+		if s.Action == plan.BuildAction || s.Action == plan.PullAction {
+			rebuilt[s.Resource] = true
+		}
+	}
+	for _, r := range res {
+		deployer, err := deploy.New(r)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := deployer.PlanDeploy(!ForceDeploy, rebuilt)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, steps...)
+	}
+	return
+}
+
+func planDown(res []resources.Resourcer) (p plan.Plan, err error) {
+	for _, r := range res {
+		deployer, err := deploy.New(r)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := deployer.PlanUndeploy(RmVolumes)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, steps...)
+	}
+	return
+}
+
+func planPhase(phase string, res []resources.Resourcer) (plan.Plan, error) {
+	switch phase {
+	case BuildPhase:
+		return planBuild(res)
+	case UpPhase, "":
+		return planUp(res)
+	case DownPhase:
+		return planDown(res)
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownPhase, phase)
+	}
+}
+
+func WritePlan(w io.Writer, p plan.Plan, output string) (err error) {
+	if p == nil {
+		p = plan.Plan{}
+	}
+	switch output {
+	case TableOutput, "":
+		err = p.Write(w)
+	case JsonOutput:
+		content, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", content)
+		return err
+	case YamlOutput:
+		content, err := yaml.Marshal(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	default:
+		err = fmt.Errorf("%w: %s", UnknownOutput, output)
+	}
+	return
+}
+
+// Display what a phase would do on resources without running anything
+func PlanResources(phase string, args []string) {
+	d := display.Service()
+	d.Info("Plan starting ...")
+
+	res := ResolveExpression(args, resources.AllKind)
+	p, err := planPhase(phase, res)
+	if err != nil {
+		d.Fatal(fmt.Sprintf("Encountered error during plan phase: %s", err))
+	}
+
+	builder := strings.Builder{}
+	err = WritePlan(&builder, p, PlanOutput)
+	if err != nil {
+		d.Fatal(fmt.Sprintf("Encountered error writing plan: %s", err))
+	}
+	d.Display(builder.String())
+
+	d.Flush()
+	d.Info("Plan finished")
+}
@@ -0,0 +1,84 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/plan"
+	"mby.fr/mass/internal/resources"
+)
+
+func TestPlanPhase(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	res := []resources.Resourcer{image}
+
+	p, err := planPhase(BuildPhase, res)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 1, "should plan one build")
+	assert.Equal(t, "build", p[0].Phase)
+	assert.Equal(t, "image/p1/i1", p[0].Resource)
+	assert.Equal(t, plan.BuildAction, p[0].Action)
+	assert.Equal(t, "signature changed", p[0].Reason)
+	require.Len(t, p[0].Commands, 1)
+	assert.True(t, strings.HasPrefix(p[0].Commands[0], "docker build -t "+image.FullName()), "should plan docker build: %s", p[0].Commands[0])
+
+	p, err = planPhase(UpPhase, res)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 2, "should plan build then up")
+	assert.Equal(t, "up", p[1].Phase)
+	assert.Equal(t, plan.CreateAction, p[1].Action)
+	assert.Equal(t, "image rebuilt", p[1].Reason)
+	require.Len(t, p[1].Commands, 2)
+	assert.Equal(t, "docker rm -f ", p[1].Commands[0][:13])
+	assert.True(t, strings.HasPrefix(p[1].Commands[1], "docker run "), "should plan docker run: %s", p[1].Commands[1])
+	assert.True(t, strings.HasSuffix(p[1].Commands[1], image.FullName()), "should run image: %s", p[1].Commands[1])
+
+	// Unchanged image should not be rebuilt but pulled as it is missing locally
+	err = change.StoreImageSignature(image)
+	require.NoError(t, err, "should not error")
+	p, err = planPhase(BuildPhase, res)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 1)
+	assert.Equal(t, plan.PullAction, p[0].Action)
+	assert.Equal(t, "signature unchanged, image missing", p[0].Reason)
+	assert.Empty(t, p[0].Commands, "should not plan any build command")
+
+	p, err = planPhase(DownPhase, res)
+	require.NoError(t, err, "should not error")
+	require.Len(t, p, 1)
+	assert.Equal(t, "down", p[0].Phase)
+	require.Len(t, p[0].Commands, 1)
+	assert.Equal(t, "docker rm -f ", p[0].Commands[0][:13])
+
+	_, err = planPhase("foo", res)
+	assert.ErrorIs(t, err, UnknownPhase)
+}
+
+func TestWritePlan(t *testing.T) {
+	p := plan.Plan{{Phase: "build", Resource: "image/p1/i1", Action: plan.BuildAction, Commands: []string{"docker build ."}}}
+
+	b := strings.Builder{}
+	err := WritePlan(&b, p, JsonOutput)
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, b.String(), `"action": "build"`)
+
+	b = strings.Builder{}
+	err = WritePlan(&b, p, YamlOutput)
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, b.String(), "- docker build .")
+
+	err = WritePlan(&b, p, "foo")
+	assert.ErrorIs(t, err, UnknownOutput)
+}
@@ -0,0 +1,48 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/change"
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/resources"
+	masstesting "mby.fr/mass/testing"
+)
+
+func TestRecordTestResults(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	_, err = resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	_, err = resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i2"))
+	require.NoError(t, err, "should not error")
+
+	images := ResolveExpression([]string{"p1/i1"}, resources.ImageKind)
+	projects := ResolveExpression([]string{"p1"}, resources.ProjectKind)
+	res := append(images, projects...)
+	report := masstesting.Report{}
+	report.Add(masstesting.Result{Resource: "image/p1/i1", Status: masstesting.PassedStatus, Tests: 1},
+		masstesting.Result{Resource: "image/p1/i2", Status: masstesting.FailedStatus, Tests: 1, Failures: 1})
+	err = recordTestResults(res, report)
+	require.NoError(t, err, "should not error")
+
+	i1, err := resources.Read[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	err = change.CheckTested(i1)
+	assert.NoError(t, err, "passing test should be recorded")
+	i2, err := resources.Read[resources.Image](filepath.Join(wksPath, "p1", "i2"))
+	require.NoError(t, err, "should not error")
+	err = change.CheckTested(i2)
+	assert.True(t, errors.Is(err, change.NoPassingTest), "failing test should be recorded")
+	history, err := change.TestHistory(i2)
+	require.NoError(t, err, "should not error")
+	assert.Len(t, history, 1, "project images should be recorded")
+}
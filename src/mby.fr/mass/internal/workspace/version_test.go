@@ -0,0 +1,119 @@
+package workspace
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"mby.fr/mass/internal/commontest"
+	"mby.fr/mass/internal/git"
+	"mby.fr/mass/internal/resources"
+	masstesting "mby.fr/mass/testing"
+)
+
+// Version of an image as written in its resource file
+func resourceFileVersion(t *testing.T, image resources.Image) string {
+	content, err := os.ReadFile(filepath.Join(image.Dir(), resources.DefaultResourceFile))
+	require.NoError(t, err, "should not error")
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &values)
+	require.NoError(t, err, "should not error")
+	return values["version"].(string)
+}
+
+// Record a passing test of the current version of an image
+func recordPassingTest(t *testing.T, name string) {
+	res := ResolveExpression([]string{name}, resources.ImageKind)
+	report := masstesting.Report{}
+	report.Add(masstesting.Result{Resource: "image/" + name, Status: masstesting.PassedStatus, Tests: 1})
+	err := recordTestResults(res, report)
+	require.NoError(t, err, "should not error")
+}
+
+func TestPromoteAndReleaseResources(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+	require.Equal(t, "0.0.1-dev", resourceFileVersion(t, image))
+
+	PromoteResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1-dev", resourceFileVersion(t, image), "untested image should not be promoted")
+
+	recordPassingTest(t, "p1/i1")
+	PromoteResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1-rc1", resourceFileVersion(t, image), "tested image should be promoted")
+
+	ReleaseResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1-rc1", resourceFileVersion(t, image), "untested promoted image should not be released")
+
+	recordPassingTest(t, "p1/i1")
+	ReleaseResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1", resourceFileVersion(t, image), "tested image should be released")
+}
+
+func TestForcePromoteResources(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	ForcePromote = true
+	defer func() { ForcePromote = false }()
+	PromoteResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1-rc1", resourceFileVersion(t, image), "forced promote should not require tests")
+}
+
+func TestReleaseResourcesTags(t *testing.T) {
+	wksPath := commontest.InitTempWorkspace(t)
+	defer os.RemoveAll(wksPath)
+	_, err := resources.Init[resources.Project](filepath.Join(wksPath, "p1"))
+	require.NoError(t, err, "should not error")
+	image, err := resources.Init[resources.Image](filepath.Join(wksPath, "p1", "i1"))
+	require.NoError(t, err, "should not error")
+
+	settingsFile := filepath.Join(wksPath, ".mass", "settings.yaml")
+	content, err := os.ReadFile(settingsFile)
+	require.NoError(t, err, "should not error")
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &values)
+	require.NoError(t, err, "should not error")
+	values["git"] = map[string]interface{}{"requireclean": true, "commit": true, "tag": true}
+	content, err = yaml.Marshal(values)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(settingsFile, content, 0644)
+	require.NoError(t, err, "should not error")
+
+	gitCommand := func(args ...string) string {
+		args = append([]string{"-C", wksPath, "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, "should not error: %s", out)
+		return string(out)
+	}
+	gitCommand("init", "-q")
+	gitCommand("config", "user.name", "tester")
+	gitCommand("config", "user.email", "tester@example.com")
+	gitCommand("config", "commit.gpgsign", "false")
+	gitCommand("config", "tag.gpgsign", "false")
+	gitCommand("add", ".")
+	gitCommand("commit", "-q", "-m", "init")
+
+	ForcePromote, ForceRelease = true, true
+	defer func() { ForcePromote, ForceRelease = false, false }()
+	PromoteResources([]string{"p1/i1"})
+	ReleaseResources([]string{"p1/i1"})
+	assert.Equal(t, "0.0.1", resourceFileVersion(t, image), "image should be released")
+	tags := gitCommand("tag", "--list")
+	assert.Contains(t, strings.Fields(tags), git.ReleaseTag("p1/i1", "0.0.1"), "release should be tagged")
+	assert.Contains(t, gitCommand("log", "-1", "--format=%s"), "Release image/p1/i1 0.0.1-rc1 => 0.0.1", "release should be committed")
+}
@@ -0,0 +1,251 @@
+package testing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var TestsFailed error = fmt.Errorf("Tests failed")
+
+type Status string
+
+const (
+	PassedStatus  Status = "passed"
+	FailedStatus  Status = "failed"
+	ErrorStatus   Status = "error"
+	SkippedStatus Status = "skipped"
+)
+
+// JUnit XML model, as produced by venom xUnit output
+type JUnitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites" json:"-"`
+	Suites  []JUnitTestSuite `xml:"testsuite" json:"suites"`
+}
+
+type JUnitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite" json:"-"`
+	Name     string          `xml:"name,attr" json:"name"`
+	Package  string          `xml:"package,attr,omitempty" json:"package,omitempty"`
+	Tests    int             `xml:"tests,attr" json:"tests"`
+	Failures int             `xml:"failures,attr" json:"failures"`
+	Errors   int             `xml:"errors,attr" json:"errors"`
+	Skipped  int             `xml:"skipped,attr,omitempty" json:"skipped,omitempty"`
+	Time     string          `xml:"time,attr,omitempty" json:"time,omitempty"`
+	Cases    []JUnitTestCase `xml:"testcase" json:"cases"`
+}
+
+type JUnitTestCase struct {
+	Classname string         `xml:"classname,attr,omitempty" json:"classname,omitempty"`
+	Name      string         `xml:"name,attr" json:"name"`
+	Time      string         `xml:"time,attr,omitempty" json:"time,omitempty"`
+	Failures  []JUnitFailure `xml:"failure,omitempty" json:"failures,omitempty"`
+	Errors    []JUnitFailure `xml:"error,omitempty" json:"errors,omitempty"`
+	Skipped   *JUnitFailure  `xml:"skipped,omitempty" json:"skipped,omitempty"`
+	SystemOut string         `xml:"system-out,omitempty" json:"systemOut,omitempty"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr,omitempty" json:"message,omitempty"`
+	Type    string `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Value   string `xml:",innerxml" json:"value,omitempty"`
+}
+
+// Count failed and errored test cases of suites
+func countFailures(suites []JUnitTestSuite) (tests, failures int) {
+	for _, s := range suites {
+		tests += len(s.Cases)
+		for _, c := range s.Cases {
+			if len(c.Failures) > 0 || len(c.Errors) > 0 {
+				failures++
+			}
+		}
+	}
+	return
+}
+
+// Parse a JUnit XML document rooted by testsuites or by a single testsuite
+func ParseJUnit(content []byte) (suites []JUnitTestSuite, err error) {
+	var all JUnitTestSuites
+	err = xml.Unmarshal(content, &all)
+	if err == nil {
+		return all.Suites, nil
+	}
+	var single JUnitTestSuite
+	if xml.Unmarshal(content, &single) == nil {
+		return []JUnitTestSuite{single}, nil
+	}
+	return
+}
+
+// Parse all JUnit XML files of a directory
+func readJUnitDir(dir string) (suites []JUnitTestSuite, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		fileSuites, err := ParseJUnit(content)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse xUnit file %s: %w", f, err)
+		}
+		suites = append(suites, fileSuites...)
+	}
+	return
+}
+
+// Outcome of the tests of one resource
+type Result struct {
+	Resource string           `json:"resource"`
+	Status   Status           `json:"status"`
+	Tests    int              `json:"tests"`
+	Failures int              `json:"failures"`
+	Duration float64          `json:"duration"` // In seconds
+	Error    string           `json:"error,omitempty"`
+	Suites   []JUnitTestSuite `json:"suites,omitempty"`
+}
+
+func newResult(resource string, suites []JUnitTestSuite, duration time.Duration, runErr error) (result Result) {
+	result.Resource = resource
+	result.Suites = suites
+	result.Tests, result.Failures = countFailures(suites)
+	result.Duration = duration.Seconds()
+	switch {
+	case result.Failures > 0:
+		result.Status = FailedStatus
+	case runErr != nil:
+		result.Status = ErrorStatus
+	default:
+		result.Status = PassedStatus
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	return
+}
+
+func (r Result) Failed() bool {
+	return r.Status == FailedStatus || r.Status == ErrorStatus
+}
+
+// Merged test results of resources
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+func (r *Report) Add(results ...Result) {
+	r.Results = append(r.Results, results...)
+	sort.SliceStable(r.Results, func(i, j int) bool {
+		return r.Results[i].Resource < r.Results[j].Resource
+	})
+}
+
+func (r Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge resource suites in one JUnit document. Suites are prefixed by their resource.
+// A resource without suites which did not pass is reported as an errored test case.
+func (r Report) JUnit() (junit JUnitTestSuites) {
+	for _, result := range r.Results {
+		for _, s := range result.Suites {
+			s.Name = result.Resource + "/" + s.Name
+			junit.Suites = append(junit.Suites, s)
+		}
+		if len(result.Suites) == 0 && result.Status != PassedStatus {
+			testCase := JUnitTestCase{Classname: result.Resource, Name: "venom"}
+			failure := JUnitFailure{Message: result.Error}
+			suite := JUnitTestSuite{Name: result.Resource, Tests: 1, Time: fmt.Sprintf("%.3f", result.Duration)}
+			if result.Status == SkippedStatus {
+				testCase.Skipped = &failure
+				suite.Skipped = 1
+			} else {
+				testCase.Errors = []JUnitFailure{failure}
+				suite.Errors = 1
+			}
+			suite.Cases = []JUnitTestCase{testCase}
+			junit.Suites = append(junit.Suites, suite)
+		}
+	}
+	return
+}
+
+func (r Report) WriteJUnit(w io.Writer) (err error) {
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(r.JUnit())
+	if err != nil {
+		return
+	}
+	_, err = io.WriteString(w, "\n")
+	return
+}
+
+func (r Report) WriteJson(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Write report.xml and report.json in dir
+func (r Report) Write(dir string) (err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+	xmlFile, err := os.Create(filepath.Join(dir, "report.xml"))
+	if err != nil {
+		return
+	}
+	defer xmlFile.Close()
+	err = r.WriteJUnit(xmlFile)
+	if err != nil {
+		return
+	}
+	jsonFile, err := os.Create(filepath.Join(dir, "report.json"))
+	if err != nil {
+		return
+	}
+	defer jsonFile.Close()
+	return r.WriteJson(jsonFile)
+}
+
+func (r Report) WriteSummary(w io.Writer) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tSTATUS\tTESTS\tFAILURES\tDURATION")
+	var tests, failures int
+	var duration float64
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1fs\n", result.Resource, strings.ToUpper(string(result.Status)), result.Tests, result.Failures, result.Duration)
+		tests += result.Tests
+		failures += result.Failures
+		duration += result.Duration
+	}
+	status := PassedStatus
+	if r.Failed() {
+		status = FailedStatus
+	}
+	fmt.Fprintf(tw, "TOTAL\t%s\t%d\t%d\t%.1fs\n", strings.ToUpper(string(status)), tests, failures, duration)
+	return tw.Flush()
+}
@@ -0,0 +1,132 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/test"
+)
+
+var venomXml = `<?xml version="1.0" encoding="utf-8"?>
+<testsuites>
+  <testsuite name="http" package="tests/http.yml" tests="2" errors="0" failures="1" time="0.5">
+    <testcase classname="http" name="get ok" time="0.2"></testcase>
+    <testcase classname="http" name="get ko" time="0.3">
+      <failure><value><![CDATA[expected 200 got 500]]></value></failure>
+    </testcase>
+  </testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	suites, err := ParseJUnit([]byte(venomXml))
+	require.NoError(t, err, "should not error")
+	require.Len(t, suites, 1)
+	assert.Equal(t, "http", suites[0].Name)
+	require.Len(t, suites[0].Cases, 2)
+	assert.Empty(t, suites[0].Cases[0].Failures)
+	require.Len(t, suites[0].Cases[1].Failures, 1)
+	assert.Contains(t, suites[0].Cases[1].Failures[0].Value, "expected 200 got 500")
+
+	suites, err = ParseJUnit([]byte(`<testsuite name="single" tests="1"><testcase name="c1"></testcase></testsuite>`))
+	require.NoError(t, err, "should not error")
+	require.Len(t, suites, 1)
+	assert.Equal(t, "single", suites[0].Name)
+
+	_, err = ParseJUnit([]byte("not xml"))
+	assert.Error(t, err, "should error")
+}
+
+func TestReadJUnitDir(t *testing.T) {
+	dir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+	suites, err := readJUnitDir(dir)
+	require.NoError(t, err, "should not error on missing dir")
+	assert.Empty(t, suites)
+
+	err = os.MkdirAll(dir, 0755)
+	require.NoError(t, err, "should not error")
+	err = os.WriteFile(filepath.Join(dir, "test_results.xml"), []byte(venomXml), 0644)
+	require.NoError(t, err, "should not error")
+	suites, err = readJUnitDir(dir)
+	require.NoError(t, err, "should not error")
+	assert.Len(t, suites, 1)
+}
+
+func TestNewResult(t *testing.T) {
+	suites, err := ParseJUnit([]byte(venomXml))
+	require.NoError(t, err, "should not error")
+
+	result := newResult("image/p1/i1", suites, time.Second, fmt.Errorf("exit status 2"))
+	assert.Equal(t, FailedStatus, result.Status)
+	assert.Equal(t, 2, result.Tests)
+	assert.Equal(t, 1, result.Failures)
+	assert.Equal(t, "exit status 2", result.Error)
+	assert.True(t, result.Failed(), "result should be failed")
+
+	result = newResult("image/p1/i1", nil, time.Second, fmt.Errorf("no such image"))
+	assert.Equal(t, ErrorStatus, result.Status)
+	assert.True(t, result.Failed(), "result should be failed")
+
+	result = newResult("image/p1/i1", suites[:0], time.Second, nil)
+	assert.Equal(t, PassedStatus, result.Status)
+	assert.False(t, result.Failed(), "result should not be failed")
+}
+
+func TestReport(t *testing.T) {
+	suites, err := ParseJUnit([]byte(venomXml))
+	require.NoError(t, err, "should not error")
+
+	report := Report{}
+	assert.False(t, report.Failed(), "empty report should not be failed")
+	report.Add(newResult("project/p1", nil, time.Second, nil))
+	assert.False(t, report.Failed(), "passed report should not be failed")
+	report.Add(newResult("image/p1/i1", suites, time.Second, nil), Result{Resource: "image/p1/i2", Status: ErrorStatus, Error: "boom"})
+	assert.True(t, report.Failed(), "report should be failed")
+	assert.Equal(t, "image/p1/i1", report.Results[0].Resource, "results should be sorted")
+
+	junit := report.JUnit()
+	require.Len(t, junit.Suites, 2)
+	assert.Equal(t, "image/p1/i1/http", junit.Suites[0].Name)
+	assert.Equal(t, "image/p1/i2", junit.Suites[1].Name)
+	require.Len(t, junit.Suites[1].Cases, 1)
+	assert.Equal(t, "boom", junit.Suites[1].Cases[0].Errors[0].Message)
+
+	var b bytes.Buffer
+	err = report.WriteJUnit(&b)
+	require.NoError(t, err, "should not error")
+	merged, err := ParseJUnit(b.Bytes())
+	require.NoError(t, err, "merged report should be parsable")
+	assert.Len(t, merged, 2)
+
+	b.Reset()
+	err = report.WriteJson(&b)
+	require.NoError(t, err, "should not error")
+	var decoded Report
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	require.NoError(t, err, "should not error")
+	assert.Len(t, decoded.Results, 3)
+
+	b.Reset()
+	err = report.WriteSummary(&b)
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, b.String(), "RESOURCE")
+	assert.Contains(t, b.String(), "image/p1/i2")
+	assert.Contains(t, b.String(), "TOTAL")
+
+	dir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+	err = report.Write(dir)
+	require.NoError(t, err, "should not error")
+	assert.FileExists(t, filepath.Join(dir, "report.xml"))
+	assert.FileExists(t, filepath.Join(dir, "report.json"))
+}
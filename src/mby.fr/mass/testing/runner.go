@@ -0,0 +1,155 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"mby.fr/mass/internal/command"
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/interrupt"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/settings"
+
+	"mby.fr/utils/container"
+)
+
+const (
+	VenomRunner     = "venom"
+	CommandRunner   = "command"
+	ContainerRunner = "container"
+
+	// Env var giving command and container runners a dir to write JUnit XML files in
+	ReportDirEnvVar = "MASS_TEST_REPORT_DIR"
+	// Mount points in test containers
+	containerTestDir   = "/tests"
+	containerReportDir = "/test-reports"
+)
+
+var UnknownRunner error = fmt.Errorf("Unknown test runner")
+var MissingCommand error = fmt.Errorf("No test command configured")
+var MissingImage error = fmt.Errorf("No test image configured")
+
+// Variables are passed to venom as VENOM_VAR_<name> and as environment to other runners
+type RunnerOptions struct {
+	config.TestConfig
+	TestDir   string // Absolute test dir of the resource
+	ReportDir string // Absolute dir the runner writes its JUnit XML files in
+	Network   string // Network of the tested containers
+	Variables map[string]string
+}
+
+// Test runners all report results as JUnit suites
+type Runner interface {
+	Run(ctx context.Context, log logger.ActionLogger, opts RunnerOptions) ([]JUnitTestSuite, error)
+}
+
+func NewRunner(name string) (Runner, error) {
+	switch name {
+	case "", VenomRunner:
+		return venomTestRunner{}, nil
+	case CommandRunner:
+		return commandTestRunner{}, nil
+	case ContainerRunner:
+		return containerTestRunner{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownRunner, name)
+	}
+}
+
+// Suites written in the report dir. If the runner did not write any, a single test case
+// named after the command reports the run outcome.
+func commandSuites(runner string, opts RunnerOptions, duration time.Duration, runErr error) (suites []JUnitTestSuite, err error) {
+	suites, err = readJUnitDir(opts.ReportDir)
+	if err != nil || len(suites) > 0 {
+		return
+	}
+	timing := fmt.Sprintf("%.3f", duration.Seconds())
+	testCase := JUnitTestCase{Classname: runner, Name: strings.Join(opts.Command, " "), Time: timing}
+	suite := JUnitTestSuite{Name: runner, Tests: 1, Time: timing}
+	if runErr != nil {
+		testCase.Failures = []JUnitFailure{{Message: runErr.Error()}}
+		suite.Failures = 1
+	}
+	suite.Cases = []JUnitTestCase{testCase}
+	return []JUnitTestSuite{suite}, nil
+}
+
+// Run a command on the host in the test dir
+type commandTestRunner struct{}
+
+func (r commandTestRunner) Run(ctx context.Context, log logger.ActionLogger, opts RunnerOptions) (suites []JUnitTestSuite, err error) {
+	if len(opts.Command) == 0 {
+		return nil, MissingCommand
+	}
+	cmd := exec.CommandContext(ctx, opts.Command[0], opts.Command[1:]...)
+	cmd.Dir = opts.TestDir
+	cmd.Env = append(os.Environ(), ReportDirEnvVar+"="+opts.ReportDir)
+	for varKey, varValue := range opts.Variables {
+		cmd.Env = append(cmd.Env, varKey+"="+varValue)
+	}
+	for envKey, envValue := range opts.Environment {
+		cmd.Env = append(cmd.Env, envKey+"="+envValue)
+	}
+	log.Debug("test command: %s", opts.Command)
+
+	start := time.Now()
+	runErr := command.RunLoggingContext(ctx, cmd, log)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	suites, err = commandSuites(CommandRunner, opts, time.Since(start), runErr)
+	if err == nil {
+		err = runErr
+	}
+	return
+}
+
+// Run a command in a container of the configured image with the test dir mounted
+type containerTestRunner struct{}
+
+func (r containerTestRunner) Run(ctx context.Context, log logger.ActionLogger, opts RunnerOptions) (suites []JUnitTestSuite, err error) {
+	if opts.Image == "" {
+		return nil, MissingImage
+	}
+	ss, err := settings.GetSettingsService()
+	if err != nil {
+		return
+	}
+	client, err := ss.EngineClient()
+	if err != nil {
+		return
+	}
+
+	runner := container.Runner{
+		Image:      opts.Image,
+		CmdArgs:    opts.Command,
+		WorkingDir: containerTestDir,
+		EnvArgs:    map[string]string{ReportDirEnvVar: containerReportDir},
+		Volumes:    append([]string{opts.TestDir + ":" + containerTestDir + ":ro", opts.ReportDir + ":" + containerReportDir}, opts.Volumes...),
+		Labels:     interrupt.TemporaryLabels(),
+		Network:    opts.Network,
+		Remove:     true,
+		Client:     client,
+	}
+	for varKey, varValue := range opts.Variables {
+		runner.EnvArgs[varKey] = varValue
+	}
+	for envKey, envValue := range opts.Environment {
+		runner.EnvArgs[envKey] = envValue
+	}
+
+	start := time.Now()
+	runErr := runner.WaitContext(ctx, log.Out(), log.Err())
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	suites, err = commandSuites(ContainerRunner, opts, time.Since(start), runErr)
+	if err == nil {
+		err = runErr
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/output"
+	"mby.fr/utils/test"
+)
+
+func TestNewRunner(t *testing.T) {
+	r, err := NewRunner("")
+	require.NoError(t, err, "should not error")
+	assert.IsType(t, venomTestRunner{}, r, "venom should be the default runner")
+	r, err = NewRunner(CommandRunner)
+	require.NoError(t, err, "should not error")
+	assert.IsType(t, commandTestRunner{}, r)
+	r, err = NewRunner(ContainerRunner)
+	require.NoError(t, err, "should not error")
+	assert.IsType(t, containerTestRunner{}, r)
+	_, err = NewRunner("foo")
+	assert.True(t, errors.Is(err, UnknownRunner), "should error")
+}
+
+func runCommand(t *testing.T, command ...string) ([]JUnitTestSuite, string, error) {
+	dir, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	err = os.MkdirAll(dir, 0755)
+	require.NoError(t, err, "should not error")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	var out bytes.Buffer
+	log := logger.NewAction(output.New(&out, &out, &out), "test", "t", 0)
+	opts := RunnerOptions{TestConfig: config.TestConfig{Command: command, Environment: config.EnvConfig{"FOO": "bar"}}, TestDir: dir, ReportDir: dir, Variables: map[string]string{"p1_i1_ip": "172.20.0.2"}}
+	suites, err := commandTestRunner{}.Run(context.Background(), log, opts)
+	return suites, out.String(), err
+}
+
+func TestCommandRunner(t *testing.T) {
+	suites, out, err := runCommand(t, "sh", "-c", "echo $FOO $p1_i1_ip")
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, out, "bar", "environment should be passed")
+	assert.Contains(t, out, "172.20.0.2", "variables should be passed")
+	require.Len(t, suites, 1)
+	assert.Equal(t, CommandRunner, suites[0].Name)
+	require.Len(t, suites[0].Cases, 1)
+	assert.Equal(t, "sh -c echo $FOO $p1_i1_ip", suites[0].Cases[0].Name)
+	assert.Empty(t, suites[0].Cases[0].Failures)
+
+	suites, _, err = runCommand(t, "sh", "-c", "exit 3")
+	require.Error(t, err, "should error")
+	require.Len(t, suites, 1)
+	require.Len(t, suites[0].Cases[0].Failures, 1)
+	assert.Contains(t, suites[0].Cases[0].Failures[0].Message, "exit status 3")
+
+	// JUnit files written by the command replace the synthetic suite
+	suites, _, err = runCommand(t, "sh", "-c", "cat > $"+ReportDirEnvVar+"/junit.xml <<EOF\n"+venomXml+"\nEOF\nexit 1")
+	require.Error(t, err, "should error")
+	require.Len(t, suites, 1)
+	assert.Equal(t, "http", suites[0].Name)
+
+	_, _, err = runCommand(t)
+	assert.True(t, errors.Is(err, MissingCommand), "should error without command")
+}
+
+func TestTargetVariables(t *testing.T) {
+	target := Target{Variables: map[string]string{"p1_i1_ip": "172.20.0.2", "host": "generated"}}
+	conf := config.Config{Environment: config.EnvConfig{"host": "configured", "env": "dev"}}
+	vars := target.variables(&conf)
+	assert.Equal(t, map[string]string{"p1_i1_ip": "172.20.0.2", "host": "configured", "env": "dev"}, vars, "config environment should override generated variables")
+	assert.Equal(t, "generated", target.Variables["host"], "target variables should not be modified")
+	assert.Equal(t, target.Variables, target.variables(nil))
+}
@@ -0,0 +1,156 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"mby.fr/mass/internal/config"
+	"mby.fr/mass/internal/deploy"
+	"mby.fr/mass/internal/display"
+	"mby.fr/mass/internal/resources"
+)
+
+// Deployment tests run against
+type Target struct {
+	Namespace deploy.Namespace
+	Variables map[string]string // Generated variables of deployed endpoints
+}
+
+// Endpoints variables overridden by the merged config environment
+func (t Target) variables(conf *config.Config) map[string]string {
+	vars := map[string]string{}
+	for k, v := range t.Variables {
+		vars[k] = v
+	}
+	if conf != nil {
+		for k, v := range conf.Environment {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// Run images tests then project tests. Project tests are skipped if an image tests failed.
+func RunProjectTests(ctx context.Context, d display.Displayer, target Target, reportDir string, p resources.Project) (results []Result, err error) {
+	images, err := p.Images()
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	errors := make(chan error, len(images))
+	for _, i := range images {
+		wg.Add(1)
+		go func(i *resources.Image) {
+			defer wg.Done()
+			result, err := RunImageTests(ctx, d, target, reportDir, *i)
+			mutex.Lock()
+			results = append(results, result)
+			mutex.Unlock()
+			if err != nil {
+				errors <- err
+			}
+		}(i)
+	}
+
+	// Wait for all tests to finish
+	wg.Wait()
+
+	// Use select to not block if no error in channel
+	select {
+	case err = <-errors:
+	default:
+	}
+	if err != nil {
+		skipped := Result{Resource: p.QualifiedName(), Status: SkippedStatus, Error: "images tests failed"}
+		results = append(results, skipped)
+		return
+	}
+
+	result, err := RunTests(ctx, d, target, reportDir, p)
+	results = append(results, result)
+	return
+}
+
+func RunImageTests(ctx context.Context, d display.Displayer, target Target, reportDir string, i resources.Image) (result Result, err error) {
+	return RunTests(ctx, d, target, reportDir, i)
+}
+
+// Run tests of a resource with its configured runner collecting its JUnit output in a sub directory of reportDir
+func RunTests(ctx context.Context, d display.Displayer, target Target, reportDir string, res resources.Resourcer) (result Result, err error) {
+	result.Resource = res.QualifiedName()
+	defer func() {
+		if err != nil && result.Status == "" {
+			result.Status = ErrorStatus
+			result.Error = err.Error()
+		}
+	}()
+
+	tester, ok := res.(resources.Tester)
+	if !ok {
+		err = fmt.Errorf("Resource of type %T does not implements Tester !", res)
+		return
+	}
+	runner, err := NewRunner(tester.TestRunner())
+	if err != nil {
+		return
+	}
+	conf, err := resources.MergedConfig(res)
+	if err != nil {
+		return
+	}
+
+	outputDir, err := filepath.Abs(filepath.Join(reportDir, strings.ReplaceAll(res.QualifiedName(), "/", "_")))
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return
+	}
+	network, err := target.Namespace.TestNetwork(res)
+	if err != nil {
+		return
+	}
+	opts := RunnerOptions{TestDir: tester.AbsTestDir(), ReportDir: outputDir, Network: network, Variables: target.variables(conf)}
+	if conf != nil {
+		opts.TestConfig = conf.Test
+	}
+
+	logger := d.BufferedActionLogger("test", res.QualifiedName())
+	//defer logger.Close()
+
+	start := time.Now()
+	suites, runErr := runner.Run(ctx, logger, opts)
+	result = newResult(res.QualifiedName(), suites, time.Since(start), runErr)
+	if result.Failed() {
+		err = fmt.Errorf("%w for %s", TestsFailed, res.QualifiedName())
+		if runErr != nil {
+			err = fmt.Errorf("%w: %s", err, runErr)
+		}
+	}
+	return
+}
+
+func ResourceTests(ctx context.Context, d display.Displayer, target Target, reportDir string, res resources.Resourcer) (results []Result, err error) {
+	switch v := res.(type) {
+	case *resources.Project:
+		return ResourceTests(ctx, d, target, reportDir, *v)
+	case *resources.Image:
+		return ResourceTests(ctx, d, target, reportDir, *v)
+	case resources.Project:
+		return RunProjectTests(ctx, d, target, reportDir, v)
+	case resources.Image:
+		result, err := RunImageTests(ctx, d, target, reportDir, v)
+		return []Result{result}, err
+	default:
+		d.Warn(fmt.Sprintf("Resource %s is not testable !", res.QualifiedName()))
+		return
+	}
+}
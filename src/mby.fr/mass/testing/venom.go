@@ -1,17 +1,21 @@
 package testing
 
 import (
-	"fmt"
-	"sync"
+	"context"
 
-	"mby.fr/mass/internal/display"
-	"mby.fr/mass/internal/resources"
+	"mby.fr/mass/internal/interrupt"
+	"mby.fr/mass/internal/logger"
+	"mby.fr/mass/internal/settings"
 
 	"mby.fr/utils/container"
 )
 
 var (
 	venomImage = "mxbossard/venom:1.0.1"
+	// Venom xUnit output dir in the container
+	venomOutputDir = "/venom-reports"
+	// Venom reads variables from env vars with this prefix
+	venomVarPrefix = "VENOM_VAR_"
 
 	venomRunner = container.Runner{
 		Image: venomImage,
@@ -27,77 +31,44 @@ var (
 	}
 )
 
-func RunProjectVenomTests(d display.Displayer, p resources.Project) (err error) {
-	images, err := p.Images()
-	if err != nil {
-		return
-	}
+// Run venom test suites of the test dir collecting venom xUnit output
+type venomTestRunner struct{}
 
-	var wg sync.WaitGroup
-	errors := make(chan error, len(images))
-	for _, i := range images {
-		wg.Add(1)
-		go func(i *resources.Image) {
-			defer wg.Done()
-			err = RunImageVenomTests(d, *i)
-			if err != nil {
-				errors <- err
-			}
-		}(i)
-	}
-
-	// Wait for all tests to finish
-	wg.Wait()
+func (r venomTestRunner) Run(ctx context.Context, log logger.ActionLogger, opts RunnerOptions) (suites []JUnitTestSuite, err error) {
+	testDirMount := opts.TestDir + ":/venom:ro"
+	outputDirMount := opts.ReportDir + ":" + venomOutputDir
 
-	// Use select to not block if no error in channel
-	select {
-	case err = <-errors:
-	default:
-	}
+	ss, err := settings.GetSettingsService()
 	if err != nil {
 		return
 	}
-
-	err = RunVenomTests(d, p)
-	return
-}
-
-func RunImageVenomTests(d display.Displayer, i resources.Image) (err error) {
-	return RunVenomTests(d, i)
-}
-
-func RunVenomTests(d display.Displayer, res resources.Resourcer) (err error) {
-	tester, ok := res.(resources.Tester)
-	if !ok {
-		return fmt.Errorf("Resource of type %T does not implements Tester !", res)
+	client, err := ss.EngineClient()
+	if err != nil {
+		return
 	}
 
-	testDirMount := tester.AbsTestDir() + ":/venom:ro"
-
 	runner := venomRunner
-	runner.Volumes = []string{testDirMount}
-
-	logger := d.BufferedActionLogger("test", res.QualifiedName())
-	//defer logger.Close()
-
-	err = runner.Wait(logger.Out(), logger.Err())
-	return
-}
-
-func VenomTests(d display.Displayer, res resources.Resourcer) (err error) {
-	switch v := res.(type) {
-	case *resources.Project:
-		return VenomTests(d, *v)
-	case *resources.Image:
-		return VenomTests(d, *v)
-	case resources.Project:
-		RunProjectVenomTests(d, v)
-	case resources.Image:
-		RunImageVenomTests(d, v)
-	default:
-		d.Warn(fmt.Sprintf("Resource %s is not testable !", res.QualifiedName()))
-		return
+	if opts.Image != "" {
+		runner.Image = opts.Image
+	}
+	runner.Volumes = append([]string{testDirMount, outputDirMount}, opts.Volumes...)
+	runner.CmdArgs = append(append([]string{}, venomRunner.CmdArgs...), "--format=xml", "--output-dir="+venomOutputDir)
+	runner.EnvArgs = map[string]string{}
+	for varKey, varValue := range opts.Variables {
+		runner.EnvArgs[venomVarPrefix+varKey] = varValue
+	}
+	for envKey, envValue := range opts.Environment {
+		runner.EnvArgs[envKey] = envValue
 	}
+	runner.Client = client
+	runner.Labels = interrupt.TemporaryLabels()
+	// Join the network of the tested containers
+	runner.Network = opts.Network
 
-	return
+	runErr := runner.WaitContext(ctx, log.Out(), log.Err())
+	suites, err = readJUnitDir(opts.ReportDir)
+	if err != nil {
+		log.Warn("%s", err)
+	}
+	return suites, runErr
 }
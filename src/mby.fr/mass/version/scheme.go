@@ -0,0 +1,294 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Versioning schemes
+const (
+	SemverScheme   = "semver"          // x.y.z-dev => x.y.z-rcN => x.y.z
+	ChannelsScheme = "semver-channels" // x.y.z-dev => x.y.z-<channel>.N => x.y.z
+	CalverScheme   = "calver"          // YYYY.M.N-dev => YYYY.M.N-rcN => YYYY.M.N
+)
+
+var DefaultChannels = []string{"alpha", "beta", "rc"}
+
+var UnknownScheme error = fmt.Errorf("Unknown versioning scheme")
+
+var buildMetadataRegExp = regexp.MustCompile(`^build\.(\d+)$`)
+
+// Version lifecycle of a scheme: development versions are promoted to pre-releases then released.
+type Scheme interface {
+	IsDev(version string) (bool, error)
+	IsPreRelease(version string) (bool, error)
+	// Pre-release of the last channel, still mutable like development versions
+	IsReleaseCandidate(version string) (bool, error)
+	// Next development version, of next minor or major if asked
+	NextDev(version string, minor, major bool) (string, error)
+	// First pre-release of a development version or next pre-release in the same channel
+	NextPreRelease(version string) (string, error)
+	// First pre-release of the next channel. Not ok if version is in the last channel.
+	NextChannel(version string) (next string, ok bool, err error)
+	Release(version string) (string, error)
+}
+
+func NewScheme(name string, channels []string, buildMetadata bool) (s Scheme, err error) {
+	switch name {
+	case "", SemverScheme:
+		s = semverScheme{}
+	case ChannelsScheme:
+		if len(channels) == 0 {
+			channels = DefaultChannels
+		}
+		s = channelsScheme{channels}
+	case CalverScheme:
+		s = calverScheme{now: time.Now}
+	default:
+		return nil, fmt.Errorf("%w: %s", UnknownScheme, name)
+	}
+	if buildMetadata {
+		s = buildMetadataScheme{s}
+	}
+	return
+}
+
+// Historical scheme with -dev and -rcN qualifiers
+type semverScheme struct{}
+
+func (s semverScheme) IsDev(version string) (bool, error) {
+	return IsDev(version)
+}
+
+func (s semverScheme) IsPreRelease(version string) (bool, error) {
+	return IsRc(version)
+}
+
+func (s semverScheme) IsReleaseCandidate(version string) (bool, error) {
+	return IsRc(version)
+}
+
+func (s semverScheme) NextDev(version string, minor, major bool) (res string, err error) {
+	if major {
+		res, err = NextMajor(version)
+	} else if minor {
+		res, err = NextMinor(version)
+	} else {
+		return NextDev(version)
+	}
+	if err != nil {
+		return
+	}
+	return Dev(res)
+}
+
+func (s semverScheme) NextPreRelease(version string) (string, error) {
+	return NextRc(version)
+}
+
+func (s semverScheme) NextChannel(version string) (string, bool, error) {
+	_, err := parse(version)
+	return "", false, err
+}
+
+func (s semverScheme) Release(version string) (string, error) {
+	return Release(version)
+}
+
+// Semver with ordered pre-release channels, e.g. alpha, beta then rc
+type channelsScheme struct {
+	channels []string
+}
+
+// Channel index and number of a pre-release version. Index is -1 if not a pre-release.
+func (s channelsScheme) channel(v *semver.Version) (index int, n uint64) {
+	name, number, found := strings.Cut(v.Prerelease(), ".")
+	if !found {
+		return -1, 0
+	}
+	n, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return -1, 0
+	}
+	for i, c := range s.channels {
+		if c == name {
+			return i, n
+		}
+	}
+	return -1, 0
+}
+
+func (s channelsScheme) IsDev(version string) (bool, error) {
+	return IsDev(version)
+}
+
+func (s channelsScheme) IsPreRelease(version string) (res bool, err error) {
+	v, err := parse(version)
+	if err != nil {
+		return
+	}
+	index, _ := s.channel(v)
+	return index >= 0, nil
+}
+
+func (s channelsScheme) IsReleaseCandidate(version string) (res bool, err error) {
+	v, err := parse(version)
+	if err != nil {
+		return
+	}
+	index, _ := s.channel(v)
+	return index >= 0 && index == len(s.channels)-1, nil
+}
+
+func (s channelsScheme) NextDev(version string, minor, major bool) (string, error) {
+	return semverScheme{}.NextDev(version, minor, major)
+}
+
+func (s channelsScheme) NextPreRelease(version string) (res string, err error) {
+	v, err := parse(version)
+	if err != nil {
+		return
+	}
+	bumped := *v
+	channel, n := s.channels[0], uint64(1)
+	if index, nth := s.channel(v); index >= 0 {
+		channel, n = s.channels[index], nth+1
+	} else if v.Prerelease() == "" {
+		bumped = bumped.IncPatch()
+	}
+	bumped, err = bumped.SetPrerelease(channel + "." + fmt.Sprint(n))
+	res = bumped.String()
+	return
+}
+
+func (s channelsScheme) NextChannel(version string) (res string, ok bool, err error) {
+	v, err := parse(version)
+	if err != nil {
+		return
+	}
+	index, _ := s.channel(v)
+	if index < 0 || index == len(s.channels)-1 {
+		return
+	}
+	bumped, err := v.SetPrerelease(s.channels[index+1] + ".1")
+	if err != nil {
+		return
+	}
+	return bumped.String(), true, nil
+}
+
+func (s channelsScheme) Release(version string) (string, error) {
+	return Release(version)
+}
+
+// Calendar versions YYYY.M.N where N is the release number in the month
+type calverScheme struct {
+	now func() time.Time
+}
+
+func (s calverScheme) IsDev(version string) (bool, error) {
+	return IsDev(version)
+}
+
+func (s calverScheme) IsPreRelease(version string) (bool, error) {
+	return IsRc(version)
+}
+
+func (s calverScheme) IsReleaseCandidate(version string) (bool, error) {
+	return IsRc(version)
+}
+
+// Minor and major are meaningless with calendar versions
+func (s calverScheme) NextDev(version string, minor, major bool) (res string, err error) {
+	v, err := parse(version)
+	if err != nil {
+		return
+	}
+	now := s.now()
+	year, month := uint64(now.Year()), uint64(now.Month())
+	var n uint64 = 1
+	if v.Major() == year && v.Minor() == month {
+		n = v.Patch() + 1
+	}
+	return fmt.Sprintf("%d.%d.%d-dev", year, month, n), nil
+}
+
+func (s calverScheme) NextPreRelease(version string) (string, error) {
+	return NextRc(version)
+}
+
+func (s calverScheme) NextChannel(version string) (string, bool, error) {
+	_, err := parse(version)
+	return "", false, err
+}
+
+func (s calverScheme) Release(version string) (string, error) {
+	return Release(version)
+}
+
+// Add a +build.<n> metadata incremented on each version change
+type buildMetadataScheme struct {
+	Scheme
+}
+
+func withNextBuild(from, to string) (res string, err error) {
+	fromVer, err := parse(from)
+	if err != nil {
+		return
+	}
+	toVer, err := parse(to)
+	if err != nil {
+		return
+	}
+	var n uint64 = 1
+	if submatches := buildMetadataRegExp.FindStringSubmatch(fromVer.Metadata()); submatches != nil {
+		previous, err := strconv.ParseUint(submatches[1], 10, 32)
+		if err != nil {
+			return "", err
+		}
+		n = previous + 1
+	}
+	bumped, err := toVer.SetMetadata("build." + fmt.Sprint(n))
+	if err != nil {
+		return
+	}
+	return bumped.String(), nil
+}
+
+func (s buildMetadataScheme) NextDev(version string, minor, major bool) (res string, err error) {
+	res, err = s.Scheme.NextDev(version, minor, major)
+	if err != nil {
+		return
+	}
+	return withNextBuild(version, res)
+}
+
+func (s buildMetadataScheme) NextPreRelease(version string) (res string, err error) {
+	res, err = s.Scheme.NextPreRelease(version)
+	if err != nil {
+		return
+	}
+	return withNextBuild(version, res)
+}
+
+func (s buildMetadataScheme) NextChannel(version string) (res string, ok bool, err error) {
+	res, ok, err = s.Scheme.NextChannel(version)
+	if err != nil || !ok {
+		return
+	}
+	res, err = withNextBuild(version, res)
+	return
+}
+
+func (s buildMetadataScheme) Release(version string) (res string, err error) {
+	res, err = s.Scheme.Release(version)
+	if err != nil {
+		return
+	}
+	return withNextBuild(version, res)
+}
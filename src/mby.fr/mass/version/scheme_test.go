@@ -0,0 +1,147 @@
+package version
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScheme(t *testing.T) {
+	s, err := NewScheme("", nil, false)
+	require.NoError(t, err, "should not error")
+	assert.IsType(t, semverScheme{}, s, "semver should be the default scheme")
+	s, err = NewScheme(ChannelsScheme, nil, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, channelsScheme{DefaultChannels}, s, "channels should default")
+	s, err = NewScheme(CalverScheme, nil, true)
+	require.NoError(t, err, "should not error")
+	assert.IsType(t, buildMetadataScheme{}, s)
+	_, err = NewScheme("foo", nil, false)
+	assert.True(t, errors.Is(err, UnknownScheme), "should error")
+}
+
+func TestSemverScheme(t *testing.T) {
+	s := semverScheme{}
+	cases := []struct {
+		in           string
+		minor, major bool
+		want         string
+	}{
+		{"1.0.0", false, false, "1.0.1-dev"},
+		{"1.0.3-dev", false, false, "1.0.3-dev"},
+		{"1.0.3-rc1", false, false, "1.0.4-dev"},
+		{"1.0.3-rc1", true, false, "1.1.0-dev"},
+		{"1.0.3", false, true, "2.0.0-dev"},
+	}
+	for i, c := range cases {
+		got, err := s.NextDev(c.in, c.minor, c.major)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.want, got, "case #%d should be equal", i)
+	}
+
+	got, err := s.NextPreRelease("1.0.3-dev")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.3-rc1", got)
+	got, err = s.NextPreRelease("1.0.3-rc1")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.3-rc2", got)
+	_, ok, err := s.NextChannel("1.0.3-rc2")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "semver should have a single channel")
+	rc, err := s.IsReleaseCandidate("1.0.3-rc2")
+	require.NoError(t, err, "should not error")
+	assert.True(t, rc, "rc should be a release candidate")
+	got, err = s.Release("1.0.3-rc2")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.3", got)
+}
+
+func TestChannelsScheme(t *testing.T) {
+	s := channelsScheme{[]string{"alpha", "beta"}}
+	cases := []struct {
+		in         string
+		dev, pre   bool
+		nextPre    string
+		nextChan   string
+		nextChanOk bool
+	}{
+		{"1.0.3-dev", true, false, "1.0.3-alpha.1", "", false},
+		{"1.0.3-alpha.1", false, true, "1.0.3-alpha.2", "1.0.3-beta.1", true},
+		{"1.0.3-beta.4", false, true, "1.0.3-beta.5", "", false},
+		{"1.0.3-gamma.1", false, false, "1.0.3-alpha.1", "", false},
+		{"1.0.3", false, false, "1.0.4-alpha.1", "", false},
+	}
+	for i, c := range cases {
+		dev, err := s.IsDev(c.in)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.dev, dev, "case #%d bad dev", i)
+		pre, err := s.IsPreRelease(c.in)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.pre, pre, "case #%d bad pre-release", i)
+		nextPre, err := s.NextPreRelease(c.in)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.nextPre, nextPre, "case #%d bad next pre-release", i)
+		nextChan, ok, err := s.NextChannel(c.in)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.nextChanOk, ok, "case #%d bad next channel", i)
+		assert.Equal(t, c.nextChan, nextChan, "case #%d bad next channel", i)
+	}
+
+	got, err := s.Release("1.0.3-beta.2")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.3", got)
+
+	rc, err := s.IsReleaseCandidate("1.0.3-beta.2")
+	require.NoError(t, err, "should not error")
+	assert.True(t, rc, "last channel should be a release candidate")
+	rc, err = s.IsReleaseCandidate("1.0.3-alpha.1")
+	require.NoError(t, err, "should not error")
+	assert.False(t, rc, "first channel should not be a release candidate")
+}
+
+func TestCalverScheme(t *testing.T) {
+	s := calverScheme{now: func() time.Time { return time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC) }}
+	cases := []struct {
+		in, want string
+	}{
+		{"0.0.1-dev", "2026.10.1-dev"},
+		{"2026.9.3", "2026.10.1-dev"},
+		{"2026.10.1", "2026.10.2-dev"},
+		{"2026.10.2-rc1", "2026.10.3-dev"},
+	}
+	for i, c := range cases {
+		got, err := s.NextDev(c.in, false, true)
+		require.NoError(t, err, "case #%d should not error", i)
+		assert.Equal(t, c.want, got, "case #%d should be equal", i)
+	}
+
+	got, err := s.NextPreRelease("2026.10.1-dev")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "2026.10.1-rc1", got)
+	got, err = s.Release("2026.10.1-rc1")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "2026.10.1", got)
+}
+
+func TestBuildMetadataScheme(t *testing.T) {
+	s := buildMetadataScheme{semverScheme{}}
+	got, err := s.NextDev("1.0.0", false, false)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.1-dev+build.1", got)
+	got, err = s.NextPreRelease(got)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.1-rc1+build.2", got)
+	_, ok, err := s.NextChannel(got)
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "semver should have a single channel")
+	got, err = s.Release(got)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0.1+build.3", got)
+
+	dev, err := s.IsDev("1.0.1-dev+build.1")
+	require.NoError(t, err, "should not error")
+	assert.True(t, dev, "metadata should not change qualifier")
+}
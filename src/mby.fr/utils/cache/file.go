@@ -15,6 +15,7 @@ var _ = fmt.Printf
 type Cache interface {
 	LoadString(key string) (value string, ok bool, err error)
 	StoreString(key, value string) (err error)
+	Delete(key string) (err error)
 }
 
 type persistentCache struct {
@@ -63,17 +64,45 @@ func (c persistentCache) LoadString(key string) (value string, ok bool, err erro
 	return
 }
 
+// Values are written in a temp file then renamed so concurrent readers, possibly on other hosts, never read partial values.
 func (c persistentCache) StoreString(key, value string) (err error) {
 	dir, path := c.bucketFilepath(key)
 	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	// FIXME: always atempt to create dir
 	err = os.MkdirAll(dir, 0755)
 	if err != nil {
 		return
 	}
 	//fmt.Printf("Storing value: %s in bucket: %s ...\n", value, bucket)
-	err = os.WriteFile(path, []byte(value), 0644)
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(value)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), path)
+	return
+}
+
+func (c persistentCache) Delete(key string) (err error) {
+	_, path := c.bucketFilepath(key)
+	c.mutex.Lock()
+	err = os.Remove(path)
 	c.mutex.Unlock()
+	if os.IsNotExist(err) {
+		err = nil
+	}
 	return
 }
 
@@ -104,3 +104,24 @@ func TestFileCacheLoadNotExistingKey(t *testing.T) {
 	assert.NoError(t, err, "LoadString() should not return an error")
 	assert.Equal(t, "", res, "LoadString() should return the empty string")
 }
+
+func TestFileCacheDelete(t *testing.T) {
+	path, _ := test.BuildRandTempPath()
+	defer os.RemoveAll(path)
+
+	cache, err := NewPersistentCache(path)
+
+	key := "test"
+	err = cache.StoreString(key, "val")
+	assert.NoError(t, err, "StoreString() should not return an error")
+
+	err = cache.Delete(key)
+	assert.NoError(t, err, "Delete() should not return an error")
+
+	_, ok, err := cache.LoadString(key)
+	assert.False(t, ok, "LoadString() should not return ok after Delete()")
+	assert.NoError(t, err, "LoadString() should not return an error")
+
+	err = cache.Delete(key)
+	assert.NoError(t, err, "Delete() of missing key should not return an error")
+}
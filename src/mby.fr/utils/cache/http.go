@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Simple http key value protocol, keys are hashed:
+// GET <url>/<hashed key> answers the value or 404 if missing,
+// PUT <url>/<hashed key> stores the request body,
+// DELETE <url>/<hashed key> removes the value.
+
+var HttpError error = fmt.Errorf("Http cache error")
+
+type httpCache struct {
+	url    string
+	client *http.Client
+}
+
+// Cache served by a http key value server, see NewHttpHandler(). Each request is bounded by timeout.
+func NewHttpCache(url string, timeout time.Duration) Cache {
+	return httpCache{strings.TrimSuffix(url, "/"), &http.Client{Timeout: timeout}}
+}
+
+func (c httpCache) do(method, key string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequest(method, c.url+"/"+hashKey(key), body)
+	if err != nil {
+		return
+	}
+	return c.client.Do(req)
+}
+
+func httpError(method string, resp *http.Response) error {
+	return fmt.Errorf("%w: %s %s answered %s", HttpError, method, resp.Request.URL, resp.Status)
+}
+
+func (c httpCache) LoadString(key string) (value string, ok bool, err error) {
+	resp, err := c.do(http.MethodGet, key, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return
+	} else if resp.StatusCode != http.StatusOK {
+		err = httpError(http.MethodGet, resp)
+		return
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	return string(content), true, nil
+}
+
+func (c httpCache) StoreString(key, value string) (err error) {
+	resp, err := c.do(http.MethodPut, key, strings.NewReader(value))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err = httpError(http.MethodPut, resp)
+	}
+	return
+}
+
+func (c httpCache) Delete(key string) (err error) {
+	resp, err := c.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		err = httpError(http.MethodDelete, resp)
+	}
+	return
+}
+
+// Serve the http key value protocol storing values in a cache, e.g. a persistent cache.
+// The whole request path is the key so several caches can share a server under distinct url paths.
+func NewHttpHandler(c Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if key == "" {
+			http.Error(w, "bad key", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			value, ok, err := c.LoadString(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			} else if !ok {
+				http.NotFound(w, r)
+			} else {
+				io.WriteString(w, value)
+			}
+		case http.MethodPut:
+			content, err := io.ReadAll(r.Body)
+			if err == nil {
+				err = c.StoreString(key, string(content))
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err := c.Delete(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/test"
+)
+
+func startHttpCache(t *testing.T) (server *httptest.Server, backing Cache) {
+	path, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	t.Cleanup(func() { os.RemoveAll(path) })
+	backing, err = NewPersistentCache(path)
+	require.NoError(t, err, "should not error")
+	server = httptest.NewServer(NewHttpHandler(backing))
+	t.Cleanup(server.Close)
+	return
+}
+
+func TestHttpCache(t *testing.T) {
+	server, backing := startHttpCache(t)
+	cache := NewHttpCache(server.URL+"/ns/", time.Second)
+
+	_, ok, err := cache.LoadString("test")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "missing key should not be found")
+
+	err = cache.StoreString("test", "val\nue")
+	require.NoError(t, err, "should not error")
+	value, ok, err := cache.LoadString("test")
+	require.NoError(t, err, "should not error")
+	assert.True(t, ok, "key should be found")
+	assert.Equal(t, "val\nue", value)
+
+	value, ok, err = backing.LoadString("ns/" + hashKey("test"))
+	require.NoError(t, err, "should not error")
+	assert.True(t, ok, "value should be stored by the server cache")
+	assert.Equal(t, "val\nue", value)
+
+	err = cache.Delete("test")
+	require.NoError(t, err, "should not error")
+	_, ok, err = cache.LoadString("test")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "deleted key should not be found")
+	err = cache.Delete("test")
+	assert.NoError(t, err, "deleting a missing key should not error")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failure", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	_, _, err = NewHttpCache(failing.URL, time.Second).LoadString("test")
+	assert.True(t, errors.Is(err, HttpError), "server error should error")
+}
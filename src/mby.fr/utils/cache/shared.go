@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache in a directory shared by several hosts, e.g. an NFS mount. Each operation is bounded by timeout.
+// The directory is created on first store so an unavailable mount does not prevent building the cache.
+func NewSharedDirCache(path string, timeout time.Duration) (cache Cache, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	return WithTimeout(persistentCache{&sync.Mutex{}, path}, timeout), nil
+}
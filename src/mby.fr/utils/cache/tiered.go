@@ -0,0 +1,63 @@
+package cache
+
+// Cache in front of shared caches. Loads read through shared caches on local misses
+// and copy found values locally. Stores and deletes are written back to all shared caches.
+// Shared caches failures do not fail operations, they are reported to onError if not nil.
+type tieredCache struct {
+	local   Cache
+	shared  []Cache
+	onError func(error)
+}
+
+func NewTieredCache(local Cache, onError func(error), shared ...Cache) Cache {
+	if len(shared) == 0 {
+		return local
+	}
+	return tieredCache{local, shared, onError}
+}
+
+func (c tieredCache) report(err error) {
+	if err != nil && c.onError != nil {
+		c.onError(err)
+	}
+}
+
+func (c tieredCache) LoadString(key string) (value string, ok bool, err error) {
+	value, ok, err = c.local.LoadString(key)
+	if err != nil || ok {
+		return
+	}
+	for _, shared := range c.shared {
+		value, ok, err = shared.LoadString(key)
+		if err != nil {
+			c.report(err)
+			continue
+		}
+		if ok {
+			return value, ok, c.local.StoreString(key, value)
+		}
+	}
+	return "", false, nil
+}
+
+func (c tieredCache) StoreString(key, value string) (err error) {
+	err = c.local.StoreString(key, value)
+	if err != nil {
+		return
+	}
+	for _, shared := range c.shared {
+		c.report(shared.StoreString(key, value))
+	}
+	return
+}
+
+func (c tieredCache) Delete(key string) (err error) {
+	err = c.local.Delete(key)
+	if err != nil {
+		return
+	}
+	for _, shared := range c.shared {
+		c.report(shared.Delete(key))
+	}
+	return
+}
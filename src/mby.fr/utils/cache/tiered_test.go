@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/test"
+)
+
+func newTestCache(t *testing.T) Cache {
+	path, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	t.Cleanup(func() { os.RemoveAll(path) })
+	cache, err := NewPersistentCache(path)
+	require.NoError(t, err, "should not error")
+	return cache
+}
+
+// Cache failing or hanging on every operation
+type brokenCache struct {
+	delay time.Duration
+}
+
+var broken = errors.New("broken")
+
+func (c brokenCache) LoadString(key string) (string, bool, error) {
+	time.Sleep(c.delay)
+	return "", false, broken
+}
+
+func (c brokenCache) StoreString(key, value string) error {
+	time.Sleep(c.delay)
+	return broken
+}
+
+func (c brokenCache) Delete(key string) error {
+	time.Sleep(c.delay)
+	return broken
+}
+
+func TestTieredCache(t *testing.T) {
+	local := newTestCache(t)
+	path, err := test.BuildRandTempPath()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(path)
+	shared, err := NewSharedDirCache(path, time.Second)
+	require.NoError(t, err, "should not error")
+	var reported []error
+	cache := NewTieredCache(local, func(err error) { reported = append(reported, err) }, brokenCache{}, shared)
+
+	// Read through
+	err = shared.StoreString("teammate", "built")
+	require.NoError(t, err, "should not error")
+	value, ok, err := cache.LoadString("teammate")
+	require.NoError(t, err, "should not error")
+	assert.True(t, ok, "shared value should be found")
+	assert.Equal(t, "built", value)
+	value, ok, err = local.LoadString("teammate")
+	require.NoError(t, err, "should not error")
+	assert.True(t, ok, "shared value should be copied locally")
+	assert.Equal(t, "built", value)
+
+	// Write back
+	err = cache.StoreString("mine", "built")
+	require.NoError(t, err, "should not error")
+	value, ok, err = shared.LoadString("mine")
+	require.NoError(t, err, "should not error")
+	assert.True(t, ok, "value should be written back")
+	assert.Equal(t, "built", value)
+
+	err = cache.Delete("mine")
+	require.NoError(t, err, "should not error")
+	_, ok, err = shared.LoadString("mine")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "value should be deleted from shared cache")
+
+	_, ok, err = cache.LoadString("missing")
+	require.NoError(t, err, "should not error")
+	assert.False(t, ok, "missing key should not be found")
+
+	assert.Len(t, reported, 4, "broken shared cache errors should be reported")
+	for _, e := range reported {
+		assert.True(t, errors.Is(e, broken))
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	cache := WithTimeout(brokenCache{delay: time.Second}, 10*time.Millisecond)
+	_, _, err := cache.LoadString("test")
+	assert.True(t, errors.Is(err, Timeout), "load should time out")
+	err = cache.StoreString("test", "value")
+	assert.True(t, errors.Is(err, Timeout), "store should time out")
+	err = cache.Delete("test")
+	assert.True(t, errors.Is(err, Timeout), "delete should time out")
+
+	cache = WithTimeout(brokenCache{}, time.Second)
+	_, _, err = cache.LoadString("test")
+	assert.True(t, errors.Is(err, broken), "error should be returned before timeout")
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+var Timeout error = fmt.Errorf("Cache operation timed out")
+
+// Cache bounding the duration of each operation of another cache.
+// A timed out operation is not cancelled, it keeps running in background.
+type timeoutCache struct {
+	cache   Cache
+	timeout time.Duration
+}
+
+type loadResult struct {
+	value string
+	ok    bool
+	err   error
+}
+
+// Wrap a cache to bound its operations duration. No bound if timeout is not positive.
+func WithTimeout(c Cache, timeout time.Duration) Cache {
+	if timeout <= 0 {
+		return c
+	}
+	return timeoutCache{c, timeout}
+}
+
+func (c timeoutCache) timeoutError(op, key string) error {
+	return fmt.Errorf("%w after %s: %s %s", Timeout, c.timeout, op, key)
+}
+
+func (c timeoutCache) run(op, key string, f func() error) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err = <-done:
+		return
+	case <-time.After(c.timeout):
+		return c.timeoutError(op, key)
+	}
+}
+
+func (c timeoutCache) LoadString(key string) (value string, ok bool, err error) {
+	done := make(chan loadResult, 1)
+	go func() {
+		var r loadResult
+		r.value, r.ok, r.err = c.cache.LoadString(key)
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.ok, r.err
+	case <-time.After(c.timeout):
+		return "", false, c.timeoutError("load", key)
+	}
+}
+
+func (c timeoutCache) StoreString(key, value string) (err error) {
+	return c.run("store", key, func() error {
+		return c.cache.StoreString(key, value)
+	})
+}
+
+func (c timeoutCache) Delete(key string) (err error) {
+	return c.run("delete", key, func() error {
+		return c.cache.Delete(key)
+	})
+}
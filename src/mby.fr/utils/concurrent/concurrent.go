@@ -1,6 +1,7 @@
 package concurrent
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -16,6 +17,14 @@ type Function[I, O any] func(I) (O, error) 	// 1 input => 1 output
 
 type ErrorConsumer func(error)
 
+// Function aware of a context cancelled when the run is stopped
+type ContextFunction[I, O any] func(context.Context, I) (O, error)
+
+type Options struct {
+	Parallelism int  // Max count of functions running at once. Unlimited if <= 0.
+	FailFast    bool // Cancel the run on first error
+}
+
 func consume[F Consumer[I]|Function[I, O], I, O any](f F, in I) (O, error) {
 	var out O
 	var err error
@@ -60,3 +69,61 @@ func RunWaiting[I, O any](f Function[I, O], inputs ...I) (chan O, error) {
 	err := errorz.ConsumedAggregated(errors)
 	return outputs, err.Return()
 }
+
+// Run f on inputs with a pool of opts.Parallelism workers. Inputs not started when ctx is done are skipped.
+func RunContext[I, O any](ctx context.Context, opts Options, f ContextFunction[I, O], inputs ...I) (*sync.WaitGroup, chan O, chan error) {
+	var wg sync.WaitGroup
+	outputs := make(chan O, len(inputs))
+	errors := make(chan error, len(inputs))
+
+	jobs := make(chan I, len(inputs))
+	for _, in := range inputs {
+		jobs <- in
+	}
+	close(jobs)
+
+	workers := opts.Parallelism
+	if workers <= 0 || workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				out, err := f(ctx, i)
+				if err != nil {
+					errors <- err
+					if opts.FailFast {
+						cancel()
+					}
+				} else {
+					outputs <- out
+				}
+			}
+		}()
+	}
+
+	// Release context resources once all workers are done
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+	return &wg, outputs, errors
+}
+
+// Run and wait for all workers. Return an error if ctx was cancelled.
+func RunWaitingContext[I, O any](ctx context.Context, opts Options, f ContextFunction[I, O], inputs ...I) (chan O, error) {
+	wg, outputs, errors := RunContext(ctx, opts, f, inputs...)
+	wg.Wait()
+	err := errorz.ConsumedAggregated(errors)
+	if ctx.Err() != nil {
+		err.Add(ctx.Err())
+	}
+	return outputs, err.Return()
+}
@@ -1,8 +1,11 @@
 package concurrent
 
 import (
-	//"fmt"
+	"context"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	//"github.com/stretchr/testify/require"
@@ -39,3 +42,65 @@ func TestRunWaiting(t *testing.T) {
 	assert.NotEmpty(t, outputs)
 	assert.Len(t, outputs, len(expected))
 }
+
+func TestRunWaitingContextParallelism(t *testing.T) {
+	inputs := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	var running, maxRunning int32
+	p := func(ctx context.Context, a int) (int, error) {
+		r := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return a, nil
+	}
+
+	outputs, err := RunWaitingContext(context.Background(), Options{Parallelism: 2}, p, inputs...)
+
+	assert.NoError(t, err)
+	assert.Len(t, outputs, len(inputs))
+	assert.Equal(t, int32(2), maxRunning, "should not run more than 2 functions at once")
+}
+
+func TestRunWaitingContextFailFast(t *testing.T) {
+	inputs := []int{1, 2, 3, 4, 5, 6}
+	var started int32
+	p := func(ctx context.Context, a int) (int, error) {
+		atomic.AddInt32(&started, 1)
+		if a == 1 {
+			return 0, fmt.Errorf("fail %d", a)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+		return a, nil
+	}
+
+	_, err := RunWaitingContext(context.Background(), Options{Parallelism: 2, FailFast: true}, p, inputs...)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "fail 1")
+	assert.Less(t, started, int32(len(inputs)), "should not start all functions")
+
+	started = 0
+	_, err = RunWaitingContext(context.Background(), Options{Parallelism: 2}, p, inputs...)
+	assert.Error(t, err)
+	assert.Equal(t, int32(len(inputs)), started, "should keep going after error")
+}
+
+func TestRunWaitingContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := func(ctx context.Context, a string) (string, error) {
+		return a, nil
+	}
+
+	outputs, err := RunWaitingContext(ctx, Options{}, p, "foo", "bar")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, outputs)
+}
@@ -3,9 +3,11 @@ package container
 import (
 	//"bytes"
 
+	"context"
 	"io"
 	"os/exec"
 
+	"mby.fr/utils/engine"
 	"mby.fr/utils/errorz"
 	"mby.fr/utils/inout"
 )
@@ -18,13 +20,55 @@ type Runner struct {
 	Name       string
 	Remove     bool
 	Entrypoint string
+	WorkingDir string
 	EnvArgs    map[string]string
+	Labels     map[string]string
+	Network    string
 	Volumes    []string
 	Image      string
 	CmdArgs    []string
+	// If set run the container with the engine API instead of the docker binary
+	Client *engine.Client
+}
+
+func (r Runner) waitEngine(ctx context.Context, stdOut io.Writer, stdErr io.Writer) (err error) {
+	config := engine.ContainerConfig{Image: r.Image, Cmd: r.CmdArgs}
+	if r.Entrypoint != "" {
+		config.Entrypoint = []string{r.Entrypoint}
+	}
+	config.WorkingDir = r.WorkingDir
+	config.HostConfig.Binds = r.Volumes
+	config.Labels = r.Labels
+	config.HostConfig.NetworkMode = r.Network
+	for argKey, argValue := range r.EnvArgs {
+		config.Env = append(config.Env, argKey+"="+argValue)
+	}
+
+	// Removing the container stops the run
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if r.Name != "" {
+				r.Client.ContainerRemove(r.Name, true)
+			}
+		case <-done:
+		}
+	}()
+	return r.Client.Run(r.Name, config, r.Remove, stdOut, stdErr)
 }
 
 func (r Runner) Wait(stdOut io.Writer, stdErr io.Writer) (err error) {
+	return r.WaitContext(context.Background(), stdOut, stdErr)
+}
+
+// Run the container and wait for it. The docker process is killed when ctx is done.
+func (r Runner) WaitContext(ctx context.Context, stdOut io.Writer, stdErr io.Writer) (err error) {
+	if r.Client != nil {
+		return r.waitEngine(ctx, stdOut, stdErr)
+	}
+
 	var runParams []string
 	runParams = append(runParams, "run")
 
@@ -40,6 +84,10 @@ func (r Runner) Wait(stdOut io.Writer, stdErr io.Writer) (err error) {
 		runParams = append(runParams, "--entrypoint", r.Entrypoint)
 	}
 
+	if r.WorkingDir != "" {
+		runParams = append(runParams, "--workdir", r.WorkingDir)
+	}
+
 	// Add volumes args
 	for _, arg := range r.Volumes {
 		runParams = append(runParams, "-v", arg)
@@ -51,12 +99,21 @@ func (r Runner) Wait(stdOut io.Writer, stdErr io.Writer) (err error) {
 		runParams = append(runParams, envArg)
 	}
 
+	if r.Network != "" {
+		runParams = append(runParams, "--network", r.Network)
+	}
+
+	// Add label args
+	for labelKey, labelValue := range r.Labels {
+		runParams = append(runParams, "--label", labelKey+"="+labelValue)
+	}
+
 	runParams = append(runParams, r.Image)
 
 	// Add command args
 	runParams = append(runParams, r.CmdArgs...)
 
-	cmd := exec.Command(binary, runParams...)
+	cmd := exec.CommandContext(ctx, binary, runParams...)
 
 	// Manage // exec outputs
 	errorsChan := make(chan error, 10)
@@ -3,10 +3,22 @@ package container
 import (
 	//"fmt"
 	"bytes"
+	"context"
+	bin "encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/engine"
+	"mby.fr/utils/test"
 )
 
 var (
@@ -50,3 +62,83 @@ func TestWaitRunWithEnvArg(t *testing.T) {
 	assert.Empty(t, errBuff.String())
 	require.NoError(t, err)
 }
+
+func TestWaitRunWithEngine(t *testing.T) {
+	dir, err := test.MkRandTempDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	var created engine.ContainerConfig
+	var removed bool
+	server := http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/create"):
+			json.NewDecoder(r.Body).Decode(&created)
+			json.NewEncoder(w).Encode(map[string]string{"Id": "ct"})
+		case strings.HasSuffix(r.URL.Path, "/logs"):
+			header := make([]byte, 8)
+			header[0] = 1
+			bin.BigEndian.PutUint32(header[4:], 4)
+			w.Write(append(header, []byte("foo\n")...))
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			json.NewEncoder(w).Encode(map[string]int{"StatusCode": 0})
+		case r.Method == http.MethodDelete:
+			removed = true
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	envArgs := map[string]string{"var": "foo"}
+	run := Runner{Remove: true, Image: testImage, Entrypoint: "sh", EnvArgs: envArgs, Volumes: []string{"/tmp:/tmp"},
+		Labels: map[string]string{"foo": "bar"}, CmdArgs: []string{"-c", "echo $var"}, Client: engine.NewClient(socket)}
+	var outBuff bytes.Buffer
+	var errBuff bytes.Buffer
+	err = run.Wait(&outBuff, &errBuff)
+
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n", outBuff.String())
+	assert.Empty(t, errBuff.String())
+	assert.Equal(t, engine.ContainerConfig{Image: testImage, Entrypoint: []string{"sh"}, Cmd: []string{"-c", "echo $var"},
+		Env: []string{"var=foo"}, Labels: map[string]string{"foo": "bar"}, HostConfig: engine.HostConfig{Binds: []string{"/tmp:/tmp"}}}, created)
+	assert.True(t, removed, "container should be removed")
+}
+
+func TestWaitContextWithEngineCancelled(t *testing.T) {
+	dir, err := test.MkRandTempDir()
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	removed := make(chan bool, 1)
+	server := http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/create"):
+			json.NewEncoder(w).Encode(map[string]string{"Id": "ct"})
+		case strings.HasSuffix(r.URL.Path, "/wait"):
+			// Container stops when removed
+			<-removed
+			json.NewEncoder(w).Encode(map[string]int{"StatusCode": 137})
+		case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/foo"):
+			removed <- true
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	run := Runner{Name: "foo", Image: testImage, CmdArgs: []string{"sleep", "60"}, Client: engine.NewClient(socket)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var outBuff bytes.Buffer
+	var errBuff bytes.Buffer
+	err = run.WaitContext(ctx, &outBuff, &errBuff)
+
+	require.Error(t, err, "cancelled run should error")
+	var exitErr engine.ExitError
+	assert.ErrorAs(t, err, &exitErr)
+}
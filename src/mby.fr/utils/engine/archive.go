@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"archive/tar"
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const dockerIgnoreFile = ".dockerignore"
+
+// Read .dockerignore patterns of a context directory.
+func readIgnorePatterns(contextDir string) (patterns []string, err error) {
+	f, err := os.Open(filepath.Join(contextDir, dockerIgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	err = scanner.Err()
+	return
+}
+
+// A path is ignored if the last pattern matching it or one of its parents is not a negation (!pattern).
+func ignored(relPath string, patterns []string) (ignore bool) {
+	for _, pattern := range patterns {
+		negation := strings.HasPrefix(pattern, "!")
+		pattern = filepath.Clean(strings.TrimPrefix(pattern, "!"))
+		for p := relPath; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				ignore = !negation
+				break
+			}
+		}
+	}
+	return
+}
+
+// Stream a tar archive of a build context directory.
+func TarContext(contextDir string) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(contextDir, writer))
+	}()
+	return reader
+}
+
+func writeTar(contextDir string, w io.Writer) (err error) {
+	patterns, err := readIgnorePatterns(contextDir)
+	if err != nil {
+		return
+	}
+	tw := tar.NewWriter(w)
+	err = filepath.WalkDir(contextDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(contextDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		// .dockerignore itself is always sent
+		if relPath != dockerIgnoreFile && ignored(relPath, patterns) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return
+	}
+	return tw.Close()
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var NoCredentials error = fmt.Errorf("No credentials found")
+
+// base64 url encoding of {}
+const emptyAuth = "e30="
+
+type authConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// Build the X-Registry-Auth header of a registry host from a docker config.json credentials file.
+func RegistryAuth(credentialsFile, host string) (auth string, err error) {
+	content, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return
+	}
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	err = json.Unmarshal(content, &dockerConfig)
+	if err != nil {
+		return
+	}
+	entry, ok := dockerConfig.Auths[host]
+	if !ok {
+		entry, ok = dockerConfig.Auths["https://"+host]
+	}
+	if !ok || entry.Auth == "" {
+		return "", fmt.Errorf("%w for registry %s in %s", NoCredentials, host, credentialsFile)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return
+	}
+	username, password, _ := strings.Cut(string(decoded), ":")
+	content, err = json.Marshal(authConfig{Username: username, Password: password, ServerAddress: host})
+	if err != nil {
+		return
+	}
+	auth = base64.URLEncoding.EncodeToString(content)
+	return
+}
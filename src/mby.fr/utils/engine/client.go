@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Client of the Docker Engine API listening on a unix socket.
+
+const (
+	DefaultSocket = "/var/run/docker.sock"
+	ApiVersion    = "v1.41"
+	unixScheme    = "unix://"
+)
+
+var UnsupportedHost error = fmt.Errorf("Unsupported engine host")
+
+type ApiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e ApiError) Error() string {
+	return fmt.Sprintf("Engine API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+func (e ApiError) Is(err error) bool {
+	other, ok := err.(ApiError)
+	return ok && other.StatusCode == e.StatusCode
+}
+
+var NotFound error = ApiError{StatusCode: http.StatusNotFound}
+
+type Client struct {
+	Socket string
+	http   *http.Client
+}
+
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{Socket: socket, http: &http.Client{Transport: transport}}
+}
+
+// Build a client from DOCKER_HOST env var falling back on default socket.
+func FromEnv() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		return NewClient(DefaultSocket), nil
+	}
+	if !strings.HasPrefix(host, unixScheme) {
+		return nil, fmt.Errorf("%w: %s", UnsupportedHost, host)
+	}
+	return NewClient(strings.TrimPrefix(host, unixScheme)), nil
+}
+
+func (c Client) url(path string, query url.Values) string {
+	u := url.URL{Scheme: "http", Host: "docker", Path: "/" + ApiVersion + path}
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func (c Client) do(method, path string, query url.Values, header http.Header, body io.Reader) (resp *http.Response, err error) {
+	return c.doContext(context.Background(), method, path, query, header, body)
+}
+
+// Send a request cancelled when ctx is done, closing the connection aborts the engine operation.
+func (c Client) doContext(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path, query), body)
+	if err != nil {
+		return
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err = c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, readApiError(resp)
+	}
+	return
+}
+
+func readApiError(resp *http.Response) error {
+	content, _ := io.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(content, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(content))
+	}
+	return ApiError{StatusCode: resp.StatusCode, Message: msg.Message}
+}
+
+// Send a request with an optional json body and decode the json response into result if not nil.
+func (c Client) doJson(method, path string, query url.Values, body interface{}, result interface{}) (err error) {
+	var reader io.Reader
+	header := http.Header{}
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+		header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.do(method, path, query, header, reader)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (c Client) Ping() (err error) {
+	return c.doJson(http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// Message streamed by build and pull endpoints.
+type jsonMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	Id          string `json:"id"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Aux struct {
+		ID string `json:"ID"`
+	} `json:"aux"`
+}
+
+// Decode a json messages stream writing progress into out. Return the last aux ID received.
+func readJsonMessages(r io.Reader, out io.Writer) (auxId string, err error) {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonMessage
+		err = decoder.Decode(&msg)
+		if err == io.EOF {
+			return auxId, nil
+		} else if err != nil {
+			return
+		}
+		if msg.Error != "" {
+			return auxId, fmt.Errorf("%s", msg.Error)
+		}
+		if msg.Aux.ID != "" {
+			auxId = msg.Aux.ID
+		}
+		if out == nil {
+			continue
+		}
+		if msg.Stream != "" {
+			_, err = io.WriteString(out, msg.Stream)
+		} else if msg.Status != "" && msg.Id != "" {
+			_, err = fmt.Fprintf(out, "%s: %s\n", msg.Id, msg.Status)
+		} else if msg.Status != "" {
+			_, err = fmt.Fprintln(out, msg.Status)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mby.fr/utils/test"
+)
+
+// Fake engine listening on a unix socket
+type fakeEngine struct {
+	sync.Mutex
+	socket     string
+	server     *http.Server
+	requests   []string
+	buildFiles map[string]string
+	buildQuery map[string][]string
+	containers map[string]ContainerConfig
+	exitCode   int
+	pushAuth   string
+	pushTag    string
+	tagged     []string
+	networks   map[string]map[string]string
+	pruned     string
+}
+
+func frame(stream byte, content string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+	return append(header, []byte(content)...)
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+ApiVersion)
+	f.requests = append(f.requests, r.Method+" "+path)
+	enc := json.NewEncoder(w)
+
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case path == "/build":
+		f.buildQuery = r.URL.Query()
+		f.buildFiles = map[string]string{}
+		tr := tar.NewReader(r.Body)
+		for {
+			h, err := tr.Next()
+			if err != nil {
+				break
+			}
+			content, _ := io.ReadAll(tr)
+			f.buildFiles[h.Name] = string(content)
+		}
+		if _, ok := f.buildFiles["broken"]; ok {
+			enc.Encode(map[string]string{"stream": "Step 1/1 : FROM scratch\n"})
+			enc.Encode(map[string]string{"error": "build failed"})
+			return
+		}
+		enc.Encode(map[string]string{"stream": "Step 1/1 : FROM scratch\n"})
+		enc.Encode(map[string]interface{}{"aux": map[string]string{"ID": "sha256:1234"}})
+		enc.Encode(map[string]string{"stream": "Successfully built 1234\n"})
+	case path == "/images/create":
+		if r.URL.Query().Get("fromImage") == "slow" {
+			enc.Encode(map[string]string{"status": "Pulling " + r.URL.Query().Get("tag"), "id": "slow"})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if r.URL.Query().Get("fromImage") == "unknown" {
+			w.WriteHeader(http.StatusNotFound)
+			enc.Encode(map[string]string{"message": "pull access denied"})
+			return
+		}
+		enc.Encode(map[string]string{"status": "Pulling " + r.URL.Query().Get("tag"), "id": r.URL.Query().Get("fromImage")})
+	case strings.HasSuffix(path, "/push"):
+		f.pushAuth = r.Header.Get("X-Registry-Auth")
+		f.pushTag = r.URL.Query().Get("tag")
+		enc.Encode(map[string]string{"status": "Pushed", "id": f.pushTag})
+	case strings.HasSuffix(path, "/tag"):
+		f.tagged = append(f.tagged, r.URL.Query().Get("repo")+":"+r.URL.Query().Get("tag"))
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "/images/"):
+		enc.Encode(map[string]interface{}{"Id": "sha256:1234", "RepoDigests": []string{"foo@sha256:abcd"}, "Config": map[string]interface{}{"Labels": map[string]string{"a": "b"}}})
+	case path == "/containers/create":
+		var config ContainerConfig
+		json.NewDecoder(r.Body).Decode(&config)
+		id := fmt.Sprintf("ct%d", len(f.containers))
+		f.containers[id] = config
+		w.WriteHeader(http.StatusCreated)
+		enc.Encode(map[string]string{"Id": id})
+	case strings.HasSuffix(path, "/start"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(path, "/logs"):
+		w.Write(frame(1, "out1\n"))
+		w.Write(frame(2, "err1\n"))
+		w.Write(frame(1, "out2\n"))
+	case strings.HasSuffix(path, "/wait"):
+		enc.Encode(map[string]interface{}{"StatusCode": f.exitCode})
+	case path == "/networks/create":
+		var create networkCreate
+		json.NewDecoder(r.Body).Decode(&create)
+		f.networks[create.Name] = create.Labels
+		w.WriteHeader(http.StatusCreated)
+		enc.Encode(map[string]string{"Id": "net-" + create.Name})
+	case path == "/networks/prune":
+		f.pruned = r.URL.Query().Get("filters")
+		enc.Encode(map[string]interface{}{"NetworksDeleted": []string{}})
+	case strings.HasPrefix(path, "/networks/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(path, "/networks/")
+		if _, ok := f.networks[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			enc.Encode(map[string]string{"message": "network not found"})
+			return
+		}
+		delete(f.networks, name)
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/containers/json":
+		var filters map[string][]string
+		json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		list := []map[string]string{}
+		for id, config := range f.containers {
+			matching := true
+			for _, label := range filters["label"] {
+				k, v, _ := strings.Cut(label, "=")
+				matching = matching && config.Labels[k] == v
+			}
+			if matching {
+				list = append(list, map[string]string{"Id": id})
+			}
+		}
+		enc.Encode(list)
+	case strings.HasSuffix(path, "/json"):
+		enc.Encode(ContainerInfo{Id: "ct0", Name: "/foo", State: ContainerState{Status: "exited", ExitCode: f.exitCode}})
+	case r.Method == http.MethodDelete:
+		if strings.HasSuffix(path, "/notExist") {
+			w.WriteHeader(http.StatusNotFound)
+			enc.Encode(map[string]string{"message": "No such container"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func startFakeEngine(t *testing.T) (*fakeEngine, *Client) {
+	dir, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	f := &fakeEngine{socket: filepath.Join(dir, "docker.sock"), containers: map[string]ContainerConfig{}, networks: map[string]map[string]string{}}
+	listener, err := net.Listen("unix", f.socket)
+	require.NoError(t, err, "should not error")
+	f.server = &http.Server{Handler: f}
+	go f.server.Serve(listener)
+	t.Cleanup(func() {
+		f.server.Close()
+		os.RemoveAll(dir)
+	})
+	return f, NewClient(f.socket)
+}
+
+func TestPing(t *testing.T) {
+	_, c := startFakeEngine(t)
+	err := c.Ping()
+	assert.NoError(t, err, "should not error")
+}
+
+func TestBuild(t *testing.T) {
+	f, c := startFakeEngine(t)
+	dir, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+	os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".dockerignore"), []byte("# comment\n*.log\ntmp\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("log"), 0644)
+	os.MkdirAll(filepath.Join(dir, "tmp"), 0755)
+	os.WriteFile(filepath.Join(dir, "tmp", "foo"), []byte("foo"), 0644)
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+
+	out := bytes.Buffer{}
+	opts := BuildOptions{Tags: []string{"p1/i1:0.0.1"}, BuildFile: "Dockerfile", BuildArgs: map[string]string{"k": "v"}, NoCache: true}
+	id, err := c.Build(context.Background(), dir, opts, &out)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "sha256:1234", id)
+	assert.Equal(t, "Step 1/1 : FROM scratch\nSuccessfully built 1234\n", out.String())
+
+	assert.Equal(t, []string{"p1/i1:0.0.1"}, f.buildQuery["t"])
+	assert.Equal(t, []string{"1"}, f.buildQuery["nocache"])
+	assert.Equal(t, []string{`{"k":"v"}`}, f.buildQuery["buildargs"])
+	assert.Empty(t, f.buildQuery["pull"])
+	assert.Equal(t, "FROM scratch\n", f.buildFiles["Dockerfile"])
+	assert.Equal(t, "package main", f.buildFiles["src/main.go"])
+	assert.Contains(t, f.buildFiles, ".dockerignore")
+	assert.NotContains(t, f.buildFiles, "debug.log", "ignored file should not be sent")
+	assert.NotContains(t, f.buildFiles, "tmp/foo", "ignored dir should not be sent")
+
+	os.WriteFile(filepath.Join(dir, "broken"), []byte{}, 0644)
+	_, err = c.Build(context.Background(), dir, opts, nil)
+	assert.EqualError(t, err, "build failed")
+}
+
+func TestPull(t *testing.T) {
+	_, c := startFakeEngine(t)
+	out := bytes.Buffer{}
+	err := c.Pull(context.Background(), "alpine:3.16", &out)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "alpine: Pulling 3.16\n", out.String())
+
+	err = c.Pull(context.Background(), "unknown", &out)
+	assert.ErrorIs(t, err, NotFound)
+	assert.Contains(t, err.Error(), "pull access denied")
+}
+
+func TestPullCancelled(t *testing.T) {
+	_, c := startFakeEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := bytes.Buffer{}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err := c.Pull(ctx, "slow", &out)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPush(t *testing.T) {
+	f, c := startFakeEngine(t)
+	out := bytes.Buffer{}
+	err := c.Push(context.Background(), "localhost:5000/p1/i1:1.0", "", &out)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "1.0: Pushed\n", out.String())
+	assert.Equal(t, "1.0", f.pushTag)
+	assert.Equal(t, emptyAuth, f.pushAuth, "auth header should always be sent")
+	assert.Contains(t, f.requests, "POST /images/localhost:5000/p1/i1/push")
+}
+
+func TestRegistryAuth(t *testing.T) {
+	dir, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(dir)
+	credentialsFile := filepath.Join(dir, "config.json")
+	// auth is base64 of user:pass
+	content := `{"auths": {"localhost:5000": {"auth": "dXNlcjpwYXNz"}}}`
+	err = os.WriteFile(credentialsFile, []byte(content), 0600)
+	require.NoError(t, err, "should not error")
+
+	auth, err := RegistryAuth(credentialsFile, "localhost:5000")
+	require.NoError(t, err, "should not error")
+	decoded, err := base64.URLEncoding.DecodeString(auth)
+	require.NoError(t, err, "should not error")
+	assert.JSONEq(t, `{"username": "user", "password": "pass", "serveraddress": "localhost:5000"}`, string(decoded))
+
+	_, err = RegistryAuth(credentialsFile, "other:5000")
+	assert.ErrorIs(t, err, NoCredentials)
+}
+
+func TestSplitReference(t *testing.T) {
+	cases := []struct {
+		ref, repository, tag string
+	}{
+		{"alpine", "alpine", ""},
+		{"alpine:3.16", "alpine", "3.16"},
+		{"localhost:5000/p1/i1", "localhost:5000/p1/i1", ""},
+		{"localhost:5000/p1/i1:1.0", "localhost:5000/p1/i1", "1.0"},
+		{"alpine@sha256:abcd", "alpine", "sha256:abcd"},
+	}
+	for _, c := range cases {
+		repository, tag := splitReference(c.ref)
+		assert.Equal(t, c.repository, repository, "bad repository for %s", c.ref)
+		assert.Equal(t, c.tag, tag, "bad tag for %s", c.ref)
+	}
+}
+
+func TestImageInspect(t *testing.T) {
+	_, c := startFakeEngine(t)
+	info, err := c.ImageInspect("foo")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "sha256:1234", info.Id)
+	assert.Equal(t, []string{"foo@sha256:abcd"}, info.RepoDigests)
+	assert.Equal(t, map[string]string{"a": "b"}, info.Labels)
+}
+
+func TestImageTag(t *testing.T) {
+	f, c := startFakeEngine(t)
+	err := c.ImageTag("sha256:1234", "localhost:5000/p1/i1:1.0")
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, f.requests, "POST /images/sha256:1234/tag")
+	assert.Equal(t, []string{"localhost:5000/p1/i1:1.0"}, f.tagged)
+}
+
+func TestRun(t *testing.T) {
+	f, c := startFakeEngine(t)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	config := ContainerConfig{Image: "alpine:3.16", Cmd: []string{"echo", "foo"}, Env: []string{"k=v"}}
+	err := c.Run("foo", config, true, &stdout, &stderr)
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "out1\nout2\n", stdout.String())
+	assert.Equal(t, "err1\n", stderr.String())
+	assert.Equal(t, config, f.containers["ct0"])
+	assert.Equal(t, []string{"POST /containers/create", "POST /containers/ct0/start", "GET /containers/ct0/logs", "POST /containers/ct0/wait", "DELETE /containers/ct0"}, f.requests)
+
+	f.exitCode = 3
+	err = c.Run("bar", config, false, nil, nil)
+	assert.ErrorIs(t, err, ExitError{"bar", 3})
+	assert.NotContains(t, f.requests, "DELETE /containers/ct1", "should not remove container")
+}
+
+func TestContainerInspectAndRemove(t *testing.T) {
+	_, c := startFakeEngine(t)
+	info, err := c.ContainerInspect("ct0")
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "exited", info.State.Status)
+
+	err = c.ContainerRemove("ct0", true)
+	assert.NoError(t, err, "should not error")
+	err = c.ContainerRemove("notExist", true)
+	assert.NoError(t, err, "removing not existing container should not error")
+}
+
+func TestContainerInspectRaw(t *testing.T) {
+	_, c := startFakeEngine(t)
+	content, err := c.ContainerInspectRaw("ct0")
+	require.NoError(t, err, "should not error")
+	assert.Contains(t, string(content), `"Status":"exited"`)
+}
+
+func TestNetworks(t *testing.T) {
+	f, c := startFakeEngine(t)
+	id, err := c.NetworkCreate("foo", map[string]string{"a": "b"})
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "net-foo", id)
+	assert.Equal(t, map[string]string{"a": "b"}, f.networks["foo"])
+
+	err = c.NetworkRemove("foo")
+	require.NoError(t, err, "should not error")
+	assert.NotContains(t, f.networks, "foo")
+	err = c.NetworkRemove("foo")
+	assert.NoError(t, err, "removing not existing network should not error")
+
+	err = c.NetworksPrune(map[string]string{"a": "b"})
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, `{"label":["a=b"]}`, f.pruned)
+}
+
+func TestContainerList(t *testing.T) {
+	f, c := startFakeEngine(t)
+	f.containers["ct0"] = ContainerConfig{Image: "foo", Labels: map[string]string{"a": "b", "c": "d"}}
+	f.containers["ct1"] = ContainerConfig{Image: "foo", Labels: map[string]string{"a": "x"}}
+
+	ids, err := c.ContainerList(map[string]string{"a": "b"})
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, []string{"ct0"}, ids)
+
+	ids, err = c.ContainerList(map[string]string{"a": "y"})
+	require.NoError(t, err, "should not error")
+	assert.Empty(t, ids)
+}
+
+func TestFromEnv(t *testing.T) {
+	os.Setenv("DOCKER_HOST", "unix:///tmp/foo.sock")
+	defer os.Unsetenv("DOCKER_HOST")
+	c, err := FromEnv()
+	require.NoError(t, err, "should not error")
+	assert.Equal(t, "/tmp/foo.sock", c.Socket)
+
+	os.Setenv("DOCKER_HOST", "tcp://localhost:2375")
+	_, err = FromEnv()
+	assert.ErrorIs(t, err, UnsupportedHost)
+}
@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type ContainerConfig struct {
+	Image      string
+	Entrypoint []string          `json:",omitempty"`
+	Cmd        []string          `json:",omitempty"`
+	Env        []string          `json:",omitempty"`
+	WorkingDir string            `json:",omitempty"`
+	Labels     map[string]string `json:",omitempty"`
+	HostConfig HostConfig
+	// Aliases of the container in the HostConfig.NetworkMode network
+	NetworkingConfig *NetworkingConfig `json:",omitempty"`
+}
+
+type EndpointSettings struct {
+	Aliases []string `json:",omitempty"`
+}
+
+type NetworkingConfig struct {
+	EndpointsConfig map[string]EndpointSettings
+}
+
+type HostConfig struct {
+	Binds       []string `json:",omitempty"`
+	NetworkMode string   `json:",omitempty"`
+}
+
+type ContainerState struct {
+	Status   string
+	Running  bool
+	ExitCode int
+	Error    string
+}
+
+type ContainerInfo struct {
+	Id    string
+	Name  string
+	Image string
+	State ContainerState
+}
+
+type ExitError struct {
+	Container string
+	Code      int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("Container %s exited with code %d", e.Container, e.Code)
+}
+
+func (c Client) ContainerCreate(name string, config ContainerConfig) (id string, err error) {
+	query := url.Values{}
+	if name != "" {
+		query.Set("name", name)
+	}
+	var created struct {
+		Id string `json:"Id"`
+	}
+	err = c.doJson(http.MethodPost, "/containers/create", query, config, &created)
+	id = created.Id
+	return
+}
+
+func (c Client) ContainerStart(id string) (err error) {
+	return c.doJson(http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// Wait for a container to stop and return its exit code.
+func (c Client) ContainerWait(id string) (exitCode int, err error) {
+	var result struct {
+		StatusCode int
+		Error      *struct {
+			Message string
+		}
+	}
+	err = c.doJson(http.MethodPost, "/containers/"+id+"/wait", nil, nil, &result)
+	if err != nil {
+		return
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		err = fmt.Errorf("%s", result.Error.Message)
+	}
+	exitCode = result.StatusCode
+	return
+}
+
+// Copy container logs demultiplexing stdout and stderr. If follow, block until container stops.
+func (c Client) ContainerLogs(id string, stdout, stderr io.Writer, follow bool) (err error) {
+	query := url.Values{}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	if follow {
+		query.Set("follow", "1")
+	}
+	resp, err := c.do(http.MethodGet, "/containers/"+id+"/logs", query, nil, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return Demux(resp.Body, stdout, stderr)
+}
+
+// Demultiplex a container stream: each frame has an 8 bytes header [stream, 0, 0, 0, size (4 bytes big endian)].
+func Demux(r io.Reader, stdout, stderr io.Writer) (err error) {
+	header := make([]byte, 8)
+	for {
+		_, err = io.ReadFull(r, header)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+		var out io.Writer
+		switch header[0] {
+		case 0, 1:
+			out = stdout
+		case 2:
+			out = stderr
+		default:
+			return fmt.Errorf("Bad stream type in container output: %d", header[0])
+		}
+		if out == nil {
+			out = io.Discard
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		_, err = io.CopyN(out, r, size)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Remove a container. Removing a not existing container does not error.
+func (c Client) ContainerRemove(id string, force bool) (err error) {
+	query := url.Values{}
+	if force {
+		query.Set("force", "1")
+	}
+	err = c.doJson(http.MethodDelete, "/containers/"+id, query, nil, nil)
+	if errors.Is(err, NotFound) {
+		err = nil
+	}
+	return
+}
+
+func (c Client) ContainerInspect(id string) (info ContainerInfo, err error) {
+	err = c.doJson(http.MethodGet, "/containers/"+id+"/json", nil, nil, &info)
+	return
+}
+
+// Complete inspect output of a container as returned by the engine
+func (c Client) ContainerInspectRaw(id string) (content []byte, err error) {
+	resp, err := c.do(http.MethodGet, "/containers/"+id+"/json", nil, nil, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Ids of all containers, running or not, having all labels
+func (c Client) ContainerList(labels map[string]string) (ids []string, err error) {
+	var filter []string
+	for k, v := range labels {
+		filter = append(filter, k+"="+v)
+	}
+	filters, err := json.Marshal(map[string][]string{"label": filter})
+	if err != nil {
+		return
+	}
+	query := url.Values{}
+	query.Set("all", "1")
+	query.Set("filters", string(filters))
+	var containers []struct{ Id string }
+	err = c.doJson(http.MethodGet, "/containers/json", query, nil, &containers)
+	for _, ct := range containers {
+		ids = append(ids, ct.Id)
+	}
+	return
+}
+
+// Create and start a container, copy its outputs and wait for it to stop.
+// If remove the container is removed once stopped. A non zero exit code return an ExitError.
+func (c Client) Run(name string, config ContainerConfig, remove bool, stdout, stderr io.Writer) (err error) {
+	id, err := c.ContainerCreate(name, config)
+	if err != nil {
+		return
+	}
+	if remove {
+		defer func() {
+			rmErr := c.ContainerRemove(id, true)
+			if err == nil {
+				err = rmErr
+			}
+		}()
+	}
+	err = c.ContainerStart(id)
+	if err != nil {
+		return
+	}
+	err = c.ContainerLogs(id, stdout, stderr, true)
+	if err != nil {
+		return
+	}
+	exitCode, err := c.ContainerWait(id)
+	if err != nil {
+		return
+	}
+	if exitCode != 0 {
+		ref := name
+		if ref == "" {
+			ref = id
+		}
+		err = ExitError{Container: ref, Code: exitCode}
+	}
+	return
+}
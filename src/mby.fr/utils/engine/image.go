@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type BuildOptions struct {
+	Tags      []string
+	BuildFile string // Relative to context directory
+	BuildArgs map[string]string
+	Labels    map[string]string
+	NoCache   bool
+	ForcePull bool
+}
+
+type ImageInfo struct {
+	Id          string            `json:"Id"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     string            `json:"Created"`
+	Labels      map[string]string `json:"-"`
+}
+
+// Build an image from a context directory streaming build output into out. Return the built image ID.
+// The build is aborted when ctx is done.
+func (c Client) Build(ctx context.Context, contextDir string, opts BuildOptions, out io.Writer) (imageId string, err error) {
+	query := url.Values{}
+	for _, tag := range opts.Tags {
+		query.Add("t", tag)
+	}
+	if opts.BuildFile != "" {
+		query.Set("dockerfile", opts.BuildFile)
+	}
+	if opts.NoCache {
+		query.Set("nocache", "1")
+	}
+	if opts.ForcePull {
+		query.Set("pull", "1")
+	}
+	query.Set("rm", "1")
+	if len(opts.BuildArgs) > 0 {
+		content, err := json.Marshal(opts.BuildArgs)
+		if err != nil {
+			return "", err
+		}
+		query.Set("buildargs", string(content))
+	}
+	if len(opts.Labels) > 0 {
+		content, err := json.Marshal(opts.Labels)
+		if err != nil {
+			return "", err
+		}
+		query.Set("labels", string(content))
+	}
+
+	tarball := TarContext(contextDir)
+	defer tarball.Close()
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-tar")
+	resp, err := c.doContext(ctx, http.MethodPost, "/build", query, header, tarball)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return readJsonMessages(resp.Body, out)
+}
+
+func splitReference(ref string) (repository, tag string) {
+	repository = ref
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return
+}
+
+// Pull an image streaming pull progress into out. The pull is aborted when ctx is done.
+func (c Client) Pull(ctx context.Context, ref string, out io.Writer) (err error) {
+	repository, tag := splitReference(ref)
+	if tag == "" {
+		tag = "latest"
+	}
+	query := url.Values{}
+	query.Set("fromImage", repository)
+	query.Set("tag", tag)
+	resp, err := c.doContext(ctx, http.MethodPost, "/images/create", query, nil, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	_, err = readJsonMessages(resp.Body, out)
+	return
+}
+
+func (c Client) ImageInspect(ref string) (info ImageInfo, err error) {
+	var raw struct {
+		ImageInfo
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	err = c.doJson(http.MethodGet, "/images/"+ref+"/json", nil, nil, &raw)
+	info = raw.ImageInfo
+	info.Labels = raw.Config.Labels
+	return
+}
+
+// Push an image streaming push progress into out. auth is an encoded X-Registry-Auth header, see RegistryAuth().
+// The push is aborted when ctx is done.
+func (c Client) Push(ctx context.Context, ref string, auth string, out io.Writer) (err error) {
+	repository, tag := splitReference(ref)
+	query := url.Values{}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	if auth == "" {
+		// Header is mandatory even for anonymous push
+		auth = emptyAuth
+	}
+	header := http.Header{}
+	header.Set("X-Registry-Auth", auth)
+	resp, err := c.doContext(ctx, http.MethodPost, "/images/"+repository+"/push", query, header, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	_, err = readJsonMessages(resp.Body, out)
+	return
+}
+
+// Tag a local image with a new reference
+func (c Client) ImageTag(source, ref string) (err error) {
+	repository, tag := splitReference(ref)
+	query := url.Values{}
+	query.Set("repo", repository)
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	return c.doJson(http.MethodPost, "/images/"+source+"/tag", query, nil, nil)
+}
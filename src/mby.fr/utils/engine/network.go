@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+type networkCreate struct {
+	Name           string
+	CheckDuplicate bool
+	Labels         map[string]string `json:",omitempty"`
+}
+
+func (c Client) NetworkCreate(name string, labels map[string]string) (id string, err error) {
+	var created struct{ Id string }
+	err = c.doJson(http.MethodPost, "/networks/create", nil, networkCreate{name, true, labels}, &created)
+	id = created.Id
+	return
+}
+
+// Remove a network. Removing a not existing network does not error.
+func (c Client) NetworkRemove(id string) (err error) {
+	err = c.doJson(http.MethodDelete, "/networks/"+id, nil, nil, nil)
+	if errors.Is(err, NotFound) {
+		err = nil
+	}
+	return
+}
+
+// Remove unused networks having all labels
+func (c Client) NetworksPrune(labels map[string]string) (err error) {
+	var filter []string
+	for k, v := range labels {
+		filter = append(filter, k+"="+v)
+	}
+	filters, err := json.Marshal(map[string][]string{"label": filter})
+	if err != nil {
+		return
+	}
+	query := url.Values{}
+	query.Set("filters", string(filters))
+	err = c.doJson(http.MethodPost, "/networks/prune", query, nil, nil)
+	return
+}
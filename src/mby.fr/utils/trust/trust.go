@@ -51,6 +51,15 @@ func SignFilesContent(pathes ...string) (sign string, err error) {
 	return
 }
 
+// Sign the content of the file at path as if it was named name, whatever its location
+func SignNamedFileContent(name, path string) (sign string, err error) {
+	open := func(string) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+	sign, err = dirhash.Hash1([]string{name}, open)
+	return
+}
+
 func SignDirContent(path string) (sign string, err error) {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
@@ -111,6 +111,28 @@ func TestSignFile(t *testing.T) {
 	assertSignatureDiffer(t, s1a, s2a, err, "between 2 different files")
 }
 
+func TestSignNamedFile(t *testing.T) {
+	path1, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(path1)
+	path2, err := test.MkRandTempDir()
+	require.NoError(t, err, "should not error")
+	defer os.RemoveAll(path2)
+
+	file1 := filepath.Join(path1, "file")
+	os.WriteFile(file1, []byte("foo"), 0644)
+	file2 := filepath.Join(path2, "file")
+	os.WriteFile(file2, []byte("foo"), 0644)
+	s1, err := SignNamedFileContent("file", file1)
+	assertSignatureOk(t, s1, err, "file1")
+
+	s2, err := SignNamedFileContent("file", file2)
+	assertSameSignature(t, s1, s2, err, "same content in different dirs")
+
+	s3, err := SignNamedFileContent("other", file2)
+	assertSignatureDiffer(t, s1, s3, err, "same content with different names")
+}
+
 func TestSignEmptyDir(t *testing.T) {
 	path, err := test.MkRandTempDir()
 	require.NoError(t, err, "should not error")
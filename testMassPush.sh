@@ -0,0 +1,66 @@
+#! /bin/bash -e
+scriptDir=$( dirname $( readlink -f $0 ) )
+
+workspaceDir="/tmp/massPushWorkspace"
+registryName="massTestRegistry"
+registryPort="5000"
+registryHost="localhost:$registryPort"
+
+cd $scriptDir/src/mby.fr/mass
+go install
+
+rm -rf -- "$workspaceDir"
+
+massCmd="mass"
+
+# Start a local registry stand-in
+docker rm -f $registryName > /dev/null 2>&1 || true
+docker run -d --rm --name $registryName -p $registryPort:5000 registry:2
+trap "docker rm -f $registryName > /dev/null 2>&1 || true" EXIT
+
+# Init a workspace
+$massCmd init workspace $workspaceDir
+cd $workspaceDir
+
+# Configure dev env registry
+cat <<EOF >> .mass/settings.yaml
+registries:
+  dev:
+    host: $registryHost
+    namespace: mass
+EOF
+
+# Init some images
+$massCmd init project p1
+$massCmd init image p1/i11 p1/i12
+
+for name in p1/i11 p1/i12; do
+	cat <<EOF > $name/Dockerfile
+FROM alpine
+RUN echo "$name"
+EOF
+done
+
+echo "##### Testing mass build p/p1 ..."
+$massCmd build p/p1
+
+echo "##### Testing mass push of dev version is refused ..."
+if $massCmd push i/p1/i11; then
+	>&2 echo "Dev version should not be pushed without --force !"
+	exit 1
+fi
+
+echo "##### Testing mass push --force i/p1/i11 ..."
+$massCmd push --force i/p1/i11
+curl -sf "http://$registryHost/v2/mass/p1/i11/tags/list" | grep -q "dev"
+
+echo "##### Testing mass push of released version ..."
+# Images have no tests to record so promote and release are forced
+$massCmd promote --force i/p1/i12
+$massCmd release --force i/p1/i12
+$massCmd build i/p1/i12
+$massCmd push i/p1/i12
+curl -sf "http://$registryHost/v2/_catalog" | grep -q "mass/p1/i12"
+
+echo
+echo SUCCESS